@@ -0,0 +1,169 @@
+// Package diff computes line- and word-based differences between texts and merges concurrent changes
+package diff
+
+// Op describes the kind of an edit
+type Op int
+
+const (
+	// Equal means the element is contained in both sequences
+	Equal Op = iota
+	// Delete means the element is only contained in the first sequence
+	Delete
+	// Insert means the element is only contained in the second sequence
+	Insert
+)
+
+// Edit is a single step of an edit script transforming sequence a into sequence b
+type Edit struct {
+	Op   Op
+	Text string
+
+	// Index of the element in a (-1 for insertions)
+	AIndex int
+	// Index of the element in b (-1 for deletions)
+	BIndex int
+}
+
+// Diff computes the shortest edit script transforming a into b using Myers' algorithm.
+// The linear space variant is used, so large changes don't need quadratic memory.
+func Diff(a, b []string) []Edit {
+	return compare(a, b, 0, 0, make([]Edit, 0, len(a)+len(b)))
+}
+
+// DiffBounded computes the edit script transforming a into b like Diff, unless a and b have more than limit
+// elements apart from their common prefix and suffix. In that case, they are not compared, as the running time
+// grows quadratically in the worst case. Instead, all of them are replaced, and false is returned.
+func DiffBounded(a, b []string, limit int) ([]Edit, bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	if len(a)+len(b)-2*(prefix+suffix) <= limit {
+		return Diff(a, b), true
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Op: Equal, Text: a[i], AIndex: i, BIndex: i})
+	}
+	for i := prefix; i < len(a)-suffix; i++ {
+		edits = append(edits, Edit{Op: Delete, Text: a[i], AIndex: i, BIndex: -1})
+	}
+	for i := prefix; i < len(b)-suffix; i++ {
+		edits = append(edits, Edit{Op: Insert, Text: b[i], AIndex: -1, BIndex: i})
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, Edit{Op: Equal, Text: a[len(a)-i], AIndex: len(a) - i, BIndex: len(b) - i})
+	}
+
+	return edits, false
+}
+
+// compare appends the edit script transforming a into b to edits.
+// a and b start at aOffset and bOffset of the sequences passed to Diff.
+func compare(a, b []string, aOffset, bOffset int, edits []Edit) []Edit {
+	// Strip common prefix and suffix, which are usually most of the text
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, Edit{Op: Equal, Text: a[prefix], AIndex: aOffset + prefix, BIndex: bOffset + prefix})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	aOffset, bOffset = aOffset+prefix, bOffset+prefix
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	aSuffix := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for i, text := range b {
+			edits = append(edits, Edit{Op: Insert, Text: text, AIndex: -1, BIndex: bOffset + i})
+		}
+	case len(b) == 0:
+		for i, text := range a {
+			edits = append(edits, Edit{Op: Delete, Text: text, AIndex: aOffset + i, BIndex: -1})
+		}
+	default:
+		// Both halves are shorter than the whole, as the middle snake is neither at the start nor at the end
+		x, y := middleSnake(a, b)
+		edits = compare(a[:x], b[:y], aOffset, bOffset, edits)
+		edits = compare(a[x:], b[y:], aOffset+x, bOffset+y, edits)
+	}
+
+	for i, text := range aSuffix {
+		edits = append(edits, Edit{Op: Equal, Text: text, AIndex: aOffset + len(a) + i, BIndex: bOffset + len(b) + i})
+	}
+
+	return edits
+}
+
+// middleSnake returns a point on a shortest edit script transforming a into b,
+// which is reached after about half of the edits. It searches forward from the start and backward from
+// the end at the same time until both meet, as described in
+// "An O(ND) Difference Algorithm and Its Variations" (Myers, 1986).
+// a and b must be non-empty and must not have a common prefix or suffix.
+func middleSnake(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// vf[offset+k] is the furthest x reached on diagonal k = x-y searching forward,
+	// vb[offset+k] the same searching backward, counted from the end of both sequences
+	offset := maxD + 1
+	vf := make([]int, 2*maxD+3)
+	vb := make([]int, 2*maxD+3)
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			// Diagonal k corresponds to diagonal delta-k of the backward search, which has done d-1 steps
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && x+vb[offset+kb] >= n {
+				return x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			// Diagonal k corresponds to diagonal delta-k of the forward search, which has done d steps
+			if kf := delta - k; !odd && kf >= -d && kf <= d && vf[offset+kf]+x >= n {
+				return n - x, m - y
+			}
+		}
+	}
+
+	panic("unreachable")
+}
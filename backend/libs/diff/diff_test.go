@@ -0,0 +1,306 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// apply reconstructs both sequences from an edit script
+func apply(edits []Edit) ([]string, []string) {
+	a, b := []string{}, []string{}
+	for _, e := range edits {
+		if e.Op != Insert {
+			a = append(a, e.Text)
+		}
+		if e.Op != Delete {
+			b = append(b, e.Text)
+		}
+	}
+	return a, b
+}
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name    string
+		a       string
+		b       string
+		changes int
+	}{
+		{name: "identical", a: "a b c", b: "a b c", changes: 0},
+		{name: "both empty", a: "", b: "", changes: 0},
+		{name: "insert into empty", a: "", b: "a b", changes: 2},
+		{name: "delete all", a: "a b", b: "", changes: 2},
+		{name: "replace middle", a: "a b c", b: "a x c", changes: 2},
+		{name: "insert at start", a: "b c", b: "a b c", changes: 1},
+		{name: "delete at end", a: "a b c", b: "a b", changes: 1},
+		{name: "myers example", a: "a b c a b b a", b: "c b a b a c", changes: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := strings.Fields(tc.a), strings.Fields(tc.b)
+			edits := Diff(a, b)
+
+			gotA, gotB := apply(edits)
+			assert.Equal(t, a, gotA)
+			assert.Equal(t, b, gotB)
+
+			changes := 0
+			for _, e := range edits {
+				switch e.Op {
+				case Equal:
+					assert.Equal(t, a[e.AIndex], b[e.BIndex])
+				case Delete:
+					assert.Equal(t, a[e.AIndex], e.Text)
+					assert.Equal(t, -1, e.BIndex)
+					changes++
+				case Insert:
+					assert.Equal(t, b[e.BIndex], e.Text)
+					assert.Equal(t, -1, e.AIndex)
+					changes++
+				}
+			}
+			assert.Equal(t, tc.changes, changes)
+		})
+	}
+}
+
+// countChanges returns the number of inserted and deleted elements
+func countChanges(edits []Edit) int {
+	changes := 0
+	for _, e := range edits {
+		if e.Op != Equal {
+			changes++
+		}
+	}
+	return changes
+}
+
+// lcsLength computes the length of the longest common subsequence with dynamic programming
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiff_Shortest(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randomSequence := func() []string {
+		s := make([]string, rng.IntN(20))
+		for i := range s {
+			s[i] = string(rune('a' + rng.IntN(3)))
+		}
+		return s
+	}
+
+	for i := 0; i < 1000; i++ {
+		a, b := randomSequence(), randomSequence()
+		edits := Diff(a, b)
+
+		gotA, gotB := apply(edits)
+		assert.Equal(t, a, gotA)
+		assert.Equal(t, b, gotB)
+		assert.Equal(t, len(a)+len(b)-2*lcsLength(a, b), countChanges(edits), "%v -> %v", a, b)
+	}
+}
+
+func TestDiff_ManyChanges(t *testing.T) {
+	// Every line is changed except every tenth
+	a, b := []string{}, []string{}
+	for i := 0; i < 5000; i++ {
+		if i%10 == 0 {
+			a = append(a, fmt.Sprintf("same %d", i))
+			b = append(b, fmt.Sprintf("same %d", i))
+		} else {
+			a = append(a, fmt.Sprintf("old %d", i))
+			b = append(b, fmt.Sprintf("new %d", i))
+		}
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := Diff(a, b)
+	runtime.ReadMemStats(&after)
+
+	gotA, gotB := apply(edits)
+	assert.Equal(t, a, gotA)
+	assert.Equal(t, b, gotB)
+	assert.Equal(t, 2*4500, countChanges(edits))
+
+	// Memory grows linearly with the number of changes (quadratic would be hundreds of MB)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(32<<20))
+}
+
+func TestDiffBounded(t *testing.T) {
+	a := strings.Fields("a b c d e f")
+	b := strings.Fields("a x c y e f")
+
+	// Within limit
+	edits, compared := DiffBounded(a, b, 6)
+	assert.True(t, compared)
+	assert.Equal(t, Diff(a, b), edits)
+
+	// Changed elements between common prefix and suffix are replaced
+	edits, compared = DiffBounded(a, b, 5)
+	assert.False(t, compared)
+	assert.Equal(t, []Edit{
+		{Op: Equal, Text: "a", AIndex: 0, BIndex: 0},
+		{Op: Delete, Text: "b", AIndex: 1, BIndex: -1},
+		{Op: Delete, Text: "c", AIndex: 2, BIndex: -1},
+		{Op: Delete, Text: "d", AIndex: 3, BIndex: -1},
+		{Op: Insert, Text: "x", AIndex: -1, BIndex: 1},
+		{Op: Insert, Text: "c", AIndex: -1, BIndex: 2},
+		{Op: Insert, Text: "y", AIndex: -1, BIndex: 3},
+		{Op: Equal, Text: "e", AIndex: 4, BIndex: 4},
+		{Op: Equal, Text: "f", AIndex: 5, BIndex: 5},
+	}, edits)
+}
+
+func TestMerge(t *testing.T) {
+	testCases := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		merged    string
+		conflicts []Conflict
+	}{
+		{
+			name:   "no changes",
+			base:   "a b c",
+			ours:   "a b c",
+			theirs: "a b c",
+			merged: "a b c",
+		},
+		{
+			name:   "only ours changed",
+			base:   "a b c",
+			ours:   "a x c",
+			theirs: "a b c",
+			merged: "a x c",
+		},
+		{
+			name:   "only theirs changed",
+			base:   "a b c",
+			ours:   "a b c",
+			theirs: "a b c d",
+			merged: "a b c d",
+		},
+		{
+			name:   "non-overlapping changes",
+			base:   "a b c d e",
+			ours:   "x a b c d e",
+			theirs: "a b c y e",
+			merged: "x a b c y e",
+		},
+		{
+			name:   "identical changes",
+			base:   "a b c",
+			ours:   "a x c",
+			theirs: "a x c",
+			merged: "a x c",
+		},
+		{
+			name:   "deletion and change elsewhere",
+			base:   "a b c d",
+			ours:   "a c d",
+			theirs: "a b c z",
+			merged: "a c z",
+		},
+		{
+			name:   "conflicting change",
+			base:   "a b c",
+			ours:   "a x c",
+			theirs: "a y c",
+			merged: "a x c",
+			conflicts: []Conflict{
+				{BaseIndex: 1, Base: []string{"b"}, Ours: []string{"x"}, Theirs: []string{"y"}},
+			},
+		},
+		{
+			name:   "conflicting insertions at end",
+			base:   "a",
+			ours:   "a x",
+			theirs: "a y z",
+			merged: "a x",
+			conflicts: []Conflict{
+				{BaseIndex: 1, Base: []string{}, Ours: []string{"x"}, Theirs: []string{"y", "z"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts := Merge(strings.Fields(tc.base), strings.Fields(tc.ours), strings.Fields(tc.theirs))
+
+			assert.Equal(t, strings.Fields(tc.merged), merged)
+			if tc.conflicts == nil {
+				assert.Empty(t, conflicts)
+			} else {
+				assert.Equal(t, tc.conflicts, conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeBounded(t *testing.T) {
+	base := strings.Fields("a b c d e f")
+
+	// Within the limit, changes are merged like without limit
+	merged, conflicts := MergeBounded(base, strings.Fields("a x c d e f"), strings.Fields("a b c d y f"), 4)
+	assert.Equal(t, strings.Fields("a x c d y f"), merged)
+	assert.Empty(t, conflicts)
+
+	// Changes exceeding the limit are treated as replacing the whole changed region
+	merged, conflicts = MergeBounded(base, strings.Fields("a x c d y f"), strings.Fields("a b z d e f"), 2)
+	assert.Equal(t, strings.Fields("a x c d y f"), merged)
+	assert.Equal(t, []Conflict{{
+		BaseIndex: 1,
+		Base:      strings.Fields("b c d e"),
+		Ours:      strings.Fields("x c d y"),
+		Theirs:    strings.Fields("b z d e"),
+	}}, conflicts)
+
+	// Unless the other version didn't change that region
+	merged, conflicts = MergeBounded(base, strings.Fields("a x c d y f"), base, 2)
+	assert.Equal(t, strings.Fields("a x c d y f"), merged)
+	assert.Empty(t, conflicts)
+}
+
+func TestSplitWords(t *testing.T) {
+	testCases := []struct {
+		text  string
+		parts []string
+	}{
+		{"", []string{}},
+		{"word", []string{"word"}},
+		{"two words", []string{"two", " ", "words"}},
+		{"Hello,  world!", []string{"Hello", ",", "  ", "world", "!"}},
+		{"snake_case and 42", []string{"snake_case", " ", "and", " ", "42"}},
+		{"Grüße, 世界", []string{"Grüße", ",", " ", "世界"}},
+		{"**bold**", []string{"*", "*", "bold", "*", "*"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			parts := SplitWords(tc.text)
+			assert.Equal(t, tc.parts, parts)
+			assert.Equal(t, tc.text, strings.Join(parts, ""))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package diff
+
+import "slices"
+
+// Conflict describes a region that was changed differently in both versions
+type Conflict struct {
+	// Index of the first line of the region in base
+	BaseIndex int
+
+	Base   []string
+	Ours   []string
+	Theirs []string
+}
+
+// Merge performs a three-way merge (diff3) of two versions derived from a common base.
+// Changes that don't overlap are combined. For overlapping changes a conflict is returned,
+// in which case the merged result contains our version of the conflicting region.
+func Merge(base, ours, theirs []string) ([]string, []Conflict) {
+	return merge(base, ours, theirs, Diff)
+}
+
+// MergeBounded performs a three-way merge like Merge, but compares the versions with DiffBounded.
+// If a version differs from base in more than limit lines, the whole changed region is treated
+// as replaced, so overlapping changes result in one conflict covering that region.
+func MergeBounded(base, ours, theirs []string, limit int) ([]string, []Conflict) {
+	return merge(base, ours, theirs, func(a, b []string) []Edit {
+		edits, _ := DiffBounded(a, b, limit)
+		return edits
+	})
+}
+
+func merge(base, ours, theirs []string, diff func(a, b []string) []Edit) ([]string, []Conflict) {
+	oursMatch := matches(base, ours, diff)
+	theirsMatch := matches(base, theirs, diff)
+
+	merged := []string{}
+	conflicts := []Conflict{}
+
+	ib, io, it := 0, 0, 0
+	for ib < len(base) || io < len(ours) || it < len(theirs) {
+		// Copy lines unchanged in both versions
+		if ib < len(base) && oursMatch[ib] == io && theirsMatch[ib] == it {
+			merged = append(merged, base[ib])
+			ib++
+			io++
+			it++
+			continue
+		}
+
+		// Find next line of base that is contained in both versions
+		j := ib
+		for j < len(base) && (oursMatch[j] < 0 || theirsMatch[j] < 0) {
+			j++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if j < len(base) {
+			oEnd, tEnd = oursMatch[j], theirsMatch[j]
+		}
+
+		baseChunk := base[ib:j]
+		oursChunk := ours[io:oEnd]
+		theirsChunk := theirs[it:tEnd]
+
+		switch {
+		case slices.Equal(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case slices.Equal(theirsChunk, baseChunk), slices.Equal(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			merged = append(merged, oursChunk...)
+			conflicts = append(conflicts, Conflict{
+				BaseIndex: ib,
+				Base:      slices.Clone(baseChunk),
+				Ours:      slices.Clone(oursChunk),
+				Theirs:    slices.Clone(theirsChunk),
+			})
+		}
+
+		ib, io, it = j, oEnd, tEnd
+	}
+
+	return merged, conflicts
+}
+
+// matches returns for each element of a the index of the corresponding element in b, or -1 if it was removed
+func matches(a, b []string, diff func(a, b []string) []Edit) []int {
+	result := make([]int, len(a))
+	for i := range result {
+		result[i] = -1
+	}
+
+	for _, e := range diff(a, b) {
+		if e.Op == Equal {
+			result[e.AIndex] = e.BIndex
+		}
+	}
+
+	return result
+}
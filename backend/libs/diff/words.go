@@ -0,0 +1,30 @@
+package diff
+
+import "unicode"
+
+// SplitWords splits a text into words, runs of whitespace, and single other characters (e.g. punctuation).
+// Joining the parts results in the original text.
+func SplitWords(text string) []string {
+	runes := []rune(text)
+	parts := []string{}
+
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || !sameWordClass(runes[i-1], runes[i]) {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	return parts
+}
+
+// sameWordClass checks if two adjacent characters belong to the same part
+func sameWordClass(a, b rune) bool {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+
+	if isWord(a) && isWord(b) {
+		return true
+	}
+	return unicode.IsSpace(a) && unicode.IsSpace(b)
+}
@@ -0,0 +1,170 @@
+// Package feed renders Atom 1.0 and RSS 2.0 feeds
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is a format-independent description of a feed
+type Feed struct {
+	Title   string
+	Link    string // URL of the website
+	FeedURL string // URL of the feed itself
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is a single item of a feed
+type Entry struct {
+	ID      string
+	Title   string
+	Link    string
+	Author  string
+	Updated time.Time
+	Summary string // Plain text
+	Content string // HTML
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author"`
+	Summary string      `xml:"summary,omitempty"`
+	Content *atomText   `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// WriteAtom writes the feed in Atom 1.0 format
+func WriteAtom(w io.Writer, f Feed) error {
+	feed := atomFeed{
+		ID:      f.FeedURL,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self"},
+			{Href: f.Link, Rel: "alternate"},
+		},
+	}
+
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: e.Link, Rel: "alternate"},
+			Summary: e.Summary,
+		}
+		// Atom requires an author for each entry if the feed has none
+		author := e.Author
+		if author == "" {
+			author = "unknown"
+		}
+		entry.Author = &atomAuthor{Name: author}
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: e.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return write(w, feed)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Author      string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS writes the feed in RSS 2.0 format.
+// As RSS has no separate summary, the content is used as description if available.
+func WriteRSS(w io.Writer, f Feed) error {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range f.Entries {
+		description := e.Content
+		if description == "" {
+			description = e.Summary
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Updated.UTC().Format(time.RFC1123Z),
+			Author:      e.Author,
+			Description: description,
+		})
+	}
+
+	return write(w, feed)
+}
+
+func write(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,78 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFeed = Feed{
+	Title:   "Wiki",
+	Link:    "https://example.com/",
+	FeedURL: "https://example.com/_api/feeds/atom/",
+	Updated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Entries: []Entry{
+		{
+			ID:      "https://example.com/page#change-1",
+			Title:   "Page <edited>",
+			Link:    "https://example.com/page",
+			Author:  "Alice",
+			Updated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Summary: "Fixed typo",
+			Content: "<pre>+ new &amp; line</pre>",
+		},
+		{
+			ID:      "https://example.com/other#change-2",
+			Title:   "Other",
+			Link:    "https://example.com/other",
+			Updated: time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC),
+			Summary: "Deleted",
+		},
+	},
+}
+
+func TestWriteAtom(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteAtom(&sb, testFeed))
+	out := sb.String()
+
+	assert.True(t, strings.HasPrefix(out, xml.Header))
+	assert.Contains(t, out, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, out, `<link href="https://example.com/_api/feeds/atom/" rel="self"></link>`)
+	assert.Contains(t, out, `<updated>2024-05-01T12:00:00Z</updated>`)
+	assert.Contains(t, out, `<title>Page &lt;edited&gt;</title>`)
+	assert.Contains(t, out, `<name>Alice</name>`)
+	assert.Contains(t, out, `<name>unknown</name>`)
+	assert.Contains(t, out, `<content type="html">&lt;pre&gt;+ new &amp;amp; line&lt;/pre&gt;</content>`)
+
+	var parsed atomFeed
+	require.NoError(t, xml.Unmarshal([]byte(out), &parsed))
+	assert.Len(t, parsed.Entries, 2)
+	assert.Equal(t, "Fixed typo", parsed.Entries[0].Summary)
+	assert.Nil(t, parsed.Entries[1].Content)
+}
+
+func TestWriteRSS(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteRSS(&sb, testFeed))
+	out := sb.String()
+
+	assert.Contains(t, out, `<rss version="2.0">`)
+	assert.Contains(t, out, `<lastBuildDate>Wed, 01 May 2024 12:00:00 +0000</lastBuildDate>`)
+
+	var parsed rssFeed
+	require.NoError(t, xml.Unmarshal([]byte(out), &parsed))
+	items := parsed.Channel.Items
+	require.Len(t, items, 2)
+	assert.Equal(t, "Page <edited>", items[0].Title)
+	assert.Equal(t, "<pre>+ new &amp; line</pre>", items[0].Description)
+	assert.Equal(t, "Alice", items[0].Author)
+	assert.False(t, items[0].GUID.IsPermaLink)
+	// Summary is used if there is no content
+	assert.Equal(t, "Deleted", items[1].Description)
+	assert.Empty(t, items[1].Author)
+}
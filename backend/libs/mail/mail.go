@@ -0,0 +1,97 @@
+// Package mail sends plain text emails via SMTP
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPConfig describes the SMTP server used to send emails
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // No authentication if empty
+	Password string
+	From     string
+}
+
+// SMTPSender delivers emails via an SMTP server. STARTTLS is used if the server supports it.
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	if config.Port == 0 {
+		config.Port = 25
+	}
+	return &SMTPSender{config: config}
+}
+
+// Send delivers a message to all its recipients
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	data, err := Format(s.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	if err := smtp.SendMail(addr, auth, s.config.From, msg.To, data); err != nil {
+		return fmt.Errorf("could not send email to %s: %w", strings.Join(msg.To, ", "), err)
+	}
+
+	return nil
+}
+
+// Format renders a message including its headers. The body is encoded as quoted-printable.
+func Format(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, header := range append([]string{from}, msg.To...) {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, fmt.Errorf("invalid address %q", header)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received is a message accepted by the SMTP stand-in
+type received struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer starts a minimal SMTP server on localhost accepting all messages.
+// Returns its port and a channel receiving the accepted messages.
+func startSMTPServer(t *testing.T, requireAuth bool) (int, <-chan received) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	messages := make(chan received, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, requireAuth, messages)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func serveSMTP(conn net.Conn, requireAuth bool, messages chan<- received) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	msg := received{}
+	_ = tp.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			if requireAuth {
+				_ = tp.PrintfLine("250-localhost")
+				_ = tp.PrintfLine("250 AUTH PLAIN")
+			} else {
+				_ = tp.PrintfLine("250 localhost")
+			}
+		case "AUTH":
+			msg.auth = arg
+			_ = tp.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			msg.from = arg
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, arg)
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			messages <- msg
+			msg = received{}
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	port, messages := startSMTPServer(t, false)
+
+	sender := NewSMTPSender(SMTPConfig{Host: "localhost", Port: port, From: "wiki@example.com"})
+	err := sender.Send(Message{
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "Page edited",
+		Body:    "Hello\nWorld",
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-messages:
+		assert.Empty(t, msg.auth)
+		assert.Equal(t, "FROM:<wiki@example.com>", msg.from)
+		assert.Equal(t, []string{"TO:<alice@example.com>", "TO:<bob@example.com>"}, msg.to)
+		assert.Contains(t, msg.data, "Subject: Page edited\n")
+		assert.Contains(t, msg.data, "To: alice@example.com, bob@example.com\n")
+		assert.Contains(t, msg.data, "\nHello\nWorld")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSMTPSenderAuth(t *testing.T) {
+	port, messages := startSMTPServer(t, true)
+
+	sender := NewSMTPSender(SMTPConfig{Host: "localhost", Port: port, Username: "user", Password: "secret", From: "wiki@example.com"})
+	require.NoError(t, sender.Send(Message{To: []string{"alice@example.com"}, Subject: "Test", Body: "Test"}))
+
+	select {
+	case msg := <-messages:
+		assert.True(t, strings.HasPrefix(msg.auth, "PLAIN "))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSMTPSenderUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: port, From: "wiki@example.com"})
+	err = sender.Send(Message{To: []string{"alice@example.com"}, Subject: "Test", Body: "Test"})
+	assert.ErrorContains(t, err, "alice@example.com")
+}
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := Format("wiki@example.com", Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Änderung",
+		Body:    "Zeile eins\nÄnderung " + strings.Repeat("x", 100),
+	}, date)
+	require.NoError(t, err)
+
+	header, body, found := strings.Cut(string(data), "\r\n\r\n")
+	require.True(t, found)
+	assert.Contains(t, header, "Subject: =?utf-8?q?=C3=84nderung?=\r\n")
+	assert.Contains(t, header, "Date: Wed, 01 May 2024 12:00:00 +0000\r\n")
+	assert.Contains(t, header, "Content-Transfer-Encoding: quoted-printable")
+
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Equal(t, "Zeile eins\r\nÄnderung "+strings.Repeat("x", 100), string(decoded))
+
+	// Header injection is rejected
+	_, err = Format("wiki@example.com", Message{To: []string{"alice@example.com\r\nBcc: eve@example.com"}}, date)
+	assert.Error(t, err)
+}
+
+func TestNewSMTPSenderDefaultPort(t *testing.T) {
+	sender := NewSMTPSender(SMTPConfig{Host: "localhost"})
+	assert.Equal(t, 25, sender.config.Port)
+}
@@ -0,0 +1,145 @@
+// Package markdown extracts information from Markdown documents
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inlineLinkRegex matches inline links and images: [text](destination "title")
+var inlineLinkRegex = regexp.MustCompile(`(!?)\[(?:[^\[\]]|\[[^\[\]]*\])*\]\(\s*(<[^<>\n]*>|[^\s()]*(?:\([^\s()]*\)[^\s()]*)*)(?:\s+(?:"[^"]*"|'[^']*'|\([^()]*\)))?\s*\)`)
+
+// referenceDefinitionRegex matches link reference definitions: [label]: destination "title"
+var referenceDefinitionRegex = regexp.MustCompile(`^ {0,3}\[[^\[\]]+\]:\s*(<[^<>\n]*>|\S+)`)
+
+// Links returns the destinations of all links in a Markdown document in order of appearance.
+// Images and links inside code blocks or code spans are ignored.
+func Links(content string) []string {
+	links := []string{}
+	forEachLink(content, func(start, end int) {
+		links = append(links, content[start:end])
+	})
+	return links
+}
+
+// RewriteLinks replaces the destinations of all links (as returned by Links) with the result of rewrite
+func RewriteLinks(content string, rewrite func(destination string) string) string {
+	var sb strings.Builder
+	last := 0
+	forEachLink(content, func(start, end int) {
+		sb.WriteString(content[last:start])
+		sb.WriteString(rewrite(content[start:end]))
+		last = end
+	})
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+// forEachLink calls fn with the start and end offsets of each link destination in the content
+func forEachLink(content string, fn func(start, end int)) {
+	fence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		lineOffset := offset
+		offset += len(line)
+		line = strings.TrimSuffix(line, "\n")
+		trimmed := strings.TrimLeft(line, " ")
+
+		// Skip fenced code blocks
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t\r") == "" {
+				fence = ""
+			}
+			continue
+		}
+		if len(line)-len(trimmed) <= 3 {
+			if marker := codeFence(trimmed); marker != "" {
+				fence = marker
+				continue
+			}
+		}
+
+		if m := referenceDefinitionRegex.FindStringSubmatchIndex(line); m != nil {
+			start, end := unwrapDestination(line, m[2], m[3])
+			fn(lineOffset+start, lineOffset+end)
+			continue
+		}
+
+		for _, m := range inlineLinkRegex.FindAllStringSubmatchIndex(removeCodeSpans(line), -1) {
+			if m[3] > m[2] {
+				// Image
+				continue
+			}
+			start, end := unwrapDestination(line, m[4], m[5])
+			fn(lineOffset+start, lineOffset+end)
+		}
+	}
+}
+
+// codeFence returns the opening fence if the line starts a fenced code block
+func codeFence(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			// Info strings of backtick fences must not contain backticks
+			if c == "`" && strings.Contains(line[n:], "`") {
+				return ""
+			}
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// removeCodeSpans replaces code spans with spaces
+func removeCodeSpans(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+
+	result := []byte(line)
+	i := 0
+	for i < len(line) {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+
+		// Length of the opening backtick run
+		start := i
+		for i < len(line) && line[i] == '`' {
+			i++
+		}
+		run := i - start
+
+		// Find a closing run of the same length
+		for j := i; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			k := j
+			for k < len(line) && line[k] == '`' {
+				k++
+			}
+			if k-j == run {
+				for l := start; l < k; l++ {
+					result[l] = ' '
+				}
+				i = k
+				break
+			}
+			j = k
+		}
+	}
+
+	return string(result)
+}
+
+// unwrapDestination returns the offsets of a link destination without surrounding angle brackets
+func unwrapDestination(line string, start, end int) (int, int) {
+	if end-start >= 2 && line[start] == '<' && line[end-1] == '>' {
+		return start + 1, end - 1
+	}
+	return start, end
+}
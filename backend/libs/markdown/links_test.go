@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{name: "no links", content: "Just text", expected: []string{}},
+		{name: "inline link", content: "See [other page](other) for details", expected: []string{"other"}},
+		{name: "multiple links", content: "[a](/a) and [b](../b#section)\n[c](https://example.com)", expected: []string{"/a", "../b#section", "https://example.com"}},
+		{name: "link with title", content: `[a](a "Title") [b](b 'Title') [c](c (Title))`, expected: []string{"a", "b", "c"}},
+		{name: "angle brackets", content: "[a](<some page>)", expected: []string{"some page"}},
+		{name: "parentheses in destination", content: "[a](page_(1))", expected: []string{"page_(1)"}},
+		{name: "image is ignored", content: "![alt](image.png)", expected: []string{}},
+		{name: "linked image", content: "[![alt](image.png)](page)", expected: []string{"page"}},
+		{name: "nested brackets", content: "[a [b] c](page)", expected: []string{"page"}},
+		{name: "reference definition", content: "[a][ref]\n\n[ref]: target \"Title\"", expected: []string{"target"}},
+		{name: "code span", content: "`[a](code)` and [b](link) ``[c](`code`)``", expected: []string{"link"}},
+		{name: "unclosed code span", content: "`[a](link)", expected: []string{"link"}},
+		{name: "fenced code block", content: "```md\n[a](code)\n```\n[b](link)", expected: []string{"link"}},
+		{name: "tilde fence", content: "~~~~\n[a](code)\n~~~\n[b](code)\n~~~~\n[c](link)", expected: []string{"link"}},
+		{name: "unclosed fence", content: "```\n[a](code)", expected: []string{}},
+		{name: "empty destination", content: "[a]()", expected: []string{""}},
+		{name: "not a link", content: "[a] (b) [c]", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Links(tc.content))
+		})
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	content := "# Title\n\nSee [a](old) and [b](<old page> \"Title\"), ![img](old)\n`[c](old)`\n```\n[d](old)\n```\n[ref]: old\n"
+	expected := "# Title\n\nSee [a](OLD) and [b](<OLD PAGE> \"Title\"), ![img](old)\n`[c](old)`\n```\n[d](old)\n```\n[ref]: OLD\n"
+
+	assert.Equal(t, expected, RewriteLinks(content, strings.ToUpper))
+
+	// Content without links is unchanged
+	assert.Equal(t, "no links\r\n", RewriteLinks("no links\r\n", strings.ToUpper))
+}
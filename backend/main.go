@@ -9,11 +9,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/tfabritius/plainpage/build"
+	"github.com/tfabritius/plainpage/libs/mail"
 	"github.com/tfabritius/plainpage/server"
 	"github.com/tfabritius/plainpage/service"
 )
@@ -53,12 +55,46 @@ func main() {
 
 	frontend := getStaticFrontend()
 
-	app := server.NewApp(frontend, store)
+	options := server.AppOptions{}
+	switch searchIndex := os.Getenv("SEARCH_INDEX"); searchIndex {
+	case "", "memory":
+	case "persistent":
+		options.SearchIndexDir = filepath.Join(dataDir, "index")
+	default:
+		log.Fatalln("Invalid value for SEARCH_INDEX:", searchIndex)
+	}
+
+	options.BaseURL = os.Getenv("BASE_URL")
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort := 25
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			var err error
+			if smtpPort, err = strconv.Atoi(p); err != nil {
+				log.Fatalln("Invalid value for SMTP_PORT:", p)
+			}
+		}
+		options.SMTP = &mail.SMTPConfig{
+			Host:     smtpHost,
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if options.SMTP.From == "" {
+			log.Fatalln("SMTP_FROM is required to send email notifications")
+		}
+		if options.BaseURL == "" {
+			log.Println("BASE_URL is not set, email notifications will contain relative links")
+		}
+	}
+
+	app := server.NewAppWithOptions(frontend, store, options)
 
 	// Start background schedulers
 	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
 	app.RefreshToken.StartCleanupScheduler(cleanupCtx, 24*time.Hour)
 	app.Retention.StartCleanupScheduler(cleanupCtx, 24*time.Hour)
+	app.StartDigestScheduler(cleanupCtx, time.Hour)
 
 	handler := app.GetHandler()
 
@@ -98,5 +134,12 @@ func main() {
 		log.Fatal("Failed to shutdown gracefully: ", err)
 	}
 
+	// Deliver pending email notifications
+	app.Notifications.Wait()
+
+	if err := app.Content.Close(); err != nil {
+		log.Println("Could not close search index:", err)
+	}
+
 	log.Println("Goodbye.")
 }
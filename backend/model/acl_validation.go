@@ -20,6 +20,9 @@ func validateACL(acl []AccessRule, validOps []AccessOp) error {
 		if err := validateOperations(rule.Operations, validOps); err != nil {
 			return err
 		}
+		if err := validateOperations(rule.Deny, validOps); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -34,6 +37,12 @@ func validateSubject(subject string) error {
 			return nil
 		}
 	}
+	if len(subject) > 6 && subject[:6] == "group:" {
+		groupID := subject[6:]
+		if groupID != "" {
+			return nil
+		}
+	}
 	return ErrInvalidACLSubject
 }
 
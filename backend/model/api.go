@@ -25,6 +25,25 @@ type GetAppResponse struct {
 type PutRequest struct {
 	Page   *Page   `json:"page"`
 	Folder *Folder `json:"folder"`
+
+	// Revision the page was edited from. If set, changes saved in the meantime are merged.
+	BaseRevision *int64 `json:"baseRevision,omitempty"`
+
+	// Optional description of the change and whether it is a minor edit, recorded with the new version
+	Summary string `json:"summary,omitempty"`
+	Minor   bool   `json:"minor,omitempty"`
+}
+
+// PutResponse is returned when a page was saved with a base revision
+type PutResponse struct {
+	Page   *Page `json:"page"`
+	Merged bool  `json:"merged"` // true if the page was merged with changes saved in the meantime
+}
+
+// PatchResponse is returned when content was moved with the rewriteLinks option
+type PatchResponse struct {
+	RewrittenPages []string `json:"rewrittenPages"` // Pages whose links to the moved content were updated
+	SkippedPages   []string `json:"skippedPages"`   // Readable pages with outdated links the user is not allowed to write
 }
 
 type GetContentResponse struct {
@@ -33,6 +52,28 @@ type GetContentResponse struct {
 	AllowWrite  bool         `json:"allowWrite"`
 	AllowDelete bool         `json:"allowDelete"`
 	Breadcrumbs []Breadcrumb `json:"breadcrumbs"`
+
+	// New URL of the page or folder if it was moved away from the requested URL
+	Redirect string `json:"redirect,omitempty"`
+
+	// Notification mode if the user watches the page or folder
+	Watching NotificationMode `json:"watching,omitempty"`
+}
+
+// ConflictResponse is returned when a write is rejected because the content was modified in the meantime
+type ConflictResponse struct {
+	Page      *Page           `json:"page"`
+	Folder    *Folder         `json:"folder"`
+	ETag      string          `json:"etag"`
+	Conflicts []MergeConflict `json:"conflicts,omitempty"`
+}
+
+// MergeConflict describes lines that were changed both by the client and in the meantime on the server
+type MergeConflict struct {
+	Line   int      `json:"line"` // Line number (1-based) in the base revision where the conflict starts
+	Base   []string `json:"base"`
+	Client []string `json:"client"`
+	Server []string `json:"server"`
 }
 
 type GetAtticListResponse struct {
@@ -40,6 +81,62 @@ type GetAtticListResponse struct {
 	Breadcrumbs []Breadcrumb `json:"breadcrumbs"`
 }
 
+// RevisionDiff is the difference between two versions of a page
+type RevisionDiff struct {
+	From  int64      `json:"from"`
+	To    *int64     `json:"to"` // null for the current version
+	Lines []DiffLine `json:"lines"`
+	Meta  MetaDiff   `json:"meta"`
+
+	// True if the versions differ in too many lines to be compared line by line.
+	// All lines between the unchanged lines at the start and end are shown as deleted and inserted then.
+	Simplified bool `json:"simplified,omitempty"`
+}
+
+// DiffLine is a line of the unified diff of two versions.
+// Within a changed block, deleted lines precede inserted lines.
+type DiffLine struct {
+	Type    DiffType      `json:"type"`
+	Text    string        `json:"text"`
+	OldLine int           `json:"oldLine,omitempty"` // Line number (1-based) in the old version, omitted for inserted lines
+	NewLine int           `json:"newLine,omitempty"` // Line number (1-based) in the new version, omitted for deleted lines
+	Words   []DiffSegment `json:"words,omitempty"`   // Word diff of a changed line against the corresponding line of the other version
+}
+
+// DiffSegment is a part of a changed line
+type DiffSegment struct {
+	Type DiffType `json:"type"`
+	Text string   `json:"text"`
+}
+
+type DiffType string
+
+const (
+	DiffEqual  DiffType = "equal"
+	DiffInsert DiffType = "insert"
+	DiffDelete DiffType = "delete"
+)
+
+// MetaDiff lists the changes of the metadata, unchanged fields are omitted
+type MetaDiff struct {
+	Title       *TitleChange `json:"title,omitempty"`
+	AddedTags   []string     `json:"addedTags,omitempty"`
+	RemovedTags []string     `json:"removedTags,omitempty"`
+	ACL         *ACLChange   `json:"acl,omitempty"` // Only included for admins
+}
+
+type TitleChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type ACLChange struct {
+	Old       *[]AccessRule `json:"old"`
+	New       *[]AccessRule `json:"new"`
+	OldExtend bool          `json:"oldExtend"`
+	NewExtend bool          `json:"newExtend"`
+}
+
 type PatchOperation struct {
 	Op    string           `json:"op"`
 	Path  string           `json:"path"`
@@ -47,6 +144,42 @@ type PatchOperation struct {
 	From  *string          `json:"from,omitempty"`
 }
 
+// RevertRequest restores the content and title of a page from an attic revision
+type RevertRequest struct {
+	Revision int64 `json:"rev"`
+}
+
+type RevertResponse struct {
+	Page *Page `json:"page"`
+}
+
+// SubtreeACLMode defines how the ACLs of the content of a folder are changed
+type SubtreeACLMode string
+
+const (
+	SubtreeACLApply SubtreeACLMode = "apply" // Set the ACL on the folder and all pages and folders in it
+	SubtreeACLReset SubtreeACLMode = "reset" // Let all pages and folders in the folder inherit the ACL of the folder
+)
+
+// SubtreeACLRequest changes the ACLs of all pages and folders in a folder
+type SubtreeACLRequest struct {
+	Mode      SubtreeACLMode `json:"mode"`
+	ACL       *[]AccessRule  `json:"acl"`       // ACL to apply, only for mode apply
+	ExtendACL bool           `json:"extendAcl"` // Applied ACL extends the inherited ACL, only for mode apply
+	DryRun    bool           `json:"dryRun"`    // Only return the affected content without changing it
+}
+
+type SubtreeACLResponse struct {
+	Affected []string            `json:"affected"` // URLs of the pages and folders whose ACL is (or would be) changed
+	Failed   []SubtreeACLFailure `json:"failed"`   // Pages and folders whose ACL couldn't be changed
+}
+
+// SubtreeACLFailure is a page or folder whose ACL couldn't be changed
+type SubtreeACLFailure struct {
+	Url   string `json:"url"`
+	Error string `json:"error"`
+}
+
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -77,8 +210,21 @@ type RefreshResponse struct {
 	User        User   `json:"user"`
 }
 
+// FeedTokenResponse contains a newly created feed token. It is only returned once and can't be retrieved later.
+type FeedTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// AtticEntry describes a version of a page. Details are stored in a sidecar file next to the versions.
 type AtticEntry struct {
-	Revision int64 `json:"rev"`
+	Revision              int64  `json:"rev" yaml:"rev"`
+	ModifiedByUserID      string `json:"-" yaml:"modifiedBy,omitempty"`            // Stored in YAML, not exposed in API
+	ModifiedByUsername    string `json:"modifiedByUsername,omitempty" yaml:"-"`    // Exposed in API, not stored in YAML
+	ModifiedByDisplayName string `json:"modifiedByDisplayName,omitempty" yaml:"-"` // Exposed in API, not stored in YAML
+	Summary               string `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Minor                 bool   `json:"minor,omitempty" yaml:"minor,omitempty"`
+	Size                  int64  `json:"size" yaml:"size"` // Size of the version in bytes
+	Delta                 int64  `json:"delta" yaml:"-"`   // Change in size compared to the previous version
 }
 
 // TrashEntry represents a deleted page in the trash
@@ -121,9 +267,12 @@ type Folder struct {
 }
 
 type ContentMeta struct {
+	ID                    string        `json:"id,omitempty" yaml:"id,omitempty"` // Immutable, assigned by the server
 	Title                 string        `json:"title" yaml:"title" patch:"allow"`
-	Tags                  []string      `json:"tags" yaml:"tags"`
+	Tags                  []string      `json:"tags" yaml:"tags" patch:"allow"`
 	ACL                   *[]AccessRule `json:"acl" yaml:"acl" patch:"allow"`
+	ExtendACL             bool          `json:"extendAcl" yaml:"extendAcl,omitempty" patch:"allow"` // ACL extends the inherited ACL instead of replacing it
+	RevertedTo            *int64        `json:"revertedTo,omitempty" yaml:"revertedTo,omitempty"`   // Revision whose content this version restored
 	ModifiedAt            time.Time     `json:"modifiedAt,omitempty" yaml:"modifiedAt"`
 	ModifiedByUserID      string        `json:"-" yaml:"modifiedBy"`                      // Stored in YAML, not exposed in API
 	ModifiedByUsername    string        `json:"modifiedByUsername,omitempty" yaml:"-"`    // Exposed in API, not stored in YAML
@@ -136,7 +285,8 @@ type FolderEntry struct {
 	Title    string `json:"title"`
 	IsFolder bool   `json:"isFolder"`
 
-	ACL *[]AccessRule `json:"-"`
+	ACL       *[]AccessRule `json:"-"`
+	ExtendACL bool          `json:"-"`
 }
 
 type AccessRule struct {
@@ -146,13 +296,17 @@ type AccessRule struct {
 	// - group:xyz
 	// - all (all registered users)
 	// - anonymous (unregistered users)
-	Subject string `json:"subject" yaml:"subject"`
+	Subject string `json:"subject" yaml:"subject" patch:"allow"`
 
 	// List of permitted operations
-	Operations []AccessOp `json:"ops" yaml:"ops"`
+	Operations []AccessOp `json:"ops" yaml:"ops" patch:"allow"`
+
+	// List of denied operations, see UserService.checkPermissions for the precedence
+	Deny []AccessOp `json:"deny,omitempty" yaml:"deny,omitempty" patch:"allow"`
 
 	// Additional information about subject, if applicable
-	User *User `json:"user" yaml:"-"`
+	User  *User  `json:"user" yaml:"-"`
+	Group *Group `json:"group" yaml:"-"`
 }
 
 type AccessOp string
@@ -168,6 +322,32 @@ const (
 	AccessOpRegister AccessOp = "register"
 )
 
+// PermissionSource is the ACL containing the rule that decided about an operation
+type PermissionSource string
+
+const (
+	PermissionSourcePage   PermissionSource = "page"   // ACL of the page itself
+	PermissionSourceFolder PermissionSource = "folder" // ACL of the folder itself or an ancestor folder
+	PermissionSourceAdmin  PermissionSource = "admin"  // Admin privileges in the global ACL
+	PermissionSourceNone   PermissionSource = "none"   // No rule decided, denied by default
+)
+
+// PermissionExplanation explains why an operation is allowed or denied
+type PermissionExplanation struct {
+	Op        AccessOp         `json:"op"`
+	Allowed   bool             `json:"allowed"`
+	Source    PermissionSource `json:"source"`
+	SourceUrl string           `json:"sourceUrl"` // URL of the page or folder defining the rule, if source is page or folder
+	Rule      *AccessRule      `json:"rule"`      // Deciding rule, nil if source is none
+	Inherited bool             `json:"inherited"` // Rule is defined in an ancestor folder
+}
+
+type GetPermissionsResponse struct {
+	Url        string                  `json:"url"`
+	User       *User                   `json:"user"` // nil for anonymous users
+	Operations []PermissionExplanation `json:"operations"`
+}
+
 // ValidContentOps are the allowed operations for content (page/folder) ACLs
 var ValidContentOps = []AccessOp{AccessOpRead, AccessOpWrite, AccessOpDelete}
 
@@ -175,10 +355,52 @@ var ValidContentOps = []AccessOp{AccessOpRead, AccessOpWrite, AccessOpDelete}
 var ValidConfigOps = []AccessOp{AccessOpAdmin, AccessOpRegister}
 
 type User struct {
-	ID           string `json:"id" yaml:"id"`
-	Username     string `json:"username" yaml:"username" patch:"allow"`
-	PasswordHash string `json:"-" yaml:"passwordHash"`
-	DisplayName  string `json:"displayName" yaml:"displayName" patch:"allow"`
+	ID            string         `json:"id" yaml:"id"`
+	Username      string         `json:"username" yaml:"username" patch:"allow"`
+	PasswordHash  string         `json:"-" yaml:"passwordHash"`
+	DisplayName   string         `json:"displayName" yaml:"displayName" patch:"allow"`
+	Email         string         `json:"email" yaml:"email,omitempty" patch:"allow"`
+	FeedTokenHash string         `json:"-" yaml:"feedTokenHash,omitempty"`
+	Subscriptions []Subscription `json:"-" yaml:"subscriptions,omitempty"`
+}
+
+// Group is a set of users, which can be used as subject in ACLs: group:{id}
+type Group struct {
+	ID      string   `json:"id" yaml:"id"`
+	Name    string   `json:"name" yaml:"name" patch:"allow"`
+	Members []string `json:"members" yaml:"members" patch:"allow"` // IDs of the users in the group
+}
+
+type PostGroupRequest struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// NotificationMode describes when subscribers are notified about changes
+type NotificationMode string
+
+const (
+	// NotifyImmediately sends an email for every change
+	NotifyImmediately NotificationMode = "immediate"
+	// NotifyDigest sends one email per day summarizing all changes
+	NotifyDigest NotificationMode = "digest"
+)
+
+// Subscription is a page or folder (including its subtree) watched by a user
+type Subscription struct {
+	Url  string           `json:"url" yaml:"url"`
+	Mode NotificationMode `json:"mode" yaml:"mode"`
+}
+
+type PutSubscriptionRequest struct {
+	Mode NotificationMode `json:"mode"`
+}
+
+type GetSubscriptionsResponse struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+
+	// Whether sending emails is configured
+	NotificationsEnabled bool `json:"notificationsEnabled"`
 }
 
 type Config struct {
@@ -189,10 +411,11 @@ type Config struct {
 	Retention RetentionConfig `json:"retention" yaml:"retention" patch:"allow"`
 }
 
-// RetentionConfig defines automatic cleanup policies for trash and version history
+// RetentionConfig defines automatic cleanup policies for trash, version history and the change journal
 type RetentionConfig struct {
-	Trash TrashRetention `json:"trash" yaml:"trash" patch:"allow"`
-	Attic AtticRetention `json:"attic" yaml:"attic" patch:"allow"`
+	Trash   TrashRetention   `json:"trash" yaml:"trash" patch:"allow"`
+	Attic   AtticRetention   `json:"attic" yaml:"attic" patch:"allow"`
+	Changes ChangesRetention `json:"changes" yaml:"changes" patch:"allow"`
 }
 
 // TrashRetention defines the retention policy for deleted items in trash
@@ -216,6 +439,14 @@ type AtticRetention struct {
 	MaxVersions int `json:"maxVersions" yaml:"maxVersions" patch:"allow"`
 }
 
+// ChangesRetention defines the retention policy for the journal of recent changes
+type ChangesRetention struct {
+	// MaxAgeDays specifies the maximum age in days for changes.
+	// Changes older than this will be removed from the journal.
+	// 0 means disabled (keep forever).
+	MaxAgeDays int `json:"maxAgeDays" yaml:"maxAgeDays" patch:"allow"`
+}
+
 type SearchHit struct {
 	Url          string              `json:"url"`
 	Meta         ContentMeta         `json:"meta"`
@@ -229,6 +460,145 @@ type SearchResponse struct {
 	Page    int         `json:"page"`
 	Limit   int         `json:"limit"`
 	HasMore bool        `json:"hasMore"`
+	Tags    []TagCount  `json:"tags"` // Most frequent tags among the accessible results
+
+	// True if the tags were only counted among the first results
+	TagsTruncated bool `json:"tagsTruncated,omitempty"`
+}
+
+// TagCount is the number of accessible pages and folders carrying a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type GetTagsResponse struct {
+	Tags []TagCount `json:"tags"`
+}
+
+// ContentSummary is the metadata of a page or folder
+type ContentSummary struct {
+	Url          string       `json:"url"`
+	Meta         ContentMeta  `json:"meta"`
+	IsFolder     bool         `json:"isFolder"`
+	EffectiveACL []AccessRule `json:"-"`
+}
+
+type GetTagResponse struct {
+	Tag   string           `json:"tag"`
+	Items []ContentSummary `json:"items"`
+}
+
+// LinkedContent is a page or folder linked from or to another page
+type LinkedContent struct {
+	Url      string `json:"url"`
+	Title    string `json:"title"`
+	IsFolder bool   `json:"isFolder"`
+	Exists   bool   `json:"exists"` // false for links to pages or folders that don't exist
+}
+
+type GetLinksResponse struct {
+	Outgoing  []LinkedContent `json:"outgoing"`  // Pages and folders the page links to
+	Backlinks []LinkedContent `json:"backlinks"` // Pages linking to the page or folder
+}
+
+// BrokenLink is a link to a page or folder that doesn't exist
+type BrokenLink struct {
+	Source string `json:"source"` // URL of the page containing the link
+	Target string `json:"target"` // URL of the missing page or folder
+}
+
+// LinkReport lists broken links and orphan pages
+type LinkReport struct {
+	BrokenLinks []BrokenLink    `json:"brokenLinks"`
+	Orphans     []LinkedContent `json:"orphans"` // Pages no other page links to
+}
+
+// AccessReportNode is a page or folder in the access report
+type AccessReportNode struct {
+	Url      string     `json:"url"`
+	Title    string     `json:"title"`
+	IsFolder bool       `json:"isFolder"`
+	Ops      []AccessOp `json:"ops"` // Operations allowed on the page or folder itself
+
+	// All content in the folder allows the same operations, so children are omitted
+	Uniform bool `json:"uniform,omitempty"`
+
+	// Children with any allowed operation in their subtree
+	Children []AccessReportNode `json:"children,omitempty"`
+}
+
+// AccessReport lists the content a user, group or anonymous users can access
+type AccessReport struct {
+	Subject string            `json:"subject"` // user:{id}, group:{id} or anonymous
+	User    *User             `json:"user,omitempty"`
+	Group   *Group            `json:"group,omitempty"`
+	Root    *AccessReportNode `json:"root"` // nil if nothing is accessible
+}
+
+// Redirect points from the previous URL of a moved page or folder to its new URL
+type Redirect struct {
+	From      string    `json:"from" yaml:"from"`
+	To        string    `json:"to" yaml:"to"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	Stale     bool      `json:"stale" yaml:"-"` // true if content exists at From or To doesn't exist anymore
+}
+
+type GetRedirectsResponse struct {
+	Redirects []Redirect `json:"redirects"`
+}
+
+type DeleteRedirectsRequest struct {
+	Urls []string `json:"urls"` // Source URLs of the redirects to delete
+}
+
+type PruneRedirectsResponse struct {
+	Removed int `json:"removed"`
+}
+
+// ResolveIDResponse is the current location of the page or folder with a given ID
+type ResolveIDResponse struct {
+	Url      string `json:"url"`
+	IsFolder bool   `json:"isFolder"`
+}
+
+// ChangeType is the kind of change recorded in the change journal
+type ChangeType string
+
+const (
+	ChangeCreate  ChangeType = "create"
+	ChangeEdit    ChangeType = "edit"
+	ChangeMove    ChangeType = "move"
+	ChangeDelete  ChangeType = "delete"
+	ChangeRestore ChangeType = "restore"
+
+	// Permissions of pages and folders in a folder changed, see SubtreeACLRequest
+	ChangePermissions ChangeType = "permissions"
+)
+
+// Change is an entry of the change journal
+type Change struct {
+	Type                  ChangeType    `json:"type" yaml:"type"`
+	Url                   string        `json:"url" yaml:"url"`                                     // URL after the change
+	PreviousUrl           string        `json:"previousUrl,omitempty" yaml:"previousUrl,omitempty"` // URL before a move
+	IsFolder              bool          `json:"isFolder" yaml:"isFolder,omitempty"`
+	Title                 string        `json:"title" yaml:"title"`
+	Time                  time.Time     `json:"time" yaml:"time"`
+	Revision              int64         `json:"rev,omitempty" yaml:"rev,omitempty"` // Version created by the change
+	Summary               string        `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Minor                 bool          `json:"minor,omitempty" yaml:"minor,omitempty"`
+	ModifiedByUserID      string        `json:"-" yaml:"modifiedBy,omitempty"`            // Stored in YAML, not exposed in API
+	ModifiedByUsername    string        `json:"modifiedByUsername,omitempty" yaml:"-"`    // Exposed in API, not stored in YAML
+	ModifiedByDisplayName string        `json:"modifiedByDisplayName,omitempty" yaml:"-"` // Exposed in API, not stored in YAML
+	ACL                   *[]AccessRule `json:"-" yaml:"acl,omitempty"`                   // Own ACL at the time of the change, applies if the content doesn't exist anymore
+	ExtendACL             bool          `json:"-" yaml:"extendAcl,omitempty"`
+}
+
+type GetChangesResponse struct {
+	Changes []Change `json:"changes"`
+	Page    int      `json:"page"`
+	Limit   int      `json:"limit"`
+	HasMore bool     `json:"hasMore"`
 }
 
 type GetStatsResponse struct {
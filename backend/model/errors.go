@@ -8,8 +8,13 @@ var ErrPageOrFolderExistsAlready = errors.New("page or folder exists already")
 var ErrFolderNotEmpty = errors.New("folder is not empty")
 var ErrInvalidUsername = errors.New("invalid username")
 var ErrUserExistsAlready = errors.New("user already exists")
+var ErrInvalidGroupName = errors.New("invalid group name")
+var ErrGroupExistsAlready = errors.New("group already exists")
+var ErrInvalidGroupMember = errors.New("invalid group member")
+var ErrPreconditionFailed = errors.New("precondition failed")
 var ErrDestinationExists = errors.New("destination already exists")
 var ErrCannotMoveRoot = errors.New("cannot move root folder")
 var ErrCannotDeleteRoot = errors.New("cannot delete root folder")
 var ErrInvalidACLSubject = errors.New("invalid ACL subject")
 var ErrInvalidACLOperation = errors.New("invalid ACL operation")
+var ErrInvalidSearchQuery = errors.New("invalid search query")
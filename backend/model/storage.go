@@ -9,6 +9,7 @@ type Storage interface {
 
 	ReadFile(fsPath string) ([]byte, error)
 	WriteFile(fsPath string, content []byte) error
+	CreateFile(fsPath string, content []byte) error // Fails with fs.ErrExist if the file exists already
 	DeleteFile(fsPath string) error
 
 	CreateDirectory(fsPath string) error
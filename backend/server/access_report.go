@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service"
+)
+
+// getAccessReport lists the content the user or group given by the query parameter "user" (username)
+// or "group" (ID) can access. Without both, anonymous access is reported.
+// With format=csv, all accessible pages and folders are exported as CSV instead of a tree.
+func (app App) getAccessReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	username, groupID := query.Get("user"), query.Get("group")
+
+	report := model.AccessReport{Subject: "anonymous"}
+	var allowed func(acl []model.AccessRule, op model.AccessOp) bool
+
+	switch {
+	case username != "" && groupID != "":
+		http.Error(w, "user and group are mutually exclusive", http.StatusBadRequest)
+		return
+	case username != "":
+		user, err := app.Users.GetByUsername(username)
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+		report.Subject, report.User = "user:"+user.ID, &user
+		allowed = func(acl []model.AccessRule, op model.AccessOp) bool {
+			return app.hasContentPermission(acl, user.ID, op)
+		}
+	case groupID != "":
+		group, err := app.Users.GetGroupById(groupID)
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+		report.Subject, report.Group = "group:"+group.ID, &group
+		allowed = func(acl []model.AccessRule, op model.AccessOp) bool {
+			err := app.Users.CheckGroupContentPermissions(acl, group.ID, op)
+			var e *service.AccessDeniedError
+			if err != nil && !errors.As(err, &e) {
+				panic(err)
+			}
+			return err == nil
+		}
+	default:
+		allowed = func(acl []model.AccessRule, op model.AccessOp) bool {
+			return app.hasContentPermission(acl, "", op)
+		}
+	}
+
+	rootMeta, err := app.Content.ReadFolderMeta("")
+	if err != nil {
+		panic(err)
+	}
+	rootACL := app.Content.GetEffectivePermissions(rootMeta, nil)
+
+	items := []model.AccessReportNode{}
+	report.Root = app.accessReportNode("", rootMeta.Title, true, rootACL, allowed, &items)
+
+	if query.Get("format") == "csv" {
+		writeAccessReportCSV(w, items)
+		return
+	}
+
+	render.JSON(w, r, report)
+}
+
+// accessReportNode evaluates the allowed operations on a page or folder and, recursively, on the content of the folder.
+// Returns nil if nothing in the subtree is accessible. All accessible pages and folders are appended to items.
+func (app App) accessReportNode(
+	urlPath, title string, isFolder bool,
+	acl []model.AccessRule,
+	allowed func(acl []model.AccessRule, op model.AccessOp) bool,
+	items *[]model.AccessReportNode,
+) *model.AccessReportNode {
+	node := model.AccessReportNode{Url: urlPath, Title: title, IsFolder: isFolder, Ops: []model.AccessOp{}}
+	for _, op := range model.ValidContentOps {
+		if allowed(acl, op) {
+			node.Ops = append(node.Ops, op)
+		}
+	}
+	if len(node.Ops) > 0 {
+		*items = append(*items, node)
+	}
+
+	if isFolder {
+		folder, err := app.Content.ReadFolder(urlPath)
+		if err != nil {
+			panic(err)
+		}
+
+		// Children hidden from the subject make the folder non-uniform, also if they are further down
+		uniform := true
+		for _, entry := range folder.Content {
+			entryACL := service.InheritACL(entry.ACL, entry.ExtendACL, acl)
+			child := app.accessReportNode(entry.Url, entry.Title, entry.IsFolder, entryACL, allowed, items)
+			if child == nil {
+				uniform = false
+				continue
+			}
+			if !slices.Equal(child.Ops, node.Ops) || (child.IsFolder && !child.Uniform) {
+				uniform = false
+			}
+			node.Children = append(node.Children, *child)
+		}
+
+		if uniform {
+			node.Uniform = true
+			node.Children = nil
+		}
+	}
+
+	if len(node.Ops) == 0 && len(node.Children) == 0 {
+		return nil
+	}
+
+	return &node
+}
+
+// writeAccessReportCSV writes one line per accessible page or folder
+func writeAccessReportCSV(w http.ResponseWriter, items []model.AccessReportNode) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="access-report.csv"`)
+
+	cw := csv.NewWriter(w)
+	header := []string{"url", "type", "title"}
+	for _, op := range model.ValidContentOps {
+		header = append(header, string(op))
+	}
+	if err := cw.Write(header); err != nil {
+		panic(err)
+	}
+
+	for _, item := range items {
+		itemType := "page"
+		if item.IsFolder {
+			itemType = "folder"
+		}
+		record := []string{"/" + item.Url, itemType, item.Title}
+		for _, op := range model.ValidContentOps {
+			if slices.Contains(item.Ops, op) {
+				record = append(record, "yes")
+			} else {
+				record = append(record, "no")
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			panic(err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+func (app App) getChanges(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+
+	// Parse pagination parameters
+	pageNum := 1
+	limit := 50
+
+	if p := r.URL.Query().Get("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			pageNum = parsed
+		}
+	}
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	acls := app.newChangeACLs()
+	changes, hasMore, err := app.Changes.List((pageNum-1)*limit, limit, func(change model.Change) bool {
+		return acls.canRead(change, userID)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	app.populateChangesUserInfo(changes)
+
+	response := model.GetChangesResponse{
+		Changes: changes,
+		Page:    pageNum,
+		Limit:   limit,
+		HasMore: hasMore,
+	}
+
+	render.JSON(w, r, response)
+}
+
+// changeACLs caches the effective ACLs of the content affected by changes,
+// so checking many changes reads every page or folder and its ancestors only once.
+// It's meant to be used within one request, as ACLs might change afterwards.
+type changeACLs struct {
+	app App
+
+	// effective ACLs of existing content by URL
+	existing map[string][]model.AccessRule
+
+	// ancestors of content that doesn't exist anymore by URL
+	ancestors map[string][]model.UrlAndMeta
+}
+
+func (app App) newChangeACLs() *changeACLs {
+	return &changeACLs{
+		app:       app,
+		existing:  map[string][]model.AccessRule{},
+		ancestors: map[string][]model.UrlAndMeta{},
+	}
+}
+
+// canRead checks if the user is allowed to read the content affected by a change.
+// If the content doesn't exist anymore, its own ACL at the time of the change applies,
+// otherwise the ACL inherited from the folders existing now.
+func (c *changeACLs) canRead(change model.Change, userID string) bool {
+	return c.app.hasContentPermission(c.effectiveACL(change), userID, model.AccessOpRead)
+}
+
+func (c *changeACLs) effectiveACL(change model.Change) []model.AccessRule {
+	if acl, found := c.existing[change.Url]; found {
+		return acl
+	}
+
+	meta := model.ContentMeta{ACL: change.ACL, ExtendACL: change.ExtendACL}
+	exists := false
+	if c.app.Content.IsPage(change.Url) {
+		page, err := c.app.Content.ReadPage(change.Url, nil)
+		if err != nil {
+			panic(err)
+		}
+		meta = page.Meta
+		exists = true
+	} else if c.app.Content.IsFolder(change.Url) {
+		var err error
+		if meta, err = c.app.Content.ReadFolderMeta(change.Url); err != nil {
+			panic(err)
+		}
+		exists = true
+	}
+
+	ancestors, found := c.ancestors[change.Url]
+	if !found {
+		var err error
+		if ancestors, err = c.app.Content.ReadAncestors(change.Url); err != nil {
+			panic(err)
+		}
+		c.ancestors[change.Url] = ancestors
+	}
+
+	acl := c.app.Content.GetEffectivePermissions(meta, ancestors)
+	if exists {
+		c.existing[change.Url] = acl
+	}
+
+	return acl
+}
+
+// populateChangesUserInfo populates ModifiedByUsername and ModifiedByDisplayName of changes
+func (app App) populateChangesUserInfo(changes []model.Change) {
+	users := map[string]model.ContentMeta{}
+	for i := range changes {
+		meta, found := users[changes[i].ModifiedByUserID]
+		if !found {
+			meta = model.ContentMeta{ModifiedByUserID: changes[i].ModifiedByUserID}
+			app.populateModifiedByUserInfo(&meta)
+			users[changes[i].ModifiedByUserID] = meta
+		}
+
+		changes[i].ModifiedByUsername = meta.ModifiedByUsername
+		changes[i].ModifiedByDisplayName = meta.ModifiedByDisplayName
+	}
+}
+
+// recordChange adds a change to the change journal and notifies subscribers.
+// Title and ACL are taken from the content if it exists.
+// Errors are only logged, as the change itself was successful.
+// Without its ACL, a change is not recorded, as it might be shown to users who can't read the content.
+func (app App) recordChange(change model.Change) {
+	if app.Content.IsPage(change.Url) {
+		page, err := app.Content.ReadPage(change.Url, nil)
+		if err != nil {
+			log.Printf("[changes] Could not read page %s: %v", change.Url, err)
+			return
+		}
+		change.Title = page.Meta.Title
+		change.ACL = page.Meta.ACL
+		change.ExtendACL = page.Meta.ExtendACL
+	} else if app.Content.IsFolder(change.Url) {
+		meta, err := app.Content.ReadFolderMeta(change.Url)
+		if err != nil {
+			log.Printf("[changes] Could not read folder %s: %v", change.Url, err)
+			return
+		}
+		change.IsFolder = true
+		change.Title = meta.Title
+		change.ACL = meta.ACL
+		change.ExtendACL = meta.ExtendACL
+	}
+
+	if err := app.Changes.Record(change); err != nil {
+		log.Printf("[changes] Could not record %s of %s: %v", change.Type, change.Url, err)
+	}
+
+	app.notifySubscribers(change)
+}
+
+// recordEdit adds the creation or edit of a page to the change journal,
+// with the details of the version created by it
+func (app App) recordEdit(changeType model.ChangeType, urlPath, userID string, version model.AtticEntry) {
+	app.recordChange(model.Change{
+		Type:             changeType,
+		Url:              urlPath,
+		ModifiedByUserID: userID,
+		Revision:         version.Revision,
+		Summary:          version.Summary,
+		Minor:            version.Minor,
+	})
+}
@@ -62,8 +62,13 @@ func (app App) patchConfig(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
+	// Add user info like in getConfig, so that "test" operations can use the ACL as returned there
+	if err := app.Users.EnhanceACLWithUserInfo(&cfg.ACL); err != nil {
+		panic(err)
+	}
+
 	if err := ApplyJSONPatch(&cfg, operations); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), patchErrorStatus(err))
 		return
 	}
 
@@ -77,6 +82,7 @@ func (app App) patchConfig(w http.ResponseWriter, r *http.Request) {
 	cfg.Retention.Trash.MaxAgeDays = max(cfg.Retention.Trash.MaxAgeDays, 0)
 	cfg.Retention.Attic.MaxAgeDays = max(cfg.Retention.Attic.MaxAgeDays, 0)
 	cfg.Retention.Attic.MaxVersions = max(cfg.Retention.Attic.MaxVersions, 0)
+	cfg.Retention.Changes.MaxAgeDays = max(cfg.Retention.Changes.MaxAgeDays, 0)
 
 	if err := app.Config.Write(cfg); err != nil {
 		panic(err)
@@ -3,12 +3,15 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/render"
 	"github.com/tfabritius/plainpage/model"
@@ -75,32 +78,39 @@ func (app App) getContent(w http.ResponseWriter, r *http.Request) {
 
 	response.Breadcrumbs = app.getBreadcrumbs(urlPath, page, folder, metas)
 
-	if page != nil {
-		if app.isAdmin(userID) {
-			if err := app.Users.EnhanceACLWithUserInfo(page.Meta.ACL); err != nil {
-				panic(err)
-			}
+	// Assign IDs to content created before IDs were introduced
+	if (page != nil && page.Meta.ID == "") || (folder != nil && folder.Meta.ID == "" && urlPath != "") {
+		id, err := app.Content.EnsureID(urlPath)
+		if err != nil {
+			panic(err)
+		}
+		if page != nil {
+			page.Meta.ID = id
 		} else {
-			page.Meta.ACL = nil // Hide ACL
+			folder.Meta.ID = id
 		}
+	}
 
-		// Populate user info from userId for API response
-		app.populateModifiedByUserInfo(&page.Meta)
+	if page != nil || folder != nil {
+		etag, err := app.Content.ETag(urlPath)
+		if err != nil {
+			panic(err)
+		}
+		w.Header().Set("ETag", etag)
+
+		response.Watching = app.watching(userID, urlPath)
+	}
+
+	if page != nil {
+		app.prepareMetaForResponse(&page.Meta, userID)
 
 		response.Page = page
 	} else if folder != nil {
 		// Filter folder entries based on read access
 		accessibleContent := []model.FolderEntry{}
 		for _, entry := range folder.Content {
-			// Determine the effective ACL for this entry
-			var entryEffectiveAcl []model.AccessRule
-			if entry.ACL != nil {
-				// Entry has its own ACL
-				entryEffectiveAcl = *entry.ACL
-			} else {
-				// Entry inherits from the folder's effective ACL
-				entryEffectiveAcl = effectiveAcl
-			}
+			// Determine the effective ACL for this entry, based on the folder's effective ACL
+			entryEffectiveAcl := service.InheritACL(entry.ACL, entry.ExtendACL, effectiveAcl)
 
 			// Check read permission
 			if err := app.Users.CheckContentPermissions(entryEffectiveAcl, userID, model.AccessOpRead); err == nil {
@@ -109,18 +119,13 @@ func (app App) getContent(w http.ResponseWriter, r *http.Request) {
 		}
 		folder.Content = accessibleContent
 
-		if app.isAdmin(userID) {
-			if err := app.Users.EnhanceACLWithUserInfo(folder.Meta.ACL); err != nil {
-				panic(err)
-			}
-		} else {
-			folder.Meta.ACL = nil // Hide ACL
-		}
-
-		// Populate user info from userId for API response
-		app.populateModifiedByUserInfo(&folder.Meta)
+		app.prepareMetaForResponse(&folder.Meta, userID)
 
 		response.Folder = folder
+	} else if redirect, found := app.resolveRedirect(urlPath, userID); found {
+		// Content was moved, let the client follow the redirect
+		render.JSON(w, r, model.GetContentResponse{Redirect: redirect})
+		return
 	} else {
 		// Not found
 		w.WriteHeader(http.StatusNotFound)
@@ -148,6 +153,32 @@ func (app App) getContent(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, response)
 }
 
+// resolveRedirect returns the new URL of content moved away from urlPath,
+// if the user is allowed to read the content at the new URL
+func (app App) resolveRedirect(urlPath, userID string) (string, bool) {
+	if !isValidUrl(urlPath) {
+		return "", false
+	}
+
+	target, found, err := app.Redirects.Resolve(urlPath)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		return "", false
+	}
+
+	summary, err := app.Content.ReadContentSummary(target)
+	if err != nil {
+		panic(err)
+	}
+	if !app.hasContentPermission(summary.EffectiveACL, userID, model.AccessOpRead) {
+		return "", false
+	}
+
+	return target, true
+}
+
 // PatchableContent is the wrapper for content PATCH operations
 type PatchableContent struct {
 	Page   *model.Page   `json:"page" patch:"allow"`
@@ -179,10 +210,23 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if any operation targets ACL - require admin permission
+	if !app.checkPreconditions(w, r) {
+		return
+	}
+
+	// Check if any operation targets ACL or its inheritance - require admin permission.
+	// Paths of parents like "/page/meta" include the ACL as well, e.g. in "test" operations.
+	isACLPath := func(p string) bool {
+		for _, aclPath := range []string{"/page/meta/acl", "/page/meta/extendAcl", "/folder/meta/acl", "/folder/meta/extendAcl"} {
+			if p == "" || p == aclPath || strings.HasPrefix(p, aclPath+"/") || strings.HasPrefix(aclPath, p+"/") {
+				return true
+			}
+		}
+		return false
+	}
 	aclPatched := false
 	for _, op := range operations {
-		if strings.Contains(op.Path, "/meta/acl") {
+		if isACLPath(op.Path) || (op.From != nil && isACLPath(*op.From)) {
 			aclPatched = true
 			if userID == "" {
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
@@ -199,30 +243,60 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 	// Initialize patchable struct with current values
 	patchReq := PatchableContent{}
 
+	tagsPath := "/page/meta/tags"
+
 	if isFolder {
 		patchReq.Folder = &model.Folder{
 			Url: urlPath,
 			Meta: model.ContentMeta{
-				Title: folder.Meta.Title,
-				ACL:   folder.Meta.ACL,
+				Title:     folder.Meta.Title,
+				Tags:      slices.Clone(folder.Meta.Tags),
+				ACL:       folder.Meta.ACL,
+				ExtendACL: folder.Meta.ExtendACL,
 			},
 		}
+		tagsPath = "/folder/meta/tags"
 	} else {
 		patchReq.Page = &model.Page{
 			Url: urlPath,
 			Meta: model.ContentMeta{
-				Title: page.Meta.Title,
-				ACL:   page.Meta.ACL,
+				Title:     page.Meta.Title,
+				Tags:      slices.Clone(page.Meta.Tags),
+				ACL:       page.Meta.ACL,
+				ExtendACL: page.Meta.ExtendACL,
 			},
 		}
 	}
 
-	// Apply patch operations to patchable struct
-	if err := ApplyJSONPatch(&patchReq, operations); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	// patchReq is replaced by each ApplyJSONPatch call, so the metadata is looked up each time
+	patchMeta := func() *model.ContentMeta {
+		if isFolder {
+			return &patchReq.Folder.Meta
+		}
+		return &patchReq.Page.Meta
+	}
+
+	if aclPatched {
+		// Add user info like in getContent, so that "test" operations can use the ACL as returned there
+		if err := app.Users.EnhanceACLWithUserInfo(patchMeta().ACL); err != nil {
+			panic(err)
+		}
 	}
 
+	// Apply patch operations to patchable struct.
+	// Single tags are added and removed by value, so concurrent tag changes don't overwrite each other.
+	for _, op := range operations {
+		handled, err := applyTagOperation(&patchMeta().Tags, tagsPath, op)
+		if err == nil && !handled {
+			err = ApplyJSONPatch(&patchReq, []model.PatchOperation{op})
+		}
+		if err != nil {
+			http.Error(w, err.Error(), patchErrorStatus(err))
+			return
+		}
+	}
+	patchMeta().Tags = normalizeTags(patchMeta().Tags)
+
 	urlChanged := false
 	metadataChanged := false
 
@@ -234,6 +308,11 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 			folder.Meta.Title = patchReq.Folder.Meta.Title
 		}
 
+		if !slices.Equal(patchReq.Folder.Meta.Tags, folder.Meta.Tags) {
+			metadataChanged = true
+			folder.Meta.Tags = patchReq.Folder.Meta.Tags
+		}
+
 		if aclPatched {
 			// Validate ACL only if it's not nil (nil means "inherit")
 			if patchReq.Folder.Meta.ACL != nil {
@@ -245,6 +324,7 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 
 			metadataChanged = true
 			folder.Meta.ACL = patchReq.Folder.Meta.ACL
+			folder.Meta.ExtendACL = patchReq.Folder.Meta.ExtendACL
 		}
 
 		if patchReq.Folder.Url != folder.Url {
@@ -257,6 +337,11 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 			page.Meta.Title = patchReq.Page.Meta.Title
 		}
 
+		if !slices.Equal(patchReq.Page.Meta.Tags, page.Meta.Tags) {
+			metadataChanged = true
+			page.Meta.Tags = patchReq.Page.Meta.Tags
+		}
+
 		if aclPatched {
 			// Validate ACL only if it's not nil (nil means "inherit")
 			if patchReq.Page.Meta.ACL != nil {
@@ -268,6 +353,7 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 
 			metadataChanged = true
 			page.Meta.ACL = patchReq.Page.Meta.ACL
+			page.Meta.ExtendACL = patchReq.Page.Meta.ExtendACL
 		}
 
 		if patchReq.Page.Url != page.Url {
@@ -277,35 +363,113 @@ func (app App) patchContent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Apply url change if requested
+	sourceUrl := urlPath
 	if urlChanged {
-		if err := app.moveContent(w, urlPath, newUrl, userID, isFolder); err != nil {
+		if err := app.moveContent(w, r, urlPath, newUrl, userID, isFolder); err != nil {
 			return // Error already written to response
 		}
 		urlPath = newUrl
 	}
 
-	// Save metadata changes (ACL, etc.) only if changed
+	// Save metadata changes (ACL, etc.) only if changed.
+	// The precondition has been checked before moving, and moving doesn't change the ETag,
+	// so it still applies at the new URL.
 	if metadataChanged {
 		var err error
 		if isFolder {
-			err = app.Content.SaveFolder(urlPath, folder.Meta)
+			err = app.Content.SaveFolder(urlPath, folder.Meta, requestPrecondition(r))
 		} else {
-			// Metadata-only changes (ACL, title) should not create a new version
-			err = app.Content.SavePageWithoutVersion(urlPath, page.Content, page.Meta, userID)
+			// Metadata-only changes (ACL, title, tags) should not create a new version
+			err = app.Content.SavePageWithoutVersion(urlPath, page.Content, page.Meta, userID, requestPrecondition(r))
+		}
+
+		if errors.Is(err, model.ErrPreconditionFailed) {
+			app.preconditionFailed(w, r, urlPath)
+			return
+		}
+		if err != nil {
+			panic(err)
 		}
+	}
 
+	// Update links to the moved content if requested
+	if urlChanged && r.URL.Query().Get("rewriteLinks") == "true" {
+		rewritten, skipped, err := app.Content.RewriteLinksAfterMove(sourceUrl, urlPath, userID,
+			func(c model.ContentSummary) bool {
+				return app.hasContentPermission(c.EffectiveACL, userID, model.AccessOpWrite)
+			})
 		if err != nil {
 			panic(err)
 		}
+		response := model.PatchResponse{RewrittenPages: []string{}, SkippedPages: []string{}}
+		for _, page := range rewritten {
+			app.recordEdit(model.ChangeEdit, page.Url, userID, page.Version)
+			response.RewrittenPages = append(response.RewrittenPages, page.Url)
+		}
+
+		// Pages the user can't read are not revealed
+		for _, page := range skipped {
+			if app.hasContentPermission(page.EffectiveACL, userID, model.AccessOpRead) {
+				response.SkippedPages = append(response.SkippedPages, page.Url)
+			}
+		}
+
+		render.JSON(w, r, response)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// applyTagOperation applies the operations adding or removing a single tag by value,
+// which aren't part of RFC 6902:
+//   - {"op": "addTag", "path": "<tagsPath>", "value": "tag"} adds the tag unless it's already present
+//   - {"op": "removeTag", "path": "<tagsPath>", "value": "tag"} removes the tag if present
+//
+// Returns false if op is not such an operation.
+func applyTagOperation(tags *[]string, tagsPath string, op model.PatchOperation) (bool, error) {
+	if op.Op != "addTag" && op.Op != "removeTag" {
+		return false, nil
+	}
+	if op.Path != tagsPath {
+		return true, fmt.Errorf("error at %s: %s is only supported at %s", op.Path, op.Op, tagsPath)
+	}
+
+	var tag string
+	if op.Value == nil || json.Unmarshal(*op.Value, &tag) != nil {
+		return true, fmt.Errorf("error at %s: tag must be a string", op.Path)
+	}
+	tag = strings.TrimSpace(tag)
+
+	if op.Op == "addTag" {
+		if tag == "" {
+			return true, fmt.Errorf("error at %s: tag must not be empty", op.Path)
+		}
+		if !slices.Contains(*tags, tag) {
+			*tags = append(*tags, tag)
+		}
+	} else {
+		*tags = slices.DeleteFunc(*tags, func(t string) bool { return t == tag })
+	}
+	return true, nil
+}
+
+// normalizeTags trims tags and removes empty and duplicate ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
 // moveContent handles moving/renaming a page or folder.
 // Returns the new urlPath and any error.
 // If an error occurs, the HTTP response is already written and the returned error is non-nil.
-func (app App) moveContent(w http.ResponseWriter, urlPath, destinationPath, userID string, isFolder bool) error {
+func (app App) moveContent(w http.ResponseWriter, r *http.Request, urlPath, destinationPath, userID string, isFolder bool) error {
 	// Validate url format
 	if !isValidUrl(destinationPath) {
 		http.Error(w, "invalid url format", http.StatusBadRequest)
@@ -377,16 +541,19 @@ func (app App) moveContent(w http.ResponseWriter, urlPath, destinationPath, user
 		panic(err)
 	}
 
-	// Perform the move
+	// Perform the move, if the page or folder still matches If-Match
 	var moveErr error
 	if isFolder {
-		moveErr = app.Content.MoveFolder(urlPath, destinationPath)
+		moveErr = app.Content.MoveFolder(urlPath, destinationPath, requestPrecondition(r))
 	} else {
-		moveErr = app.Content.MovePage(urlPath, destinationPath)
+		moveErr = app.Content.MovePage(urlPath, destinationPath, requestPrecondition(r))
 	}
 
 	if moveErr != nil {
-		if errors.Is(moveErr, model.ErrNotFound) {
+		if errors.Is(moveErr, model.ErrPreconditionFailed) {
+			app.preconditionFailed(w, r, urlPath)
+			return moveErr
+		} else if errors.Is(moveErr, model.ErrNotFound) {
 			http.Error(w, moveErr.Error(), http.StatusNotFound)
 			return moveErr
 		} else if errors.Is(moveErr, model.ErrParentFolderNotFound) {
@@ -402,6 +569,23 @@ func (app App) moveContent(w http.ResponseWriter, urlPath, destinationPath, user
 		panic(moveErr)
 	}
 
+	// Keep the old URL working
+	if err := app.Redirects.Add(urlPath, destinationPath); err != nil {
+		panic(err)
+	}
+
+	if err := app.Users.MoveSubscriptions(urlPath, destinationPath); err != nil {
+		panic(err)
+	}
+
+	app.recordChange(model.Change{
+		Type:             model.ChangeMove,
+		Url:              destinationPath,
+		PreviousUrl:      urlPath,
+		IsFolder:         isFolder,
+		ModifiedByUserID: userID,
+	})
+
 	return nil
 }
 
@@ -423,14 +607,37 @@ func (app App) putContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	merged := false
+	precondition := requestPrecondition(r)
+	if body.Page != nil && page != nil && body.BaseRevision != nil {
+		// Instead of rejecting outdated changes, try to merge them
+		if met, _ := app.evaluatePreconditions(r); !met || r.Header.Get("If-Match") == "" {
+			// The merge result is only saved if the page wasn't changed again in the meantime
+			etag, err := app.Content.ETag(urlPath)
+			if err != nil {
+				panic(err)
+			}
+			precondition = func(current string) bool { return current == etag }
+
+			var ok bool
+			if merged, ok = app.mergeConcurrentChanges(w, r, body.Page, *body.BaseRevision); !ok {
+				return
+			}
+		}
+	} else if !app.checkPreconditions(w, r) {
+		return
+	}
+
 	var err error
+	var version model.AtticEntry
 	if body.Page != nil {
 		if page != nil {
 			// if page exists already, take over ACL
 			body.Page.Meta.ACL = page.Meta.ACL
+			body.Page.Meta.ExtendACL = page.Meta.ExtendACL
 		}
 
-		err = app.Content.SavePage(urlPath, body.Page.Content, body.Page.Meta, userID)
+		version, err = app.Content.SavePageWithSummary(urlPath, body.Page.Content, body.Page.Meta, userID, body.Summary, body.Minor, precondition)
 	} else if body.Folder != nil {
 		if folder != nil {
 			http.Error(w, "folder already exists", http.StatusBadRequest)
@@ -439,6 +646,7 @@ func (app App) putContent(w http.ResponseWriter, r *http.Request) {
 
 		// make sure ACLs are not set
 		body.Folder.Meta.ACL = nil
+		body.Folder.Meta.ExtendACL = false
 
 		// and create
 		err = app.Content.CreateFolder(urlPath, body.Folder.Meta)
@@ -448,7 +656,10 @@ func (app App) putContent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		if errors.Is(err, model.ErrParentFolderNotFound) {
+		if errors.Is(err, model.ErrPreconditionFailed) {
+			app.preconditionFailed(w, r, urlPath)
+			return
+		} else if errors.Is(err, model.ErrParentFolderNotFound) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		} else if errors.Is(err, model.ErrPageOrFolderExistsAlready) {
@@ -458,12 +669,76 @@ func (app App) putContent(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
+	if body.Page != nil {
+		changeType := model.ChangeEdit
+		if page == nil {
+			changeType = model.ChangeCreate
+		}
+		app.recordEdit(changeType, urlPath, userID, version)
+	} else {
+		app.recordChange(model.Change{Type: model.ChangeCreate, Url: urlPath, ModifiedByUserID: userID})
+	}
+
+	if body.Page != nil && body.BaseRevision != nil {
+		saved, err := app.Content.ReadPage(urlPath, nil)
+		if err != nil {
+			panic(err)
+		}
+		app.prepareMetaForResponse(&saved.Meta, userID)
+
+		render.JSON(w, r, model.PutResponse{Page: &saved, Merged: merged})
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// mergeConcurrentChanges merges the changes in the submitted page with the changes saved since baseRevision.
+// Returns whether the submitted page was modified by the merge.
+// If the merge fails, a 409 response containing the conflicts is written and ok is false.
+func (app App) mergeConcurrentChanges(w http.ResponseWriter, r *http.Request, changed *model.Page, baseRevision int64) (merged bool, ok bool) {
+	urlPath := r.PathValue("*")
+	userID := ctxutil.UserID(r.Context())
+
+	result, conflicts, err := app.Content.MergePage(urlPath, baseRevision, *changed)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, "base revision not found", http.StatusBadRequest)
+			return false, false
+		}
+		panic(err)
+	}
+
+	if len(conflicts) > 0 {
+		etag, err := app.Content.ETag(urlPath)
+		if err != nil {
+			panic(err)
+		}
+
+		current := *ctxutil.Page(r.Context())
+		app.prepareMetaForResponse(&current.Meta, userID)
+
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusConflict)
+		render.JSON(w, r, model.ConflictResponse{Page: &current, ETag: etag, Conflicts: conflicts})
+		return false, false
+	}
+
+	merged = result.Content != changed.Content ||
+		result.Meta.Title != changed.Meta.Title ||
+		!slices.Equal(result.Meta.Tags, changed.Meta.Tags)
+
+	changed.Content = result.Content
+	changed.Meta.Title = result.Meta.Title
+	changed.Meta.Tags = result.Meta.Tags
+
+	return merged, true
+}
+
 func (app App) deleteContent(w http.ResponseWriter, r *http.Request) {
 	urlPath := r.PathValue("*")
 
+	userID := ctxutil.UserID(r.Context())
 	page := ctxutil.Page(r.Context())
 	folder := ctxutil.Folder(r.Context())
 
@@ -473,11 +748,14 @@ func (app App) deleteContent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var err error
+	change := model.Change{Type: model.ChangeDelete, Url: urlPath, ModifiedByUserID: userID}
 	if page != nil {
 		err = app.Content.DeletePage(urlPath)
+		change.Title, change.ACL, change.ExtendACL = page.Meta.Title, page.Meta.ACL, page.Meta.ExtendACL
 
 	} else if folder != nil {
 		err = app.Content.DeleteFolder(urlPath)
+		change.IsFolder, change.Title, change.ACL, change.ExtendACL = true, folder.Meta.Title, folder.Meta.ACL, folder.Meta.ExtendACL
 
 	} else {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
@@ -491,10 +769,17 @@ func (app App) deleteContent(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
+	app.recordChange(change)
+
 	w.WriteHeader(http.StatusOK)
 }
 
 func (app App) getAttic(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("action") == "diff" {
+		app.getAtticDiff(w, r)
+		return
+	}
+
 	urlPath := r.PathValue("*")
 	queryRev := r.URL.Query().Get("rev")
 
@@ -514,6 +799,7 @@ func (app App) getAttic(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			panic(err)
 		}
+		app.populateAtticUserInfo(list)
 
 		response := model.GetAtticListResponse{
 			Entries:     list,
@@ -546,10 +832,231 @@ func (app App) getAttic(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getAtticDiff returns the difference between the revision given by the query parameter from
+// and the revision given by to, or the current version if to is omitted
+func (app App) getAtticDiff(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.PathValue("*")
+	userID := ctxutil.UserID(r.Context())
+	page := ctxutil.Page(r.Context())
+
+	if !isValidUrl(urlPath) || page == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid query parameter: from", http.StatusBadRequest)
+		return
+	}
+
+	var to *int64
+	if queryTo := r.URL.Query().Get("to"); queryTo != "" {
+		rev, err := strconv.ParseInt(queryTo, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid query parameter: to", http.StatusBadRequest)
+			return
+		}
+		to = &rev
+	}
+
+	revisionDiff, err := app.Content.DiffRevisions(urlPath, from, to)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		panic(err)
+	}
+
+	// ACL changes are only visible to admins
+	if aclChange := revisionDiff.Meta.ACL; aclChange != nil {
+		if app.isAdmin(userID) {
+			for _, acl := range []*[]model.AccessRule{aclChange.Old, aclChange.New} {
+				if err := app.Users.EnhanceACLWithUserInfo(acl); err != nil {
+					panic(err)
+				}
+			}
+		} else {
+			revisionDiff.Meta.ACL = nil
+		}
+	}
+
+	render.JSON(w, r, revisionDiff)
+}
+
+// postContentAction handles actions on content posted to /pages/{url}/{action}
+func (app App) postContentAction(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("action") {
+	case "revert":
+		app.revertContent(w, r)
+	case "acl":
+		app.setSubtreeACL(w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+}
+
+// revertContent restores the content and title of a page from an attic revision
+func (app App) revertContent(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.PathValue("*")
+
+	userID := ctxutil.UserID(r.Context())
+	page := ctxutil.Page(r.Context())
+
+	if !isValidUrl(urlPath) || page == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var body model.RevertRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !app.checkPreconditions(w, r) {
+		return
+	}
+
+	reverted, version, err := app.Content.RevertPage(urlPath, body.Revision, userID, requestPrecondition(r))
+	if err != nil {
+		if errors.Is(err, model.ErrPreconditionFailed) {
+			app.preconditionFailed(w, r, urlPath)
+			return
+		}
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		panic(err)
+	}
+
+	app.recordEdit(model.ChangeEdit, urlPath, userID, version)
+
+	etag, err := app.Content.ETag(urlPath)
+	if err != nil {
+		panic(err)
+	}
+	app.prepareMetaForResponse(&reverted.Meta, userID)
+
+	w.Header().Set("ETag", etag)
+	render.JSON(w, r, model.RevertResponse{Page: &reverted})
+}
+
+// setSubtreeACL applies the ACL of a folder to all its content, or lets all its content inherit the ACL of the folder.
+// Like patching ACLs, it requires admin permission.
+func (app App) setSubtreeACL(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.PathValue("*")
+
+	userID := ctxutil.UserID(r.Context())
+	folder := ctxutil.Folder(r.Context())
+
+	if !isValidUrl(urlPath) || folder == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if userID == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if !app.isAdmin(userID) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var body model.SubtreeACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch body.Mode {
+	case model.SubtreeACLApply:
+		if body.ACL == nil {
+			http.Error(w, "acl is required", http.StatusBadRequest)
+			return
+		}
+		if err := model.ValidateContentACL(*body.ACL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The home folder cannot inherit permissions
+		if urlPath == "" {
+			body.ExtendACL = false
+		}
+	case model.SubtreeACLReset:
+	default:
+		http.Error(w, "invalid mode", http.StatusBadRequest)
+		return
+	}
+
+	if !body.DryRun && !app.checkPreconditions(w, r) {
+		return
+	}
+
+	affected, failed, err := app.Content.SetSubtreeACL(urlPath, body.Mode, body.ACL, body.ExtendACL, userID, body.DryRun, requestPrecondition(r))
+	if errors.Is(err, model.ErrPreconditionFailed) {
+		app.preconditionFailed(w, r, urlPath)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if !body.DryRun {
+		app.recordSubtreeACLChanges(affected, userID)
+	}
+
+	render.JSON(w, r, model.SubtreeACLResponse{Affected: affected, Failed: failed})
+}
+
+// recordSubtreeACLChanges adds one change per folder to the change journal,
+// covering the changed folder itself and the changed pages in it
+func (app App) recordSubtreeACLChanges(affected []string, userID string) {
+	folders := []string{}
+	counts := map[string]int{}
+	for _, url := range affected {
+		folder := url
+		if !app.Content.IsFolder(url) {
+			folder = strings.TrimPrefix(path.Dir("/"+url), "/")
+		}
+		if counts[folder] == 0 {
+			folders = append(folders, folder)
+		}
+		counts[folder]++
+	}
+
+	for _, folder := range folders {
+		app.recordChange(model.Change{
+			Type:             model.ChangePermissions,
+			Url:              folder,
+			ModifiedByUserID: userID,
+			Summary:          fmt.Sprintf("Changed permissions of %d pages and folders", counts[folder]),
+		})
+	}
+}
+
 func (app App) searchContent(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("q")
 	userID := ctxutil.UserID(r.Context())
 
+	filter, err := parseSearchFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := service.BuildSearchQuery(q, filter, app.resolveUsername)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidSearchQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
 	// Parse pagination parameters
 	page := 1
 	limit := 20
@@ -568,26 +1075,28 @@ func (app App) searchContent(w http.ResponseWriter, r *http.Request) {
 	skip := (page - 1) * limit
 	need := limit
 
-	// Iteratively fetch from Bleve until we have enough accessible results
+	// Admins can read everything, so their tags can be counted by the index.
+	// For other users, the tags of the readable results among the first maxTagFacetHits hits are counted.
+	isAdmin := app.isAdmin(userID)
+	facetItems := []model.ContentSummary{}
+
+	// Iteratively fetch from Bleve until we have enough accessible results and all hits for the tags
 	const batchSize = 100
 
 	accessibleResults := []model.SearchHit{}
 	skipped := 0
 	bleveOffset := 0
-	bleveExhausted := false
-	stoppedEarly := false // Set to true if we break out of the inner loop with results remaining
+	hasMore := false // Set to true if there are accessible results after the current page
+	var totalHits uint64
 
-	for len(accessibleResults) < need && !bleveExhausted {
-		results, totalHits, err := app.Content.SearchWithPagination(q, bleveOffset, batchSize)
+	for {
+		var results []model.SearchHit
+		results, totalHits, err = app.Content.SearchWithPagination(query, bleveOffset, batchSize)
 		if err != nil {
 			panic(err)
 		}
 
-		// Check if Bleve has more results beyond this batch
-		noMoreBatches := len(results) == 0 || bleveOffset+len(results) >= int(totalHits)
-
 		// Filter each result by access control
-		processedAll := true
 		for i, result := range results {
 			if err := app.Users.CheckContentPermissions(result.EffectiveACL, userID, model.AccessOpRead); err != nil {
 				var e *service.AccessDeniedError
@@ -598,44 +1107,105 @@ func (app App) searchContent(w http.ResponseWriter, r *http.Request) {
 				panic(err)
 			}
 
-			// User has access to this result
+			if !isAdmin && bleveOffset+i < maxTagFacetHits {
+				facetItems = append(facetItems, model.ContentSummary{Url: result.Url, Meta: result.Meta})
+			}
+
 			if skipped < skip {
 				// This result belongs to a previous page
 				skipped++
-			} else {
+			} else if len(accessibleResults) < need {
 				// This result belongs to the current page
 				result.Meta.ACL = nil // Hide ACL
 				app.populateModifiedByUserInfo(&result.Meta)
 				accessibleResults = append(accessibleResults, result)
-
-				if len(accessibleResults) >= need {
-					// We have enough for this page
-					// Check if there are more results we haven't processed
-					if i < len(results)-1 || !noMoreBatches {
-						stoppedEarly = true
-					}
-					processedAll = false
-					break
-				}
+			} else {
+				hasMore = true
 			}
 		}
 
-		if processedAll && noMoreBatches {
-			bleveExhausted = true
-		}
-
 		bleveOffset += batchSize
+
+		bleveExhausted := len(results) == 0 || bleveOffset >= int(totalHits)
+		facetsComplete := isAdmin || bleveOffset >= maxTagFacetHits
+		if bleveExhausted || (hasMore && facetsComplete) {
+			break
+		}
 	}
 
-	// hasMore is true if we stopped early (didn't check all results)
-	hasMore := stoppedEarly
+	var tags []model.TagCount
+	tagsTruncated := false
+	if isAdmin {
+		tags, err = app.Content.TagFacets(query, maxTagFacets)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		tags = countTags(facetItems)
+		if len(tags) > maxTagFacets {
+			tags = tags[:maxTagFacets]
+		}
+		tagsTruncated = totalHits > maxTagFacetHits
+	}
 
 	response := model.SearchResponse{
-		Items:   accessibleResults,
-		Page:    page,
-		Limit:   limit,
-		HasMore: hasMore,
+		Items:         accessibleResults,
+		Page:          page,
+		Limit:         limit,
+		HasMore:       hasMore,
+		Tags:          tags,
+		TagsTruncated: tagsTruncated,
 	}
 
 	render.JSON(w, r, response)
 }
+
+// parseSearchFilter reads the search filter from the query parameters
+// folder, tags (comma-separated or repeated), modifiedAfter, modifiedBefore and modifiedBy
+func parseSearchFilter(r *http.Request) (service.SearchFilter, error) {
+	params := r.URL.Query()
+
+	filter := service.SearchFilter{
+		Folder:     params.Get("folder"),
+		ModifiedBy: params.Get("modifiedBy"),
+	}
+
+	if !isValidUrl(strings.Trim(filter.Folder, "/")) {
+		return filter, errors.New("invalid folder")
+	}
+
+	for _, tags := range params["tags"] {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	var err error
+	if value := params.Get("modifiedAfter"); value != "" {
+		if filter.ModifiedAfter, err = parseSearchDate(value, false); err != nil {
+			return filter, errors.New("invalid modifiedAfter, expected YYYY-MM-DD or RFC 3339 timestamp")
+		}
+	}
+	if value := params.Get("modifiedBefore"); value != "" {
+		if filter.ModifiedBefore, err = parseSearchDate(value, true); err != nil {
+			return filter, errors.New("invalid modifiedBefore, expected YYYY-MM-DD or RFC 3339 timestamp")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseSearchDate parses a date (YYYY-MM-DD, UTC) or an RFC 3339 timestamp.
+// For dates, endOfDay selects the last instead of the first moment of the day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/tfabritius/plainpage/libs/feed"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+const (
+	// feedSize is the number of changes included in a feed
+	feedSize = 50
+	// feedSnippetLines is the maximum number of changed lines shown in the diff snippet of an entry
+	feedSnippetLines = 20
+)
+
+// FeedTokenMiddleware authenticates requests by the feed token in the query parameter "token",
+// as feed readers can't send access tokens. Requests without token are anonymous.
+func (app App) FeedTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" {
+			user, err := app.Users.GetByFeedToken(token)
+			if errors.Is(err, model.ErrNotFound) {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				panic(err)
+			}
+
+			r = r.WithContext(ctxutil.WithUserID(r.Context(), user.ID))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app App) getFeed(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+	urlPath := r.PathValue("*")
+	format := r.PathValue("format")
+
+	folder := ctxutil.Folder(r.Context())
+	if folder == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	acls := app.newChangeACLs()
+	changes, _, err := app.Changes.List(0, feedSize, func(change model.Change) bool {
+		return (inSubtree(change.Url, urlPath) || (change.PreviousUrl != "" && inSubtree(change.PreviousUrl, urlPath))) &&
+			acls.canRead(change, userID)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	app.populateChangesUserInfo(changes)
+
+	cfg, err := app.Config.Read()
+	if err != nil {
+		panic(err)
+	}
+
+	base := app.baseURL(r)
+	f := feed.Feed{
+		Title:   cfg.AppTitle,
+		Link:    base + "/" + urlPath,
+		FeedURL: base + r.URL.Path,
+	}
+	if urlPath != "" {
+		f.Title = cfg.AppTitle + ": " + folder.Meta.Title
+	}
+	if len(changes) > 0 {
+		f.Updated = changes[0].Time
+	}
+
+	for _, change := range changes {
+		f.Entries = append(f.Entries, app.feedEntry(change, base))
+	}
+
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = feed.WriteRSS(w, f)
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = feed.WriteAtom(w, f)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// inSubtree checks if urlPath is the folder folderPath or contained in it. The root folder contains everything.
+func inSubtree(urlPath, folderPath string) bool {
+	return folderPath == "" || urlPath == folderPath || strings.HasPrefix(urlPath, folderPath+"/")
+}
+
+// baseURL returns the public URL of the app if configured,
+// otherwise the URL of the app as requested by the client
+func (app App) baseURL(r *http.Request) string {
+	if app.BaseURL != "" {
+		return app.BaseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedEntry converts a change to an entry of a feed, including a snippet of the changed lines
+func (app App) feedEntry(change model.Change, base string) feed.Entry {
+	entry := describeChange(change, base)
+
+	var content strings.Builder
+	if entry.Summary != "" {
+		content.WriteString("<p>" + html.EscapeString(entry.Summary) + "</p>")
+	}
+	if snippet := app.changeSnippet(change); len(snippet) > 0 {
+		content.WriteString("<pre>" + html.EscapeString(strings.Join(snippet, "\n")) + "</pre>")
+	}
+	entry.Content = content.String()
+
+	return entry
+}
+
+// describeChange returns title, link, author and summary of a change, without content
+func describeChange(change model.Change, base string) feed.Entry {
+	title := change.Title
+	if title == "" {
+		title = change.Url
+	}
+
+	entry := feed.Entry{
+		ID:      fmt.Sprintf("%s/%s#change-%d", base, change.Url, change.Time.UnixMilli()),
+		Link:    base + "/" + change.Url,
+		Author:  change.ModifiedByDisplayName,
+		Updated: change.Time,
+		Summary: change.Summary,
+	}
+
+	switch change.Type {
+	case model.ChangeCreate:
+		entry.Title = "Created: " + title
+	case model.ChangeEdit:
+		entry.Title = "Edited: " + title
+		if change.Revision != 0 {
+			entry.Link = fmt.Sprintf("%s/%s?rev=%d", base, change.Url, change.Revision)
+		}
+	case model.ChangeMove:
+		entry.Title = "Moved: " + title
+		if entry.Summary == "" {
+			entry.Summary = fmt.Sprintf("Moved from %s to %s", change.PreviousUrl, change.Url)
+		}
+	case model.ChangeDelete:
+		entry.Title = "Deleted: " + title
+		entry.Link = base + "/_changes"
+	case model.ChangeRestore:
+		entry.Title = "Restored: " + title
+	case model.ChangePermissions:
+		entry.Title = "Permissions changed: " + title
+	default:
+		entry.Title = title
+	}
+
+	return entry
+}
+
+// changeSnippet returns the lines changed by the creation or edit of a page, prefixed by "+" or "-".
+// Returns nothing if the version doesn't exist anymore, e.g. because the page was moved or the version was pruned.
+func (app App) changeSnippet(change model.Change) []string {
+	if change.IsFolder || change.Revision == 0 ||
+		(change.Type != model.ChangeCreate && change.Type != model.ChangeEdit) ||
+		!app.Content.IsAtticPage(change.Url, change.Revision) {
+		return nil
+	}
+
+	entries, err := app.Content.ListAttic(change.Url)
+	if err != nil {
+		panic(err)
+	}
+
+	// Find the version preceding the one created by the change
+	var previous *int64
+	for i := range entries {
+		if entries[i].Revision == change.Revision {
+			if i > 0 {
+				previous = &entries[i-1].Revision
+			}
+			break
+		}
+	}
+
+	lines := []string{}
+	if previous == nil {
+		page, err := app.Content.ReadPage(change.Url, &change.Revision)
+		if err != nil {
+			panic(err)
+		}
+		if page.Content != "" {
+			for _, line := range strings.Split(page.Content, "\n") {
+				lines = append(lines, "+ "+line)
+			}
+		}
+	} else {
+		revisionDiff, err := app.Content.DiffRevisions(change.Url, *previous, &change.Revision)
+		if errors.Is(err, model.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		gap := false
+		for _, line := range revisionDiff.Lines {
+			switch line.Type {
+			case model.DiffEqual:
+				gap = len(lines) > 0
+				continue
+			case model.DiffDelete:
+				line.Text = "- " + line.Text
+			case model.DiffInsert:
+				line.Text = "+ " + line.Text
+			}
+			if gap {
+				lines = append(lines, "…")
+				gap = false
+			}
+			lines = append(lines, line.Text)
+		}
+	}
+
+	if len(lines) > feedSnippetLines {
+		lines = append(lines[:feedSnippetLines], "…")
+	}
+
+	return lines
+}
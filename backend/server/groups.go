@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func (app App) getGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := app.Users.ReadAllGroups()
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, groups)
+}
+
+func (app App) getGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	group, err := app.Users.GetGroupById(id)
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, group)
+}
+
+// groupErrorStatus returns the HTTP status code for validation errors of groups, or 0 for other errors
+func groupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalidGroupName), errors.Is(err, model.ErrInvalidGroupMember):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrGroupExistsAlready):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound
+	}
+	return 0
+}
+
+func (app App) postGroup(w http.ResponseWriter, r *http.Request) {
+	var body model.PostGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group, err := app.Users.CreateGroup(body.Name, body.Members)
+	if err != nil {
+		if status := groupErrorStatus(err); status != 0 {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		panic(err)
+	}
+
+	render.JSON(w, r, group)
+}
+
+func (app App) patchGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	group, err := app.Users.GetGroupById(id)
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	var operations []model.PatchOperation
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ApplyJSONPatch(&group, operations); err != nil {
+		http.Error(w, err.Error(), patchErrorStatus(err))
+		return
+	}
+
+	if err := app.Users.SaveGroup(group); err != nil {
+		if status := groupErrorStatus(err); status != 0 {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app App) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := app.Users.DeleteGroup(id)
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
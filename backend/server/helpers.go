@@ -5,7 +5,9 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/go-chi/render"
 	"github.com/tfabritius/plainpage/model"
 	"github.com/tfabritius/plainpage/service"
 	"github.com/tfabritius/plainpage/service/ctxutil"
@@ -88,6 +90,35 @@ func (app App) RetrieveContentMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// SplitContentActionMiddleware splits requests to /pages/{url}/{action} into the URL of the content
+// and the action, which is available as path value "action"
+func SplitContentActionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := r.PathValue("*")
+
+		i := strings.LastIndex(urlPath, "/")
+		r.SetPathValue("*", urlPath[:max(i, 0)])
+		r.SetPathValue("action", urlPath[i+1:])
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SplitAtticDiffMiddleware splits requests to /attic/{url}/diff?from=... into the URL of the page
+// and the action "diff". Without the from parameter, the URL is kept, as pages may be named "diff".
+func SplitAtticDiffMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("from") {
+			if urlPath, found := strings.CutSuffix("/"+r.PathValue("*"), "/diff"); found {
+				r.SetPathValue("*", strings.TrimPrefix(urlPath, "/"))
+				r.SetPathValue("action", "diff")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app App) RequireContentPermission(op model.AccessOp, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := ctxutil.UserID(r.Context())
@@ -107,6 +138,108 @@ func (app App) RequireContentPermission(op model.AccessOp, next http.Handler) ht
 	})
 }
 
+// checkPreconditions evaluates the If-Match and If-None-Match headers against the current version of the content.
+// If a precondition fails, a 412 response containing the current version is written and false is returned.
+// As the content may change after this check, changes must be saved with requestPrecondition as well.
+func (app App) checkPreconditions(w http.ResponseWriter, r *http.Request) bool {
+	if met, _ := app.evaluatePreconditions(r); met {
+		return true
+	}
+
+	app.preconditionFailed(w, r, r.PathValue("*"))
+	return false
+}
+
+// preconditionFailed writes a 412 response containing the current version of the content
+func (app App) preconditionFailed(w http.ResponseWriter, r *http.Request, urlPath string) {
+	userID := ctxutil.UserID(r.Context())
+
+	response := model.ConflictResponse{}
+	if app.Content.IsPage(urlPath) {
+		current, err := app.Content.ReadPage(urlPath, nil)
+		if err != nil {
+			panic(err)
+		}
+		app.prepareMetaForResponse(&current.Meta, userID)
+		response.Page = &current
+	} else if app.Content.IsFolder(urlPath) {
+		meta, err := app.Content.ReadFolderMeta(urlPath)
+		if err != nil {
+			panic(err)
+		}
+		current := model.Folder{Url: urlPath, Meta: meta}
+		app.prepareMetaForResponse(&current.Meta, userID)
+		response.Folder = &current
+	}
+
+	if response.Page != nil || response.Folder != nil {
+		etag, err := app.Content.ETag(urlPath)
+		if err != nil {
+			panic(err)
+		}
+		response.ETag = etag
+		w.Header().Set("ETag", etag)
+	}
+
+	w.WriteHeader(http.StatusPreconditionFailed)
+	render.JSON(w, r, response)
+}
+
+// requestPrecondition returns the If-Match and If-None-Match headers as precondition for saving content,
+// which is evaluated while the content is locked. Returns nil if there are no such headers.
+func requestPrecondition(r *http.Request) service.Precondition {
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return nil
+	}
+
+	return func(etag string) bool {
+		return (ifMatch == "" || matchesETag(ifMatch, etag)) &&
+			(ifNoneMatch == "" || !matchesETag(ifNoneMatch, etag))
+	}
+}
+
+// evaluatePreconditions checks the If-Match and If-None-Match headers against the current version of the content.
+// Returns whether the preconditions are met and the current ETag (empty if the content doesn't exist).
+func (app App) evaluatePreconditions(r *http.Request) (bool, string) {
+	precondition := requestPrecondition(r)
+	if precondition == nil {
+		return true, ""
+	}
+
+	urlPath := r.PathValue("*")
+	page := ctxutil.Page(r.Context())
+	folder := ctxutil.Folder(r.Context())
+
+	etag := ""
+	if page != nil || folder != nil {
+		var err error
+		etag, err = app.Content.ETag(urlPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return precondition(etag), etag
+}
+
+// matchesETag checks if an If-Match or If-None-Match header value matches the given ETag.
+// An empty etag means that the content doesn't exist, which is never matched.
+func matchesETag(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // isAdmin checks if the user has admin privileges. Panics on errors.
 func (app App) isAdmin(userID string) bool {
 	err := app.Users.CheckAppPermissions(userID, model.AccessOpAdmin)
@@ -122,6 +255,19 @@ func (app App) isAdmin(userID string) bool {
 	return true
 }
 
+// hasContentPermission checks if the user is allowed to perform the operation on content
+// with the given effective ACL. Panics on errors.
+func (app App) hasContentPermission(effectiveACL []model.AccessRule, userID string, op model.AccessOp) bool {
+	if err := app.Users.CheckContentPermissions(effectiveACL, userID, op); err != nil {
+		var e *service.AccessDeniedError
+		if errors.As(err, &e) {
+			return false
+		}
+		panic(err)
+	}
+	return true
+}
+
 func clientIPFromRequest(r *http.Request) string {
 	ip := r.RemoteAddr
 
@@ -164,6 +310,20 @@ func (app App) SearchRateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// prepareMetaForResponse hides the ACL from non-admins (or enhances it with user info for admins)
+// and populates the user info of the last modification
+func (app App) prepareMetaForResponse(meta *model.ContentMeta, userID string) {
+	if app.isAdmin(userID) {
+		if err := app.Users.EnhanceACLWithUserInfo(meta.ACL); err != nil {
+			panic(err)
+		}
+	} else {
+		meta.ACL = nil // Hide ACL
+	}
+
+	app.populateModifiedByUserInfo(meta)
+}
+
 // populateModifiedByUserInfo populates ModifiedByUsername and ModifiedByDisplayName from ModifiedByUserID
 func (app App) populateModifiedByUserInfo(meta *model.ContentMeta) {
 	if meta.ModifiedByUserID == "" {
@@ -183,3 +343,31 @@ func (app App) populateModifiedByUserInfo(meta *model.ContentMeta) {
 	meta.ModifiedByUsername = user.Username
 	meta.ModifiedByDisplayName = user.DisplayName
 }
+
+// populateAtticUserInfo populates ModifiedByUsername and ModifiedByDisplayName of attic entries
+func (app App) populateAtticUserInfo(entries []model.AtticEntry) {
+	users := map[string]model.ContentMeta{}
+	for i := range entries {
+		meta, found := users[entries[i].ModifiedByUserID]
+		if !found {
+			meta = model.ContentMeta{ModifiedByUserID: entries[i].ModifiedByUserID}
+			app.populateModifiedByUserInfo(&meta)
+			users[entries[i].ModifiedByUserID] = meta
+		}
+
+		entries[i].ModifiedByUsername = meta.ModifiedByUsername
+		entries[i].ModifiedByDisplayName = meta.ModifiedByDisplayName
+	}
+}
+
+// resolveUsername returns the ID of the user with the given username
+func (app App) resolveUsername(username string) (string, bool) {
+	user, err := app.Users.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return "", false
+		}
+		panic(err)
+	}
+	return user.ID, true
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+// resolveID returns the current URL of the page or folder with the given ID
+func (app App) resolveID(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+	id := chi.URLParam(r, "id")
+
+	item, err := app.Content.ResolveID(id)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		panic(err)
+	}
+
+	if err := app.Users.CheckContentPermissions(item.EffectiveACL, userID, model.AccessOpRead); err != nil {
+		var e *service.AccessDeniedError
+		if errors.As(err, &e) {
+			http.Error(w, http.StatusText(e.StatusCode), e.StatusCode)
+			return
+		}
+		panic(err)
+	}
+
+	render.JSON(w, r, model.ResolveIDResponse{Url: item.Url, IsFolder: item.IsFolder})
+}
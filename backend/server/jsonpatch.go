@@ -1,112 +1,410 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/tfabritius/plainpage/model"
 )
 
+// ErrPatchTestFailed is returned if a "test" operation doesn't match the current value
+var ErrPatchTestFailed = errors.New("test failed")
+
 // ApplyJSONPatch applies RFC 6902 patch operations to a struct using reflection.
 // It uses json tags to map paths like "/retention/trash/maxAgeDays" to struct fields.
-// Only the "replace" operation is supported.
+// The operations "add", "remove", "replace", "move", "copy" and "test" are supported.
+// Array elements are addressed by index, "-" refers to the end of an array.
 // Fields with json:"-" tag are protected and cannot be patched.
 // Only fields with patch:"allow" tag can be patched.
 // Nil values are allowed and will set the field to its JSON null value.
+// The patch is applied atomically: if any operation fails, the target is left unchanged.
 func ApplyJSONPatch[T any](target *T, operations []model.PatchOperation) error {
+	result := deepCopy(reflect.ValueOf(target).Elem())
+
 	for _, op := range operations {
-		if op.Op != "replace" {
-			return fmt.Errorf("operation %s not supported", op.Op)
+		if err := applyPatchOperation(result, op); err != nil {
+			return fmt.Errorf("error at %s: %w", op.Path, err)
 		}
+	}
 
-		// Use "null" JSON value if op.Value is nil
-		var rawValue json.RawMessage
-		if op.Value == nil {
-			rawValue = json.RawMessage("null")
-		} else {
-			rawValue = *op.Value
+	reflect.ValueOf(target).Elem().Set(result)
+	return nil
+}
+
+// patchErrorStatus returns the HTTP status code for an error returned by ApplyJSONPatch
+func patchErrorStatus(err error) int {
+	if errors.Is(err, ErrPatchTestFailed) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// applyPatchOperation applies a single patch operation to root, which must be addressable
+func applyPatchOperation(root reflect.Value, op model.PatchOperation) error {
+	// Use "null" JSON value if op.Value is nil
+	var rawValue json.RawMessage
+	if op.Value == nil {
+		rawValue = json.RawMessage("null")
+	} else {
+		rawValue = *op.Value
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		// Parent of the target location; nil pointers are only initialized when writing
+		parent, key, err := resolveJSONPath(root, op.Path, op.Op != "test")
+		if err != nil {
+			return err
 		}
 
-		if err := setFieldByJSONPath(target, op.Path, rawValue); err != nil {
-			return fmt.Errorf("error at %s: %w", op.Path, err)
+		switch op.Op {
+		case "add":
+			return addValue(parent, key, rawValue)
+		case "replace":
+			return replaceValue(parent, key, rawValue)
+		default:
+			return testValue(parent, key, rawValue)
+		}
+
+	case "remove":
+		parent, key, err := resolveJSONPath(root, op.Path, false)
+		if err != nil {
+			return err
+		}
+		return removeValue(parent, key)
+
+	case "move", "copy":
+		if op.From == nil {
+			return fmt.Errorf("missing from")
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, *op.From+"/") {
+			return fmt.Errorf("cannot move into own child")
+		}
+
+		fromParent, fromKey, err := resolveJSONPath(root, *op.From, false)
+		if err != nil {
+			return fmt.Errorf("from %s: %w", *op.From, err)
+		}
+		value, err := getValue(fromParent, fromKey)
+		if err != nil {
+			return fmt.Errorf("from %s: %w", *op.From, err)
+		}
+
+		// The value is copied through its JSON representation, just like a client would
+		valueJSON, err := json.Marshal(value.Interface())
+		if err != nil {
+			return err
+		}
+
+		if op.Op == "move" {
+			if op.Path == *op.From {
+				return nil
+			}
+			if err := removeValue(fromParent, fromKey); err != nil {
+				return fmt.Errorf("from %s: %w", *op.From, err)
+			}
+		}
+
+		parent, key, err := resolveJSONPath(root, op.Path, true)
+		if err != nil {
+			return err
 		}
+		return addValue(parent, key, valueJSON)
+
+	default:
+		return fmt.Errorf("operation %s not supported", op.Op)
 	}
-	return nil
 }
 
-// setFieldByJSONPath navigates the struct using json tags and sets the value.
-// It automatically initializes nil pointers along the path.
-func setFieldByJSONPath(target any, path string, value json.RawMessage) error {
+// resolveJSONPath navigates the struct along a JSON pointer using json tags.
+// It returns the value containing the target location (a struct or slice) and the last path segment.
+// If create is true, nil pointers along the path are initialized.
+func resolveJSONPath(root reflect.Value, path string, create bool) (reflect.Value, string, error) {
 	if !strings.HasPrefix(path, "/") {
-		return fmt.Errorf("path must start with /")
+		return reflect.Value{}, "", fmt.Errorf("path must start with /")
 	}
 
 	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
-	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
-		return fmt.Errorf("empty path")
+	if len(parts) == 1 && parts[0] == "" {
+		return reflect.Value{}, "", fmt.Errorf("empty path")
+	}
+	for i := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(parts[i])
 	}
 
-	v := reflect.ValueOf(target)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
+	v := root
+	for _, part := range parts[:len(parts)-1] {
+		var err error
+		if v, err = derefPointer(v, create); err != nil {
+			return reflect.Value{}, "", err
+		}
+
+		if v, err = childValue(v, part); err != nil {
+			return reflect.Value{}, "", err
+		}
 	}
 
-	// Navigate to the nested field
-	for i, part := range parts {
-		if v.Kind() != reflect.Struct {
-			return fmt.Errorf("cannot navigate into non-struct at %s", part)
+	v, err := derefPointer(v, create)
+	if err != nil {
+		return reflect.Value{}, "", err
+	}
+	if v.Kind() != reflect.Struct && v.Kind() != reflect.Slice {
+		return reflect.Value{}, "", fmt.Errorf("cannot navigate into non-struct at %s", parts[len(parts)-1])
+	}
+
+	return v, parts[len(parts)-1], nil
+}
+
+// derefPointer follows pointers. Nil pointers are initialized if create is true.
+func derefPointer(v reflect.Value, create bool) (reflect.Value, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !create {
+				return reflect.Value{}, fmt.Errorf("path not found")
+			}
+			if !v.CanSet() {
+				return reflect.Value{}, fmt.Errorf("cannot initialize nil pointer")
+			}
+			v.Set(reflect.New(v.Type().Elem()))
 		}
+		v = v.Elem()
+	}
+	return v, nil
+}
 
+// childValue returns the struct field or slice element addressed by a path segment
+func childValue(v reflect.Value, part string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Struct:
 		field, found := findFieldByJSONTag(v.Type(), part)
 		if !found {
-			return fmt.Errorf("path not supported")
+			return reflect.Value{}, fmt.Errorf("path not supported")
 		}
+		return v.FieldByIndex(field.Index), nil
 
-		v = v.FieldByIndex(field.Index)
+	case reflect.Slice:
+		index, err := parseArrayIndex(part, v.Len()-1)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Index(index), nil
 
-		// Last part - set the value
-		if i == len(parts)-1 {
-			if !v.CanSet() {
-				return fmt.Errorf("cannot set field")
-			}
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot navigate into non-struct at %s", part)
+	}
+}
 
-			// Check if trying to set null on a non-nullable field
-			if string(value) == "null" {
-				kind := v.Type().Kind()
-				// Allow null only for pointer, slice, map, interface types and custom types that implement json.Unmarshaler
-				if kind != reflect.Ptr && kind != reflect.Slice && kind != reflect.Map && kind != reflect.Interface {
-					// Check if the type implements json.Unmarshaler
-					if !reflect.PointerTo(v.Type()).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
-						return fmt.Errorf("cannot set null on non-nullable field")
-					}
-				}
+// parseArrayIndex parses an array index between 0 and maxIndex
+func parseArrayIndex(part string, maxIndex int) (int, error) {
+	index, err := strconv.Atoi(part)
+	if err != nil || index < 0 || (len(part) > 1 && part[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %s", part)
+	}
+	if index > maxIndex {
+		return 0, fmt.Errorf("array index %s out of bounds", part)
+	}
+	return index, nil
+}
+
+// getValue returns the value at key in parent
+func getValue(parent reflect.Value, key string) (reflect.Value, error) {
+	if parent.Kind() == reflect.Slice && key == "-" {
+		return reflect.Value{}, fmt.Errorf("path not found")
+	}
+	return childValue(parent, key)
+}
+
+// addValue adds a value at key in parent. Struct fields are replaced, array elements are inserted.
+func addValue(parent reflect.Value, key string, rawValue json.RawMessage) error {
+	if parent.Kind() == reflect.Struct {
+		field, err := childValue(parent, key)
+		if err != nil {
+			return err
+		}
+		if !field.CanSet() {
+			return fmt.Errorf("cannot set field")
+		}
+		value, err := decodeValue(field.Type(), rawValue)
+		if err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+
+	index := parent.Len()
+	if key != "-" {
+		var err error
+		if index, err = parseArrayIndex(key, parent.Len()); err != nil {
+			return err
+		}
+	}
+
+	value, err := decodeValue(parent.Type().Elem(), rawValue)
+	if err != nil {
+		return err
+	}
+
+	// Build a new slice instead of modifying the existing backing array
+	result := reflect.MakeSlice(parent.Type(), 0, parent.Len()+1)
+	result = reflect.AppendSlice(result, parent.Slice(0, index))
+	result = reflect.Append(result, value)
+	result = reflect.AppendSlice(result, parent.Slice(index, parent.Len()))
+	parent.Set(result)
+	return nil
+}
+
+// replaceValue replaces the existing value at key in parent
+func replaceValue(parent reflect.Value, key string, rawValue json.RawMessage) error {
+	target, err := getValue(parent, key)
+	if err != nil {
+		return err
+	}
+	if !target.CanSet() {
+		return fmt.Errorf("cannot set field")
+	}
+
+	value, err := decodeValue(target.Type(), rawValue)
+	if err != nil {
+		return err
+	}
+	target.Set(value)
+	return nil
+}
+
+// removeValue removes the value at key in parent. Struct fields are set to their JSON null value.
+func removeValue(parent reflect.Value, key string) error {
+	if parent.Kind() == reflect.Struct {
+		field, err := childValue(parent, key)
+		if err != nil {
+			return err
+		}
+		if !field.CanSet() {
+			return fmt.Errorf("cannot set field")
+		}
+		if !isNullable(field.Type()) {
+			return fmt.Errorf("cannot remove non-nullable field")
+		}
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if key == "-" {
+		return fmt.Errorf("path not found")
+	}
+	index, err := parseArrayIndex(key, parent.Len()-1)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(parent.Type(), 0, parent.Len()-1)
+	result = reflect.AppendSlice(result, parent.Slice(0, index))
+	result = reflect.AppendSlice(result, parent.Slice(index+1, parent.Len()))
+	parent.Set(result)
+	return nil
+}
+
+// testValue checks that the value at key in parent equals the given JSON value
+func testValue(parent reflect.Value, key string, rawValue json.RawMessage) error {
+	current, err := getValue(parent, key)
+	if err != nil {
+		return err
+	}
+
+	expected, err := decodeValue(current.Type(), rawValue)
+	if err != nil {
+		return err
+	}
+
+	// Compare the JSON representations, so that e.g. nil and empty slices are distinguished like in the API
+	currentJSON, err := json.Marshal(current.Interface())
+	if err != nil {
+		return err
+	}
+	expectedJSON, err := json.Marshal(expected.Interface())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(currentJSON, expectedJSON) {
+		return ErrPatchTestFailed
+	}
+	return nil
+}
+
+// decodeValue unmarshals a JSON value into a new value of the given type
+func decodeValue(t reflect.Type, rawValue json.RawMessage) (reflect.Value, error) {
+	// Check if trying to set null on a non-nullable field
+	if string(rawValue) == "null" && !isNullable(t) {
+		return reflect.Value{}, fmt.Errorf("cannot set null on non-nullable field")
+	}
+
+	newVal := reflect.New(t)
+	if err := json.Unmarshal(rawValue, newVal.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid value: %w", err)
+	}
+	return newVal.Elem(), nil
+}
+
+// isNullable checks if null is a valid value for the type.
+// This is the case for pointer, slice, map, interface types and custom types that implement json.Unmarshaler.
+func isNullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+	return reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem())
+}
+
+// deepCopy returns an addressable copy of v that doesn't share pointers, slices or maps with v.
+// Unexported struct fields are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	result := reflect.New(v.Type()).Elem()
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			elem := deepCopy(v.Elem())
+			ptr := reflect.New(elem.Type())
+			ptr.Elem().Set(elem)
+			result.Set(ptr)
+		}
+
+	case reflect.Slice:
+		if !v.IsNil() {
+			result.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				result.Index(i).Set(deepCopy(v.Index(i)))
 			}
+		}
 
-			// Create new value and unmarshal
-			newVal := reflect.New(v.Type())
-			if err := json.Unmarshal(value, newVal.Interface()); err != nil {
-				return fmt.Errorf("invalid value: %w", err)
+	case reflect.Map:
+		if !v.IsNil() {
+			result.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+			iter := v.MapRange()
+			for iter.Next() {
+				result.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
 			}
-			v.Set(newVal.Elem())
-			return nil
-		}
-
-		// Navigate into pointer if needed, initializing nil pointers
-		if v.Kind() == reflect.Ptr {
-			if v.IsNil() {
-				// Initialize the nil pointer with a new value
-				if !v.CanSet() {
-					return fmt.Errorf("cannot initialize nil pointer at %s", part)
-				}
-				v.Set(reflect.New(v.Type().Elem()))
+		}
+
+	case reflect.Struct:
+		result.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				result.Field(i).Set(deepCopy(v.Field(i)))
 			}
-			v = v.Elem()
 		}
+
+	default:
+		result.Set(v)
 	}
 
-	return fmt.Errorf("path not found")
+	return result
 }
 
 // findFieldByJSONTag finds a struct field by its json tag name.
@@ -218,22 +218,23 @@ func TestApplyJSONPatch_Error_UnsupportedOperation(t *testing.T) {
 	target := simpleStruct{}
 
 	err := ApplyJSONPatch(&target, []model.PatchOperation{
-		{Op: "add", Path: "/name", Value: rawJSON(`"test"`)},
+		{Op: "merge", Path: "/name", Value: rawJSON(`"test"`)},
 	})
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "operation add not supported")
+	assert.Contains(t, err.Error(), "operation merge not supported")
 }
 
-func TestApplyJSONPatch_Error_UnsupportedOperation_Remove(t *testing.T) {
-	target := simpleStruct{}
+func TestApplyJSONPatch_Error_RemoveNonNullable(t *testing.T) {
+	target := simpleStruct{Name: "test"}
 
 	err := ApplyJSONPatch(&target, []model.PatchOperation{
 		{Op: "remove", Path: "/name"},
 	})
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "operation remove not supported")
+	assert.Contains(t, err.Error(), "cannot remove non-nullable field")
+	assert.Equal(t, "test", target.Name)
 }
 
 func TestApplyJSONPatch_Error_PathNotStartingWithSlash(t *testing.T) {
@@ -360,18 +361,32 @@ func TestApplyJSONPatch_Error_NavigateIntoNonStruct(t *testing.T) {
 	assert.Contains(t, err.Error(), "cannot navigate into non-struct")
 }
 
-func TestApplyJSONPatch_StopsOnFirstError(t *testing.T) {
+func TestApplyJSONPatch_AtomicOnError(t *testing.T) {
 	target := simpleStruct{Name: "original", Value: 10}
 
 	err := ApplyJSONPatch(&target, []model.PatchOperation{
 		{Op: "replace", Path: "/name", Value: rawJSON(`"updated"`)},
-		{Op: "add", Path: "/value", Value: rawJSON(`20`)}, // This should fail
+		{Op: "replace", Path: "/value", Value: rawJSON(`"twenty"`)}, // This should fail
 		{Op: "replace", Path: "/value", Value: rawJSON(`30`)},
 	})
 
 	require.Error(t, err)
-	assert.Equal(t, "updated", target.Name) // First op succeeded
-	assert.Equal(t, 10, target.Value)       // Second op failed, value unchanged
+	assert.Equal(t, "original", target.Name) // First op is rolled back
+	assert.Equal(t, 10, target.Value)
+}
+
+func TestApplyJSONPatch_AtomicOnError_NestedPointerAndSlice(t *testing.T) {
+	target := sliceOfStructs{Items: []simpleStruct{{Name: "a"}}, Pointer: &simpleStruct{Name: "b"}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "replace", Path: "/items/0/name", Value: rawJSON(`"changed"`)},
+		{Op: "replace", Path: "/pointer/name", Value: rawJSON(`"changed"`)},
+		{Op: "test", Path: "/pointer/value", Value: rawJSON(`1`)}, // This should fail
+	})
+
+	require.ErrorIs(t, err, ErrPatchTestFailed)
+	assert.Equal(t, "a", target.Items[0].Name)
+	assert.Equal(t, "b", target.Pointer.Name)
 }
 
 // Test with json tag options (omitempty, etc.)
@@ -442,3 +457,244 @@ func TestApplyJSONPatch_ErrorIncludesNestedPath(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "/nested/unknown")
 }
+
+// RFC 6902 operations
+
+type sliceOfStructs struct {
+	Items   []simpleStruct `json:"items" patch:"allow"`
+	Tags    []string       `json:"tags" patch:"allow"`
+	Pointer *simpleStruct  `json:"pointer" patch:"allow"`
+	Other   []string       `json:"other"`
+}
+
+func TestApplyJSONPatch_Add(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		value    string
+		expected []string
+	}{
+		{"append", "/tags/-", `"d"`, []string{"a", "b", "c", "d"}},
+		{"insert at beginning", "/tags/0", `"d"`, []string{"d", "a", "b", "c"}},
+		{"insert in middle", "/tags/1", `"d"`, []string{"a", "d", "b", "c"}},
+		{"insert at end", "/tags/3", `"d"`, []string{"a", "b", "c", "d"}},
+		{"replace whole array", "/tags", `["x"]`, []string{"x"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			original := []string{"a", "b", "c"}
+			target := sliceOfStructs{Tags: original}
+
+			err := ApplyJSONPatch(&target, []model.PatchOperation{
+				{Op: "add", Path: tc.path, Value: rawJSON(tc.value)},
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, target.Tags)
+			assert.Equal(t, []string{"a", "b", "c"}, original) // Original slice is not modified
+		})
+	}
+}
+
+func TestApplyJSONPatch_Add_ToNilSlice(t *testing.T) {
+	target := sliceOfStructs{}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "add", Path: "/tags/-", Value: rawJSON(`"a"`)},
+		{Op: "add", Path: "/items/0", Value: rawJSON(`{"name": "x", "value": 1}`)},
+		{Op: "add", Path: "/pointer/name", Value: rawJSON(`"p"`)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, target.Tags)
+	assert.Equal(t, []simpleStruct{{Name: "x", Value: 1}}, target.Items)
+	assert.Equal(t, "p", target.Pointer.Name)
+}
+
+func TestApplyJSONPatch_Remove(t *testing.T) {
+	target := sliceOfStructs{
+		Tags:    []string{"a", "b", "c"},
+		Items:   []simpleStruct{{Name: "x"}, {Name: "y"}},
+		Pointer: &simpleStruct{Name: "p"},
+	}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "remove", Path: "/tags/1"},
+		{Op: "remove", Path: "/items/0"},
+		{Op: "remove", Path: "/pointer"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, target.Tags)
+	assert.Equal(t, []simpleStruct{{Name: "y"}}, target.Items)
+	assert.Nil(t, target.Pointer)
+}
+
+func TestApplyJSONPatch_ReplaceArrayElement(t *testing.T) {
+	target := sliceOfStructs{Tags: []string{"a", "b"}, Items: []simpleStruct{{Name: "x", Value: 1}}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "replace", Path: "/tags/1", Value: rawJSON(`"c"`)},
+		{Op: "replace", Path: "/items/0/value", Value: rawJSON(`2`)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, target.Tags)
+	assert.Equal(t, []simpleStruct{{Name: "x", Value: 2}}, target.Items)
+}
+
+func TestApplyJSONPatch_Move(t *testing.T) {
+	target := sliceOfStructs{Tags: []string{"a", "b", "c"}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "move", From: strPtr("/tags/0"), Path: "/tags/-"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "a"}, target.Tags)
+}
+
+func TestApplyJSONPatch_Move_BetweenFields(t *testing.T) {
+	target := nestedStruct{Title: "title", Nested: simpleStruct{Name: "name"}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "move", From: strPtr("/nested/name"), Path: "/title"},
+	})
+
+	// Moving requires removing the source, which is not possible for non-nullable fields
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot remove non-nullable field")
+}
+
+func TestApplyJSONPatch_Copy(t *testing.T) {
+	target := sliceOfStructs{Items: []simpleStruct{{Name: "x", Value: 1}}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "copy", From: strPtr("/items/0"), Path: "/items/-"},
+		{Op: "replace", Path: "/items/1/name", Value: rawJSON(`"y"`)},
+		{Op: "copy", From: strPtr("/items/0"), Path: "/pointer"},
+		{Op: "copy", From: strPtr("/items/1/name"), Path: "/tags/-"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []simpleStruct{{Name: "x", Value: 1}, {Name: "y", Value: 1}}, target.Items)
+	assert.Equal(t, &simpleStruct{Name: "x", Value: 1}, target.Pointer)
+	assert.Equal(t, []string{"y"}, target.Tags)
+}
+
+func TestApplyJSONPatch_Test(t *testing.T) {
+	target := sliceOfStructs{Tags: []string{"a", "b"}, Items: []simpleStruct{{Name: "x", Value: 1}}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "test", Path: "/tags", Value: rawJSON(`["a", "b"]`)},
+		{Op: "test", Path: "/tags/1", Value: rawJSON(`"b"`)},
+		{Op: "test", Path: "/items/0", Value: rawJSON(`{"value": 1, "name": "x"}`)},
+		{Op: "test", Path: "/pointer", Value: rawJSON(`null`)},
+		{Op: "replace", Path: "/tags/0", Value: rawJSON(`"c"`)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, target.Tags)
+}
+
+func TestApplyJSONPatch_Test_Failed(t *testing.T) {
+	target := sliceOfStructs{Tags: []string{"a", "b"}}
+
+	for _, op := range []model.PatchOperation{
+		{Op: "test", Path: "/tags", Value: rawJSON(`["b", "a"]`)},
+		{Op: "test", Path: "/tags/0", Value: rawJSON(`"b"`)},
+		{Op: "test", Path: "/items", Value: rawJSON(`[]`)}, // nil is not an empty array
+	} {
+		err := ApplyJSONPatch(&target, []model.PatchOperation{op})
+		require.ErrorIs(t, err, ErrPatchTestFailed, op.Path)
+	}
+}
+
+func TestApplyJSONPatch_Error_ArrayIndex(t *testing.T) {
+	for _, op := range []model.PatchOperation{
+		{Op: "add", Path: "/tags/3", Value: rawJSON(`"x"`)},
+		{Op: "add", Path: "/tags/-1", Value: rawJSON(`"x"`)},
+		{Op: "add", Path: "/tags/01", Value: rawJSON(`"x"`)},
+		{Op: "replace", Path: "/tags/2", Value: rawJSON(`"x"`)},
+		{Op: "replace", Path: "/tags/-", Value: rawJSON(`"x"`)},
+		{Op: "remove", Path: "/tags/2"},
+		{Op: "remove", Path: "/tags/-"},
+		{Op: "test", Path: "/tags/x", Value: rawJSON(`"x"`)},
+		{Op: "move", From: strPtr("/tags/5"), Path: "/tags/0"},
+	} {
+		target := sliceOfStructs{Tags: []string{"a", "b"}}
+		err := ApplyJSONPatch(&target, []model.PatchOperation{op})
+		require.Error(t, err, op.Op+" "+op.Path)
+		assert.Equal(t, []string{"a", "b"}, target.Tags)
+	}
+}
+
+func TestApplyJSONPatch_Error_FieldWithoutPatchTag_AllOperations(t *testing.T) {
+	for _, op := range []model.PatchOperation{
+		{Op: "add", Path: "/other/-", Value: rawJSON(`"x"`)},
+		{Op: "remove", Path: "/other"},
+		{Op: "test", Path: "/other", Value: rawJSON(`null`)},
+		{Op: "copy", From: strPtr("/other"), Path: "/tags"},
+		{Op: "move", From: strPtr("/tags"), Path: "/other"},
+	} {
+		target := sliceOfStructs{Tags: []string{"a"}}
+		err := ApplyJSONPatch(&target, []model.PatchOperation{op})
+		require.Error(t, err, op.Op)
+		assert.Contains(t, err.Error(), "path not supported")
+	}
+}
+
+func TestApplyJSONPatch_Error_MissingFrom(t *testing.T) {
+	target := sliceOfStructs{}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "copy", Path: "/tags"},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing from")
+}
+
+func TestApplyJSONPatch_Error_MoveIntoChild(t *testing.T) {
+	target := sliceOfStructs{Items: []simpleStruct{{Name: "x"}}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "move", From: strPtr("/items"), Path: "/items/0"},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot move into own child")
+}
+
+func TestApplyJSONPatch_Error_CopyTypeMismatch(t *testing.T) {
+	target := sliceOfStructs{Items: []simpleStruct{{Name: "x"}}}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "copy", From: strPtr("/items/0"), Path: "/tags/-"},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid value")
+}
+
+func TestApplyJSONPatch_EscapedPath(t *testing.T) {
+	target := escapedStruct{}
+
+	err := ApplyJSONPatch(&target, []model.PatchOperation{
+		{Op: "replace", Path: "/a~1b", Value: rawJSON(`"slash"`)},
+		{Op: "replace", Path: "/c~0d", Value: rawJSON(`"tilde"`)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "slash", target.Slash)
+	assert.Equal(t, "tilde", target.Tilde)
+}
+
+type escapedStruct struct {
+	Slash string `json:"a/b" patch:"allow"`
+	Tilde string `json:"c~d" patch:"allow"`
+}
+
+func strPtr(s string) *string {
+	return &s
+}
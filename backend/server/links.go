@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+func (app App) getLinks(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.PathValue("*")
+	userID := ctxutil.UserID(r.Context())
+
+	if ctxutil.Page(r.Context()) == nil && ctxutil.Folder(r.Context()) == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	response := model.GetLinksResponse{
+		Outgoing:  []model.LinkedContent{},
+		Backlinks: []model.LinkedContent{},
+	}
+
+	targets, err := app.Content.OutgoingLinks(urlPath)
+	if err != nil {
+		panic(err)
+	}
+	for _, target := range targets {
+		summary, err := app.Content.ReadContentSummary(target)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				response.Outgoing = append(response.Outgoing, model.LinkedContent{Url: target})
+				continue
+			}
+			panic(err)
+		}
+
+		if !app.hasContentPermission(summary.EffectiveACL, userID, model.AccessOpRead) {
+			continue
+		}
+		response.Outgoing = append(response.Outgoing, newLinkedContent(summary))
+	}
+
+	backlinks, err := app.Content.Backlinks(urlPath)
+	if err != nil {
+		panic(err)
+	}
+	for _, source := range app.filterReadable(backlinks, userID) {
+		response.Backlinks = append(response.Backlinks, newLinkedContent(source))
+	}
+
+	render.JSON(w, r, response)
+}
+
+func newLinkedContent(summary model.ContentSummary) model.LinkedContent {
+	return model.LinkedContent{
+		Url:      summary.Url,
+		Title:    summary.Meta.Title,
+		IsFolder: summary.IsFolder,
+		Exists:   true,
+	}
+}
+
+func (app App) getLinkReport(w http.ResponseWriter, r *http.Request) {
+	report, err := app.Content.LinkReport()
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, report)
+}
@@ -0,0 +1,371 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/libs/mail"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+func (app App) getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+
+	user, err := app.Users.GetById(userID)
+	if err != nil {
+		panic(err)
+	}
+
+	subscriptions := user.Subscriptions
+	if subscriptions == nil {
+		subscriptions = []model.Subscription{}
+	}
+
+	render.JSON(w, r, model.GetSubscriptionsResponse{
+		Subscriptions:        subscriptions,
+		NotificationsEnabled: app.Notifications.Enabled(),
+	})
+}
+
+func (app App) putSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+	urlPath := r.PathValue("*")
+
+	if ctxutil.Page(r.Context()) == nil && ctxutil.Folder(r.Context()) == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var body model.PutSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Mode != model.NotifyImmediately && body.Mode != model.NotifyDigest {
+		http.Error(w, "invalid mode", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.Users.Subscribe(userID, urlPath, body.Mode); err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteSubscription doesn't require read permission, so users can stop watching content they lost access to
+func (app App) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+	urlPath := r.PathValue("*")
+
+	err := app.Users.Unsubscribe(userID, urlPath)
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// watching returns the notification mode if the user watches the page or folder at urlPath
+func (app App) watching(userID, urlPath string) model.NotificationMode {
+	if userID == "" {
+		return ""
+	}
+
+	user, err := app.Users.GetById(userID)
+	if err != nil {
+		return ""
+	}
+
+	for _, sub := range user.Subscriptions {
+		if sub.Url == urlPath {
+			return sub.Mode
+		}
+	}
+
+	return ""
+}
+
+// matchingSubscription returns the subscription of the user with the given mode covering the change
+func matchingSubscription(user model.User, change model.Change, mode model.NotificationMode) (model.Subscription, bool) {
+	for _, sub := range user.Subscriptions {
+		if sub.Mode != mode {
+			continue
+		}
+		if inSubtree(change.Url, sub.Url) || (change.PreviousUrl != "" && inSubtree(change.PreviousUrl, sub.Url)) {
+			return sub, true
+		}
+	}
+	return model.Subscription{}, false
+}
+
+// isRecipient checks if the user is notified about the change by a subscription with the given mode.
+// Users are neither notified about their own changes nor about content they can't read.
+func (app App) isRecipient(acls *changeACLs, user model.User, change model.Change, mode model.NotificationMode) (model.Subscription, bool) {
+	if user.Email == "" || user.ID == change.ModifiedByUserID {
+		return model.Subscription{}, false
+	}
+
+	sub, found := matchingSubscription(user, change, mode)
+	if !found || !acls.canRead(change, user.ID) {
+		return model.Subscription{}, false
+	}
+
+	return sub, true
+}
+
+// notifySubscribers sends an email about a change to all users watching the affected content immediately.
+// Recipients are determined and notified in the background, permissions are checked at that time.
+func (app App) notifySubscribers(change model.Change) {
+	if !app.Notifications.Enabled() {
+		return
+	}
+
+	app.Notifications.Go(func() {
+		users, err := app.Users.ReadAll()
+		if err != nil {
+			log.Printf("[notifications] Could not read users: %v", err)
+			return
+		}
+
+		cfg, err := app.Config.Read()
+		if err != nil {
+			log.Printf("[notifications] Could not read config: %v", err)
+			return
+		}
+
+		changes := []model.Change{change}
+		app.populateChangesUserInfo(changes)
+		change := changes[0]
+
+		// Described lazily, as most changes are probably not watched by anybody
+		var subject, body string
+		acls := app.newChangeACLs()
+		messages := []mail.Message{}
+		for _, user := range users {
+			sub, ok := app.isRecipient(acls, user, change, model.NotifyImmediately)
+			if !ok {
+				continue
+			}
+
+			if body == "" {
+				subject = fmt.Sprintf("[%s] %s", cfg.AppTitle, describeChange(change, app.BaseURL).Title)
+				body = app.formatChange(change)
+			}
+			messages = append(messages, mail.Message{
+				To:      []string{user.Email},
+				Subject: subject,
+				Body:    body + app.notificationFooter([]string{sub.Url}),
+			})
+		}
+
+		for _, msg := range messages {
+			if err := app.Notifications.Send(msg); err != nil {
+				log.Printf("[notifications] %v", err)
+			}
+		}
+	})
+}
+
+// SendDigests sends one email with all changes since the last digest to every user watching content in digest mode.
+// Users whose digest couldn't be delivered get the changes again with the next digest.
+func (app App) SendDigests(now time.Time) error {
+	since, err := app.Notifications.LastDigest()
+	if err != nil {
+		return err
+	}
+	if since.IsZero() {
+		since = now.Add(-service.DigestInterval)
+	}
+
+	pending, err := app.Notifications.PendingDigests()
+	if err != nil {
+		return err
+	}
+
+	earliest := since
+	for _, t := range pending {
+		if t.Before(earliest) {
+			earliest = t
+		}
+	}
+
+	changes, err := app.Changes.Since(earliest, now)
+	if err != nil {
+		return err
+	}
+	app.populateChangesUserInfo(changes)
+
+	users, err := app.Users.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := app.Config.Read()
+	if err != nil {
+		return err
+	}
+
+	// Every change is described only once, even if it's sent to many users
+	formatted := map[int]string{}
+	acls := app.newChangeACLs()
+	failed := map[string]time.Time{}
+	errs := []error{}
+	for _, user := range users {
+		userSince := since
+		if t, found := pending[user.ID]; found && t.Before(since) {
+			userSince = t
+		}
+
+		var body strings.Builder
+		count := 0
+		watched := []string{}
+		for i, change := range changes {
+			if !change.Time.After(userSince) {
+				continue
+			}
+			sub, ok := app.isRecipient(acls, user, change, model.NotifyDigest)
+			if !ok {
+				continue
+			}
+			if _, found := formatted[i]; !found {
+				formatted[i] = app.formatChange(change)
+			}
+			if count > 0 {
+				body.WriteString("\n")
+			}
+			body.WriteString(formatted[i])
+			count++
+			if !slices.Contains(watched, sub.Url) {
+				watched = append(watched, sub.Url)
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		subject := fmt.Sprintf("[%s] Daily digest: %d changes", cfg.AppTitle, count)
+		if count == 1 {
+			subject = fmt.Sprintf("[%s] Daily digest: 1 change", cfg.AppTitle)
+		}
+
+		err := app.Notifications.Send(mail.Message{
+			To:      []string{user.Email},
+			Subject: subject,
+			Body:    body.String() + app.notificationFooter(watched),
+		})
+		if err != nil {
+			failed[user.ID] = userSince
+			errs = append(errs, err)
+		}
+	}
+
+	if err := app.Notifications.FinishDigest(now, failed); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not send %d digests: %w", len(errs), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// StartDigestScheduler starts a background goroutine that periodically checks whether a digest is due
+func (app App) StartDigestScheduler(ctx context.Context, interval time.Duration) {
+	if !app.Notifications.Enabled() {
+		return
+	}
+
+	check := func() {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[notifications] Digest error: %v", err)
+			}
+		}()
+
+		now := time.Now()
+		last, err := app.Notifications.LastDigest()
+		if err != nil {
+			log.Printf("[notifications] Digest error: %v", err)
+			return
+		}
+
+		if last.IsZero() {
+			// Start counting with the first run
+			err = app.Notifications.SetLastDigest(now)
+		} else if now.Sub(last) >= service.DigestInterval {
+			err = app.SendDigests(now)
+		}
+		if err != nil {
+			log.Printf("[notifications] Digest error: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		// Sending digests might take a while, so the first check doesn't delay the start either
+		check()
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				log.Println("[notifications] Digest scheduler stopped")
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}()
+}
+
+// formatChange describes a change in plain text, including a snippet of the changed lines
+func (app App) formatChange(change model.Change) string {
+	entry := describeChange(change, app.BaseURL)
+
+	var sb strings.Builder
+	sb.WriteString(entry.Title + "\n")
+	sb.WriteString(entry.Link + "\n")
+	if entry.Author != "" {
+		sb.WriteString("By " + entry.Author + " at " + change.Time.Format(time.RFC1123) + "\n")
+	} else {
+		sb.WriteString("At " + change.Time.Format(time.RFC1123) + "\n")
+	}
+	if entry.Summary != "" {
+		sb.WriteString("Summary: " + entry.Summary + "\n")
+	}
+	if snippet := app.changeSnippet(change); len(snippet) > 0 {
+		sb.WriteString("\n" + strings.Join(snippet, "\n") + "\n")
+	}
+
+	return sb.String()
+}
+
+// notificationFooter explains why an email was sent, given the URLs of the watched pages and folders
+func (app App) notificationFooter(urls []string) string {
+	watched := []string{}
+	for _, url := range urls {
+		if url == "" {
+			url = "the whole wiki"
+		}
+		watched = append(watched, url)
+	}
+	return fmt.Sprintf("\n-- \nYou receive this email because you watch %s.\nManage your subscriptions at %s/_profile\n",
+		strings.Join(watched, ", "), app.BaseURL)
+}
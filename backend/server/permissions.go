@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+// getPermissions explains which operations the user given by the query parameter "user" (username)
+// may perform on a page or folder, and which rule decided. Without user, anonymous access is explained.
+func (app App) getPermissions(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.PathValue("*")
+
+	page := ctxutil.Page(r.Context())
+	folder := ctxutil.Folder(r.Context())
+	ancestors := ctxutil.Ancestors(r.Context())
+
+	var meta model.ContentMeta
+	if page != nil {
+		meta = page.Meta
+	} else if folder != nil {
+		meta = folder.Meta
+	} else {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	response := model.GetPermissionsResponse{
+		Url:        urlPath,
+		Operations: []model.PermissionExplanation{},
+	}
+
+	userID := ""
+	if username := r.URL.Query().Get("user"); username != "" {
+		user, err := app.Users.GetByUsername(username)
+		if err != nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		userID = user.ID
+		response.User = &user
+	}
+
+	acl, origins := app.Content.GetEffectivePermissionsWithOrigins(urlPath, meta, ancestors)
+
+	cfg, err := app.Config.Read()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, op := range model.ValidContentOps {
+		decision, err := app.Users.ExplainContentPermissions(acl, userID, op)
+		if err != nil {
+			panic(err)
+		}
+
+		explanation := model.PermissionExplanation{
+			Op:      op,
+			Allowed: decision.Allowed,
+			Source:  model.PermissionSourceNone,
+		}
+
+		var rule model.AccessRule
+		switch {
+		case decision.Rule < 0:
+		case decision.Admin:
+			explanation.Source = model.PermissionSourceAdmin
+			rule = cfg.ACL[decision.Rule]
+		default:
+			explanation.SourceUrl = origins[decision.Rule]
+			explanation.Inherited = origins[decision.Rule] != urlPath
+			explanation.Source = model.PermissionSourceFolder
+			if page != nil && !explanation.Inherited {
+				explanation.Source = model.PermissionSourcePage
+			}
+			rule = acl[decision.Rule]
+		}
+
+		if decision.Rule >= 0 {
+			rules := []model.AccessRule{rule}
+			if err := app.Users.EnhanceACLWithUserInfo(&rules); err != nil {
+				panic(err)
+			}
+			explanation.Rule = &rules[0]
+		}
+
+		response.Operations = append(response.Operations, explanation)
+	}
+
+	render.JSON(w, r, response)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func (app App) getRedirects(w http.ResponseWriter, r *http.Request) {
+	redirects, err := app.Redirects.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, model.GetRedirectsResponse{Redirects: redirects})
+}
+
+func (app App) deleteRedirects(w http.ResponseWriter, r *http.Request) {
+	var req model.DeleteRedirectsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, url := range req.Urls {
+		if err := app.Redirects.Delete(url); err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				http.Error(w, "redirect not found: "+url, http.StatusNotFound)
+				return
+			}
+			panic(err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app App) pruneRedirects(w http.ResponseWriter, r *http.Request) {
+	removed, err := app.Redirects.Prune()
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, model.PruneRedirectsResponse{Removed: removed})
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/tfabritius/plainpage/libs/mail"
 	"github.com/tfabritius/plainpage/libs/spa"
 	"github.com/tfabritius/plainpage/model"
 	"github.com/tfabritius/plainpage/service"
@@ -20,23 +22,56 @@ type App struct {
 	Config              *service.ConfigService
 	Content             *service.ContentService
 	Users               *service.UserService
+	Redirects           *service.RedirectService
+	Changes             *service.ChangeService
+	Notifications       *service.NotificationService
 	AccessToken         service.AccessTokenService
 	RefreshToken        *service.RefreshTokenService
 	Retention           *service.RetentionService
 	LoginLimiter        *LoginLimiter
 	SearchLimiterByIP   *RateLimiter
 	SearchLimiterByUser *RateLimiter
+
+	// BaseURL is the public URL of the app, used for links in emails and feeds
+	BaseURL string
+}
+
+// AppOptions configures optional features of the app
+type AppOptions struct {
+	// SearchIndexDir is the directory to store a persistent search index in.
+	// If empty, the search index is kept in memory.
+	SearchIndexDir string
+
+	// SMTP configures the server used to send email notifications.
+	// If nil, notifications are disabled.
+	SMTP *mail.SMTPConfig
+
+	// BaseURL is the public URL of the app, used for links in emails and feeds
+	BaseURL string
 }
 
 func NewApp(staticFrontendFiles http.FileSystem, store model.Storage) App {
+	return NewAppWithOptions(staticFrontendFiles, store, AppOptions{})
+}
+
+func NewAppWithOptions(staticFrontendFiles http.FileSystem, store model.Storage, options AppOptions) App {
 	// Initialize config service (creates default config if needed)
 	configService := service.NewConfigService(store)
 
-	contentService := service.NewContentService(store, configService)
+	contentService := service.NewContentServiceWithOptions(store, configService, service.ContentOptions{
+		IndexDir: options.SearchIndexDir,
+	})
 	userService := service.NewUserService(store, configService)
+	redirectService := service.NewRedirectService(store, contentService)
+	changeService := service.NewChangeService(store)
+	var sender mail.Sender
+	if options.SMTP != nil {
+		sender = mail.NewSMTPSender(*options.SMTP)
+	}
+	notificationService := service.NewNotificationService(store, sender)
 	accessTokenService := service.NewAccessTokenService(configService)
 	refreshTokenService := service.NewRefreshTokenService(store)
-	retentionService := service.NewRetentionService(contentService, configService)
+	retentionService := service.NewRetentionService(contentService, changeService, configService)
 	loginLimiter := NewLoginLimiter(5, rate.Every(30*time.Second), 30*time.Minute)
 
 	// Search rate limiters: stricter for unauthenticated users (by IP), more lenient for authenticated users (by userID)
@@ -49,12 +84,16 @@ func NewApp(staticFrontendFiles http.FileSystem, store model.Storage) App {
 		Config:              configService,
 		Content:             contentService,
 		Users:               userService,
+		Redirects:           redirectService,
+		Changes:             changeService,
+		Notifications:       notificationService,
 		AccessToken:         accessTokenService,
 		RefreshToken:        refreshTokenService,
 		Retention:           retentionService,
 		LoginLimiter:        loginLimiter,
 		SearchLimiterByIP:   searchLimiterByIP,
 		SearchLimiterByUser: searchLimiterByUser,
+		BaseURL:             strings.TrimSuffix(options.BaseURL, "/"),
 	}
 }
 
@@ -68,7 +107,8 @@ func (app App) GetHandler() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*", "http://*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders: []string{"ETag"},
 	}))
 
 	r.
@@ -79,38 +119,86 @@ func (app App) GetHandler() http.Handler {
 			r.With(app.RequireAdminPermission).Get("/config", app.getConfig)
 			r.With(app.RequireAdminPermission).Patch("/config", app.patchConfig)
 			r.With(app.RequireAdminPermission).Get("/stats", app.getStats)
+			r.With(app.RequireAdminPermission).Get("/reports/links", app.getLinkReport)
+			r.With(app.RequireAdminPermission).Get("/reports/access", app.getAccessReport)
+			r.With(app.RequireAdminPermission, app.RetrieveContentMiddleware).Get("/permissions/*", app.getPermissions)
+			r.With(app.RequireAdminPermission).Route("/redirects", func(r chi.Router) {
+				r.Get("/", app.getRedirects)
+				r.Post("/delete", app.deleteRedirects)
+				r.Post("/prune", app.pruneRedirects)
+			})
 			r.With(app.RequireAdminPermission).Get("/storage/download", app.downloadStorage)
 			r.With(app.RequireAdminPermission).Post("/storage/restore", app.restoreStorage)
 
-			r.With(app.RetrieveContentMiddleware).Route("/pages", func(r chi.Router) {
-				r.Get("/*",
-					app.RequireContentPermission(model.AccessOpRead,
-						http.HandlerFunc(app.getContent),
-					).ServeHTTP)
-				r.Put("/*",
+			r.Route("/pages", func(r chi.Router) {
+				r.With(app.RetrieveContentMiddleware).Group(func(r chi.Router) {
+					r.Get("/*",
+						app.RequireContentPermission(model.AccessOpRead,
+							http.HandlerFunc(app.getContent),
+						).ServeHTTP)
+					r.Put("/*",
+						app.RequireContentPermission(model.AccessOpWrite,
+							http.HandlerFunc(app.putContent),
+						).ServeHTTP)
+					r.Patch("/*",
+						app.RequireContentPermission(model.AccessOpWrite,
+							http.HandlerFunc(app.patchContent),
+						).ServeHTTP)
+					r.Delete("/*",
+						app.RequireContentPermission(model.AccessOpDelete,
+							http.HandlerFunc(app.deleteContent),
+						).ServeHTTP)
+				})
+
+				// Actions on content, e.g. POST /pages/{url}/revert
+				r.With(SplitContentActionMiddleware, app.RetrieveContentMiddleware).Post("/*",
 					app.RequireContentPermission(model.AccessOpWrite,
-						http.HandlerFunc(app.putContent),
+						http.HandlerFunc(app.postContentAction),
 					).ServeHTTP)
-				r.Patch("/*",
-					app.RequireContentPermission(model.AccessOpWrite,
-						http.HandlerFunc(app.patchContent),
-					).ServeHTTP)
-				r.Delete("/*",
-					app.RequireContentPermission(model.AccessOpDelete,
-						http.HandlerFunc(app.deleteContent),
+			})
+
+			r.Route("/attic", func(r chi.Router) {
+				r.With(SplitAtticDiffMiddleware, app.RetrieveContentMiddleware).Get("/*",
+					app.RequireContentPermission(model.AccessOpRead,
+						http.HandlerFunc(app.getAttic),
 					).ServeHTTP)
 			})
 
-			r.With(app.RetrieveContentMiddleware).Route("/attic", func(r chi.Router) {
+			r.With(app.RetrieveContentMiddleware).Route("/links", func(r chi.Router) {
 				r.Get("/*",
 					app.RequireContentPermission(model.AccessOpRead,
-						http.HandlerFunc(app.getAttic),
+						http.HandlerFunc(app.getLinks),
 					).ServeHTTP)
 			})
 
 			r.With(app.SearchRateLimitMiddleware).
 				Post("/search", app.searchContent)
 
+			r.Get("/id/{id}", app.resolveID)
+
+			// Tags are collected from all content, so they are rate limited like searches
+			r.With(app.SearchRateLimitMiddleware).Get("/tags", app.getTags)
+			r.With(app.SearchRateLimitMiddleware).Get("/tags/{tag}", app.getTag)
+
+			r.Get("/changes", app.getChanges)
+
+			r.With(app.RequireAuth).Route("/subscriptions", func(r chi.Router) {
+				r.Get("/", app.getSubscriptions)
+				r.With(app.RetrieveContentMiddleware).Put("/*",
+					app.RequireContentPermission(model.AccessOpRead,
+						http.HandlerFunc(app.putSubscription),
+					).ServeHTTP)
+				r.Delete("/*", app.deleteSubscription)
+			})
+
+			r.Route("/feeds", func(r chi.Router) {
+				getFeed := app.RequireContentPermission(model.AccessOpRead,
+					http.HandlerFunc(app.getFeed),
+				).ServeHTTP
+				r.With(app.FeedTokenMiddleware, app.RetrieveContentMiddleware).Get("/{format:atom|rss}", getFeed)
+				r.With(app.FeedTokenMiddleware, app.RetrieveContentMiddleware).Get("/{format:atom|rss}/*", getFeed)
+			})
+
 			r.With(app.RequireAdminPermission).Route("/trash", func(r chi.Router) {
 				r.Get("/", app.getTrash)
 				r.Get("/page", app.getTrashPage)
@@ -130,6 +218,18 @@ func (app App) GetHandler() http.Handler {
 					Post("/users/{username:[a-zA-Z0-9_-]+}/password", app.changePassword)
 				r.With(app.RequireAuth).
 					Post("/users/{username:[a-zA-Z0-9_-]+}/delete", app.deleteUser)
+				r.With(app.RequireAuth).
+					Post("/users/{username:[a-zA-Z0-9_-]+}/feed-token", app.createFeedToken)
+				r.With(app.RequireAuth).
+					Post("/users/{username:[a-zA-Z0-9_-]+}/feed-token/delete", app.deleteFeedToken)
+
+				r.With(app.RequireAdminPermission).Route("/groups", func(r chi.Router) {
+					r.Get("/", app.getGroups)
+					r.Post("/", app.postGroup)
+					r.Get("/{id}", app.getGroup)
+					r.Patch("/{id}", app.patchGroup)
+					r.Delete("/{id}", app.deleteGroup)
+				})
 
 				r.With(app.LoginLimiter.Middleware(clientIPFromRequest)).
 					Post("/login", app.login)
@@ -51,11 +51,25 @@ func (app App) restoreStorage(w http.ResponseWriter, r *http.Request) {
 
 	// Restore backup
 	usersRestored, err := app.Content.RestoreBackup(zipReader)
+	// groups.yml might have been replaced, even if the restore failed later on
+	app.Users.InvalidateGroups()
 	if err != nil {
 		http.Error(w, "Failed to restore backup: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Redirects refer to the replaced content
+	if err := app.Redirects.DeleteAll(); err != nil {
+		http.Error(w, "Failed to delete redirects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The change journal refers to the replaced content
+	if err := app.Changes.DeleteAll(); err != nil {
+		http.Error(w, "Failed to delete change journal: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// If users were restored, revoke all refresh tokens for security
 	// (prevents old tokens from authenticating as wrong/deleted users)
 	if usersRestored {
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
+)
+
+// maxTagFacetHits is the maximum number of search hits considered for tag facets of non-admins
+const maxTagFacetHits = 1000
+
+// maxTagFacets is the number of tags included as facets in search responses
+const maxTagFacets = 20
+
+func (app App) getTags(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+
+	items, err := app.Content.ListTaggedContent("")
+	if err != nil {
+		panic(err)
+	}
+
+	response := model.GetTagsResponse{
+		Tags: countTags(app.filterReadable(items, userID)),
+	}
+
+	render.JSON(w, r, response)
+}
+
+func (app App) getTag(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+
+	tag, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		http.Error(w, "invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	items, err := app.Content.ListTaggedContent(tag)
+	if err != nil {
+		panic(err)
+	}
+
+	items = app.filterReadable(items, userID)
+	for i := range items {
+		items[i].Meta.ACL = nil // Hide ACL
+		app.populateModifiedByUserInfo(&items[i].Meta)
+	}
+
+	response := model.GetTagResponse{
+		Tag:   tag,
+		Items: items,
+	}
+
+	render.JSON(w, r, response)
+}
+
+// filterReadable returns the pages and folders the user is allowed to read
+func (app App) filterReadable(items []model.ContentSummary, userID string) []model.ContentSummary {
+	readable := []model.ContentSummary{}
+	for _, item := range items {
+		if app.hasContentPermission(item.EffectiveACL, userID, model.AccessOpRead) {
+			readable = append(readable, item)
+		}
+	}
+	return readable
+}
+
+// countTags counts the pages and folders carrying each tag, sorted by descending count and by name
+func countTags(items []model.ContentSummary) []model.TagCount {
+	counts := map[string]int{}
+	for _, item := range items {
+		seen := map[string]bool{}
+		for _, tag := range item.Meta.Tags {
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			counts[tag]++
+		}
+	}
+
+	tags := make([]model.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, model.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
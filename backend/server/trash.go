@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/render"
 	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/service/ctxutil"
 )
 
 func (app App) getTrash(w http.ResponseWriter, r *http.Request) {
@@ -149,6 +150,8 @@ func (app App) deleteTrashItems(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app App) restoreTrashItems(w http.ResponseWriter, r *http.Request) {
+	userID := ctxutil.UserID(r.Context())
+
 	var req model.TrashActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -167,6 +170,8 @@ func (app App) restoreTrashItems(w http.ResponseWriter, r *http.Request) {
 			}
 			panic(err)
 		}
+
+		app.recordChange(model.Change{Type: model.ChangeRestore, Url: item.Url, ModifiedByUserID: userID})
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/go-chi/render"
@@ -132,7 +133,7 @@ func (app App) patchUser(w http.ResponseWriter, r *http.Request) {
 
 	// Apply patch operations
 	if err := ApplyJSONPatch(&user, operations); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), patchErrorStatus(err))
 		return
 	}
 
@@ -150,6 +151,14 @@ func (app App) patchUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// validation: email address must be a plain address
+	if user.Email != "" {
+		if address, err := mail.ParseAddress(user.Email); err != nil || address.Address != user.Email {
+			http.Error(w, "invalid email address", http.StatusBadRequest)
+			return
+		}
+	}
+
 	if err := app.Users.Save(user); err != nil {
 		if errors.Is(err, model.ErrUserExistsAlready) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -450,3 +459,55 @@ func (app App) changePassword(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// feedTokenUser returns the user whose feed token is managed by the request.
+// Users can only manage their own feed token. With allowAdmin, admins can manage the feed tokens of all users.
+func (app App) feedTokenUser(w http.ResponseWriter, r *http.Request, allowAdmin bool) (model.User, bool) {
+	userID := ctxutil.UserID(r.Context())
+	username := r.PathValue("username")
+
+	user, err := app.Users.GetByUsername(username)
+	userNotFound := errors.Is(err, model.ErrNotFound)
+	if err != nil && !userNotFound {
+		panic(err)
+	}
+
+	if !(allowAdmin && app.isAdmin(userID)) && (userNotFound || user.ID != userID) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return model.User{}, false
+	}
+	if userNotFound {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return model.User{}, false
+	}
+
+	return user, true
+}
+
+func (app App) createFeedToken(w http.ResponseWriter, r *http.Request) {
+	// Tokens read feeds as their owner, so admins can't create them for other users
+	user, ok := app.feedTokenUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	token, err := app.Users.CreateFeedToken(user.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	render.JSON(w, r, model.FeedTokenResponse{Token: token})
+}
+
+func (app App) deleteFeedToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.feedTokenUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := app.Users.DeleteFeedToken(user.ID); err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tfabritius/plainpage/model"
+	"gopkg.in/yaml.v3"
+)
+
+func NewChangeService(store model.Storage) *ChangeService {
+	return &ChangeService{
+		storage: store,
+	}
+}
+
+// ChangeService keeps a journal of changes to pages and folders.
+// The journal is stored in one file per day: changes/{yyyy-mm-dd}.yml
+type ChangeService struct {
+	storage model.Storage
+	mu      sync.RWMutex
+}
+
+// changesFile returns the path of the journal file for the day of t
+func changesFile(t time.Time) string {
+	return filepath.Join("changes", t.UTC().Format(time.DateOnly)+".yml")
+}
+
+func (s *ChangeService) readFileUnlocked(fsPath string) ([]model.Change, error) {
+	if !s.storage.Exists(fsPath) {
+		return []model.Change{}, nil
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", fsPath, err)
+	}
+
+	changes := []model.Change{}
+	if err := yaml.Unmarshal(bytes, &changes); err != nil {
+		return nil, fmt.Errorf("could not parse YAML: %w", err)
+	}
+
+	return changes, nil
+}
+
+// Record adds a change to the journal. If no time is set, the current time is used.
+func (s *ChangeService) Record(change model.Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	change.Time = change.Time.UTC()
+
+	fsPath := changesFile(change.Time)
+	changes, err := s.readFileUnlocked(fsPath)
+	if err != nil {
+		return err
+	}
+
+	changes = append(changes, change)
+
+	bytes, err := yaml.Marshal(&changes)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WriteFile(fsPath, bytes)
+}
+
+// journalFilesUnlocked returns the names of the journal files, newest first
+func (s *ChangeService) journalFilesUnlocked() ([]string, error) {
+	if !s.storage.Exists("changes") {
+		return []string{}, nil
+	}
+
+	fileInfos, err := s.storage.ReadDirectory("changes")
+	if err != nil {
+		return nil, err
+	}
+
+	// Names of journal files sort chronologically
+	names := []string{}
+	for _, fi := range fileInfos {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".yml") {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	return names, nil
+}
+
+// readFileNewestFirstUnlocked reads a journal file and returns its changes, newest first
+func (s *ChangeService) readFileNewestFirstUnlocked(name string) ([]model.Change, error) {
+	changes, err := s.readFileUnlocked(filepath.Join("changes", name))
+	if err != nil {
+		return nil, err
+	}
+
+	// Changes are appended in chronological order
+	slices.Reverse(changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.After(changes[j].Time)
+	})
+
+	return changes, nil
+}
+
+// List returns the changes for which include returns true, newest first.
+// The first offset matching changes are skipped, at most limit changes are returned.
+// Also returns whether there are more matching changes.
+func (s *ChangeService) List(offset, limit int, include func(model.Change) bool) ([]model.Change, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names, err := s.journalFilesUnlocked()
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := []model.Change{}
+	skipped := 0
+	for _, name := range names {
+		changes, err := s.readFileNewestFirstUnlocked(name)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, change := range changes {
+			if !include(change) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(result) == limit {
+				return result, true, nil
+			}
+			result = append(result, change)
+		}
+	}
+
+	return result, false, nil
+}
+
+// Since returns the changes after since and not after until, oldest first.
+// Only the journal files of the days in between are read.
+func (s *ChangeService) Since(since, until time.Time) ([]model.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names, err := s.journalFilesUnlocked()
+	if err != nil {
+		return nil, err
+	}
+
+	first := filepath.Base(changesFile(since))
+	last := filepath.Base(changesFile(until))
+
+	result := []model.Change{}
+	for _, name := range names {
+		if name > last {
+			continue
+		}
+		if name < first {
+			break
+		}
+
+		changes, err := s.readFileNewestFirstUnlocked(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, change := range changes {
+			if change.Time.After(since) && !change.Time.After(until) {
+				result = append(result, change)
+			}
+		}
+	}
+
+	slices.Reverse(result)
+
+	return result, nil
+}
+
+// DeleteBefore removes the journal files of all days before the day of cutoff.
+// Returns the number of deleted files.
+func (s *ChangeService) DeleteBefore(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.journalFilesUnlocked()
+	if err != nil {
+		return 0, err
+	}
+
+	first := filepath.Base(changesFile(cutoff))
+
+	deleted := 0
+	for _, name := range names {
+		if name >= first {
+			continue
+		}
+		if err := s.storage.DeleteFile(filepath.Join("changes", name)); err != nil {
+			return deleted, fmt.Errorf("could not delete %s: %w", name, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// DeleteAll removes all changes from the journal
+func (s *ChangeService) DeleteAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.storage.DeleteDirectory("changes")
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func TestChangeService_Since(t *testing.T) {
+	r := require.New(t)
+	changeService := NewChangeService(newMockStorage())
+
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, url := range []string{"a", "b", "c", "d"} {
+		r.NoError(changeService.Record(model.Change{Url: url, Time: day.Add(time.Duration(i) * 12 * time.Hour)}))
+	}
+
+	urls := func(changes []model.Change) []string {
+		result := []string{}
+		for _, change := range changes {
+			result = append(result, change.Url)
+		}
+		return result
+	}
+
+	// Oldest first, excluding since and including until
+	changes, err := changeService.Since(day, day.Add(24*time.Hour))
+	r.NoError(err)
+	r.Equal([]string{"b", "c"}, urls(changes))
+
+	changes, err = changeService.Since(day.Add(-time.Hour), day.Add(48*time.Hour))
+	r.NoError(err)
+	r.Equal([]string{"a", "b", "c", "d"}, urls(changes))
+
+	changes, err = changeService.Since(day.Add(48*time.Hour), day.Add(72*time.Hour))
+	r.NoError(err)
+	r.Empty(changes)
+}
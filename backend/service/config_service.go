@@ -52,6 +52,10 @@ func (s *ConfigService) initializeConfig() error {
 		AppTitle:  "PlainPage",
 		JwtSecret: jwtSecret,
 		SetupMode: true,
+		Retention: model.RetentionConfig{
+			// The journal is only used for recent changes, feeds and notifications
+			Changes: model.ChangesRetention{MaxAgeDays: 90},
+		},
 	}
 
 	return s.writeUnlocked(cfg)
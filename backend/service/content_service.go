@@ -2,22 +2,37 @@ package service
 
 import (
 	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/url"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/tfabritius/plainpage/libs/diff"
 	"github.com/tfabritius/plainpage/model"
 )
 
+// ContentOptions configures optional features of the content service
+type ContentOptions struct {
+	// IndexDir is the directory to store a persistent search index in.
+	// If empty, an in-memory index is created on every start.
+	IndexDir string
+}
+
 // BackupOptions configures what to include in a backup
 type BackupOptions struct {
 	IncludeConfig bool
@@ -25,16 +40,22 @@ type BackupOptions struct {
 }
 
 func NewContentService(store model.Storage, config *ConfigService) *ContentService {
+	return NewContentServiceWithOptions(store, config, ContentOptions{})
+}
+
+func NewContentServiceWithOptions(store model.Storage, config *ConfigService, options ContentOptions) *ContentService {
 	s := ContentService{
-		storage: store,
-		config:  config,
+		storage:  store,
+		config:   config,
+		indexDir: options.IndexDir,
+		locks:    newKeyedMutex(),
 	}
 
 	if err := s.initializeStorage(); err != nil {
 		log.Fatalln("Could not initialize storage:", err)
 	}
 
-	if err := s.RecreateIndex(); err != nil {
+	if err := s.openIndex(); err != nil {
 		log.Fatalln("Could not initialize search index:", err)
 	}
 
@@ -42,9 +63,43 @@ func NewContentService(store model.Storage, config *ConfigService) *ContentServi
 }
 
 type ContentService struct {
-	storage model.Storage
-	index   bleve.Index
-	config  *ConfigService
+	storage  model.Storage
+	index    bleve.Index
+	indexDir string
+	config   *ConfigService
+
+	// indexLock protects index, which is replaced when the index is recreated
+	indexLock sync.RWMutex
+
+	// indexChanges records the pages and folders changed while the index is recreated,
+	// and whether everything below them changed as well. nil if the index isn't being recreated.
+	indexChanges   map[string]bool
+	indexChangesMu sync.Mutex
+
+	// locks serializes changes of a page or folder, keyed by URL
+	locks *keyedMutex
+}
+
+// Precondition checks the current ETag of a page or folder ("" if it doesn't exist) before it is changed.
+// It is evaluated while the page or folder is locked, so no other change can happen in between.
+type Precondition func(etag string) bool
+
+// checkPrecondition evaluates the precondition (if any) for the page or folder, which must be locked.
+// Returns model.ErrPreconditionFailed if it isn't met.
+func (s *ContentService) checkPrecondition(urlPath string, precondition Precondition) error {
+	if precondition == nil {
+		return nil
+	}
+
+	etag, err := s.ETag(urlPath)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	if !precondition(etag) {
+		return model.ErrPreconditionFailed
+	}
+	return nil
 }
 
 func (s *ContentService) initializeStorage() error {
@@ -63,7 +118,7 @@ func (s *ContentService) initializeStorage() error {
 		defaultACL := []model.AccessRule{
 			{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead, model.AccessOpWrite, model.AccessOpDelete}},
 		}
-		if err := s.SaveFolder("", model.ContentMeta{ACL: &defaultACL}); err != nil {
+		if err := s.SaveFolder("", model.ContentMeta{ACL: &defaultACL}, nil); err != nil {
 			return fmt.Errorf("could not create default ACL: %w", err)
 		}
 	}
@@ -71,52 +126,113 @@ func (s *ContentService) initializeStorage() error {
 	return nil
 }
 
+// RecreateIndex builds a new search index from all pages and folders.
+// The current index is used until the new one is complete.
+// Changes made in the meantime are applied to the new index before it replaces the current one.
 func (s *ContentService) RecreateIndex() error {
-	// Create new in-memory index
-	idx, err := bleve.NewMemOnly(s.createIndexMapping())
+	startedAt := time.Now()
+
+	// Create new empty index
+	idx, err := s.newIndex()
 	if err != nil {
 		return err
 	}
 
+	s.indexChangesMu.Lock()
+	s.indexChanges = map[string]bool{}
+	s.indexChangesMu.Unlock()
+
 	// (Re-)Index all documents
 	log.Println("Creating search index...")
-	if err := s.indexFolder("", &idx); err != nil {
+	err = s.buildIndex(idx, startedAt)
+
+	// Block writers until the new index is in use
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+
+	s.indexChangesMu.Lock()
+	changes := s.indexChanges
+	s.indexChanges = nil
+	s.indexChangesMu.Unlock()
+
+	if err == nil {
+		err = s.applyIndexChanges(idx, changes)
+	}
+	if err != nil {
+		s.discardIndex(idx)
+		return err
+	}
+
+	return s.replaceIndexLocked(idx)
+}
+
+// buildIndex indexes all pages and folders in the new index idx
+func (s *ContentService) buildIndex(idx bleve.Index, startedAt time.Time) error {
+	if err := s.indexTree("", idx); err != nil {
 		return err
 	}
 	cnt, err := idx.DocCount()
 	if err != nil {
 		return err
 	}
+	if err := setIndexSyncState(idx, startedAt); err != nil {
+		return err
+	}
 	log.Printf("done (%v entries).", cnt)
-
-	s.index = idx
 	return nil
 }
 
 func (*ContentService) createIndexMapping() *mapping.IndexMappingImpl {
-	metaMapping := bleve.NewDocumentMapping()
-	metaMapping.AddSubDocumentMapping("acl", bleve.NewDocumentDisabledMapping())
-
-	pageMapping := bleve.NewDocumentMapping()
-	pageMapping.AddSubDocumentMapping("meta", metaMapping)
-	pageMapping.AddSubDocumentMapping("url", bleve.NewDocumentDisabledMapping())
-
-	folderMapping := bleve.NewDocumentMapping()
-	folderMapping.AddSubDocumentMapping("meta", metaMapping)
-	folderMapping.AddSubDocumentMapping("content", bleve.NewDocumentDisabledMapping())
+	// Fields only searchable by field name (e.g. url:docs/*)
+	urlMapping := bleve.NewKeywordFieldMapping()
+	urlMapping.IncludeInAll = false
+	modifiedAtMapping := bleve.NewDateTimeFieldMapping()
+	modifiedAtMapping.IncludeInAll = false
+	modifiedByMapping := bleve.NewKeywordFieldMapping()
+	modifiedByMapping.IncludeInAll = false
+	modifiedByMapping.Store = false
+	linksMapping := bleve.NewKeywordFieldMapping()
+	linksMapping.IncludeInAll = false
+	idMapping := bleve.NewKeywordFieldMapping()
+	idMapping.IncludeInAll = false
+	idMapping.Store = false
+
+	// Tags are additionally indexed as keywords (meta.tag) for exact filtering
+	tagMapping := bleve.NewKeywordFieldMapping()
+	tagMapping.Name = "tag"
+	tagMapping.IncludeInAll = false
+	tagMapping.Store = false
+
+	metaMapping := bleve.NewDocumentStaticMapping()
+	metaMapping.AddFieldMappingsAt("id", idMapping)
+	metaMapping.AddFieldMappingsAt("title", bleve.NewTextFieldMapping())
+	metaMapping.AddFieldMappingsAt("tags", bleve.NewTextFieldMapping(), tagMapping)
+	metaMapping.AddFieldMappingsAt("modifiedAt", modifiedAtMapping)
+	metaMapping.AddFieldMappingsAt("modifiedBy", modifiedByMapping)
+
+	documentMapping := bleve.NewDocumentStaticMapping()
+	documentMapping.AddFieldMappingsAt("url", urlMapping)
+	documentMapping.AddFieldMappingsAt("content", bleve.NewTextFieldMapping())
+	documentMapping.AddFieldMappingsAt("links", linksMapping)
+	documentMapping.AddSubDocumentMapping("meta", metaMapping)
 
 	indexMapping := bleve.NewIndexMapping()
 	indexMapping.TypeField = "BleveType"
-	indexMapping.AddDocumentMapping("page", pageMapping)
-	indexMapping.AddDocumentMapping("folder", folderMapping)
+	indexMapping.AddDocumentMapping("page", documentMapping)
+	indexMapping.AddDocumentMapping("folder", documentMapping)
 	return indexMapping
 }
 
-func (s *ContentService) indexFolder(urlPath string, idx *bleve.Index) error {
-	if idx == nil {
-		panic("index pointer is nil")
+// indexTree indexes a folder and all its contents
+func (s *ContentService) indexTree(urlPath string, idx bleve.Index) error {
+	batch := idx.NewBatch()
+	if err := s.indexFolder(urlPath, idx, batch); err != nil {
+		return err
 	}
+	return idx.Batch(batch)
+}
 
+func (s *ContentService) indexFolder(urlPath string, idx bleve.Index, batch *bleve.Batch) error {
 	folder, err := s.ReadFolder(urlPath)
 	if err != nil {
 		return err
@@ -124,26 +240,38 @@ func (s *ContentService) indexFolder(urlPath string, idx *bleve.Index) error {
 
 	if urlPath != "" {
 		// Index the folder itself
-		if err := (*idx).Index(urlPath, folder); err != nil {
+		if err := s.addToBatch(batch, urlPath, model.Folder{Url: urlPath, Meta: folder.Meta}); err != nil {
 			return err
 		}
 	}
 
 	for _, c := range folder.Content {
+		// Pages and folders removed in the meantime are skipped,
+		// changes during a rebuild are applied to the new index afterwards
 		if c.IsFolder {
 			// Recursively index subfolder
-			if err := s.indexFolder(c.Url, idx); err != nil {
+			if err := s.indexFolder(c.Url, idx, batch); err != nil && !errors.Is(err, fs.ErrNotExist) {
 				return err
 			}
 		} else {
 			// Index page
 			page, err := s.ReadPage(c.Url, nil)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
 			if err != nil {
 				return err
 			}
-			if err := (*idx).Index(c.Url, page); err != nil {
+			if err := s.addToBatch(batch, c.Url, page); err != nil {
+				return err
+			}
+		}
+
+		if batch.Size() >= indexBatchSize {
+			if err := idx.Batch(batch); err != nil {
 				return err
 			}
+			batch.Reset()
 		}
 	}
 
@@ -163,7 +291,7 @@ func (s *ContentService) removeFolderFromIndex(urlPath string) error {
 				return err
 			}
 		} else {
-			if err := s.index.Delete(entry.Url); err != nil {
+			if err := s.unindexDocument(entry.Url); err != nil {
 				log.Printf("[INDEX] Could not delete page %s from index: %v", entry.Url, err)
 			}
 		}
@@ -171,7 +299,7 @@ func (s *ContentService) removeFolderFromIndex(urlPath string) error {
 
 	// Delete the folder itself (if not root)
 	if urlPath != "" {
-		if err := s.index.Delete(urlPath); err != nil {
+		if err := s.unindexDocument(urlPath); err != nil {
 			log.Printf("[INDEX] Could not delete folder %s from index: %v", urlPath, err)
 		}
 	}
@@ -180,60 +308,49 @@ func (s *ContentService) removeFolderFromIndex(urlPath string) error {
 }
 
 // Search searches for content and returns all matching results (up to 10000).
+// See ParseSearchQuery for the query syntax.
 func (s *ContentService) Search(q string) ([]model.SearchHit, error) {
-	results, _, err := s.SearchWithPagination(q, 0, 10000)
+	parsedQuery, err := ParseSearchQuery(q, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results, _, err := s.SearchWithPagination(parsedQuery, 0, 10000)
 	return results, err
 }
 
 // SearchWithPagination searches for content with pagination support.
 // Returns the search hits, total number of hits in the index, and any error.
-func (s *ContentService) SearchWithPagination(q string, offset, size int) ([]model.SearchHit, uint64, error) {
-	query := bleve.NewMatchQuery(q)
-
-	search := bleve.NewSearchRequest(query)
+func (s *ContentService) SearchWithPagination(q query.Query, offset, size int) ([]model.SearchHit, uint64, error) {
+	search := bleve.NewSearchRequest(q)
 	search.Highlight = bleve.NewHighlight()
 	search.From = offset
 	search.Size = size
 
-	results, err := s.index.Search(search)
+	results, err := s.searchIndex(search)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	// Ancestors only depend on the parent folder, so they can be shared between siblings
+	ancestorsCache := map[string][]model.UrlAndMeta{}
+
 	ret := []model.SearchHit{}
 	for _, r := range results.Hits {
-		var meta model.ContentMeta
-		isFolder := false
-
-		if s.IsPage(r.ID) {
-			page, err := s.ReadPage(r.ID, nil)
-			if err != nil {
-				return nil, 0, err
-			}
-			meta = page.Meta
-		} else if s.IsFolder(r.ID) {
-			isFolder = true
-			folder, err := s.ReadFolder(r.ID)
-			if err != nil {
-				return nil, 0, err
-			}
-			meta = folder.Meta
-		} else {
-			continue
-		}
-
-		metas, err := s.ReadAncestors(r.ID)
+		item, err := s.readContentSummary(r.ID, ancestorsCache)
 		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				continue
+			}
 			return nil, 0, err
 		}
-		acl := s.GetEffectivePermissions(meta, metas)
 
 		ret = append(ret, model.SearchHit{
 			Url:          r.ID,
-			Meta:         meta,
+			Meta:         item.Meta,
 			Fragments:    r.Fragments,
-			EffectiveACL: acl,
-			IsFolder:     isFolder,
+			EffectiveACL: item.EffectiveACL,
+			IsFolder:     item.IsFolder,
 		})
 	}
 
@@ -250,6 +367,48 @@ func (s *ContentService) IsFolder(urlPath string) bool {
 	return s.storage.Exists(fsPath)
 }
 
+// ETag returns an entity tag identifying the current version of a page or folder.
+// It is derived from the hash of the stored file, so any change to content or metadata yields a new value.
+func (s *ContentService) ETag(urlPath string) (string, error) {
+	var fsPath string
+	if s.IsPage(urlPath) {
+		fsPath = filepath.Join("pages", urlPath+".md")
+	} else if s.IsFolder(urlPath) {
+		fsPath = filepath.Join("pages", urlPath, "_index.md")
+	} else {
+		return "", model.ErrNotFound
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return "", err
+	}
+
+	return `"` + hashContent(bytes) + `"`, nil
+}
+
+// readPageWithETag reads the current version of a page together with its ETag,
+// e.g. to save changes based on it only if the page wasn't changed in the meantime
+func (s *ContentService) readPageWithETag(urlPath string) (model.Page, string, error) {
+	bytes, err := s.storage.ReadFile(filepath.Join("pages", urlPath+".md"))
+	if err != nil {
+		return model.Page{}, "", err
+	}
+
+	fm, content, err := parseFrontMatter(string(bytes))
+	if err != nil {
+		return model.Page{}, "", fmt.Errorf("could not parse frontmatter: %w", err)
+	}
+
+	return model.Page{Url: urlPath, Content: content, Meta: fm}, `"` + hashContent(bytes) + `"`, nil
+}
+
+// hashContent returns a hex encoded hash of the given file content
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16])
+}
+
 func (s *ContentService) IsAtticPage(urlPath string, revision int64) bool {
 	revStr := strconv.FormatInt(revision, 10)
 	fsPath := filepath.Join("attic", urlPath+"."+revStr+".md")
@@ -364,6 +523,9 @@ func (s *ContentService) ReadTrashPage(urlPath string, deletedAt int64) (model.P
 
 // RestoreFromTrash restores a page from trash to its original location
 func (s *ContentService) RestoreFromTrash(urlPath string, deletedAt int64) error {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
 	timestampStr := "_" + strconv.FormatInt(deletedAt, 10)
 	pageName := path.Base(urlPath)
 	trashDir := filepath.Join("trash", urlPath, timestampStr)
@@ -421,6 +583,14 @@ func (s *ContentService) RestoreFromTrash(urlPath string, deletedAt int64) error
 		}
 	}
 
+	// Move the details of the attic entries back
+	srcRevisionsPath := filepath.Join(trashDir, revisionsFileName(pageName))
+	if s.storage.Exists(srcRevisionsPath) {
+		if err := s.storage.Rename(srcRevisionsPath, revisionsFile(urlPath)); err != nil {
+			return fmt.Errorf("could not restore revisions: %w", err)
+		}
+	}
+
 	// Delete the now-empty trash directory
 	_ = s.storage.DeleteEmptyDirectory(trashDir)
 
@@ -429,7 +599,7 @@ func (s *ContentService) RestoreFromTrash(urlPath string, deletedAt int64) error
 	if err != nil {
 		return fmt.Errorf("could not read restored page: %w", err)
 	}
-	if err := s.index.Index(urlPath, page); err != nil {
+	if err := s.indexDocument(urlPath, page); err != nil {
 		log.Printf("[INDEX] Could not index restored page %s: %v", urlPath, err)
 	}
 
@@ -465,53 +635,95 @@ func (s *ContentService) ReadPage(urlPath string, revision *int64) (model.Page,
 
 // SavePage saves a page and creates a version in the attic.
 func (s *ContentService) SavePage(urlPath, content string, meta model.ContentMeta, userID string) error {
-	return s.savePageAtInternal(urlPath, content, meta, userID, true, time.Now())
+	_, err := s.SavePageWithSummary(urlPath, content, meta, userID, "", false, nil)
+	return err
+}
+
+// SavePageWithSummary saves a page and creates a version in the attic,
+// recording an edit summary and whether the change is a minor edit.
+// Returns the version created, or model.ErrPreconditionFailed if the precondition (optional) isn't met.
+func (s *ContentService) SavePageWithSummary(urlPath, content string, meta model.ContentMeta, userID, summary string, minor bool, precondition Precondition) (model.AtticEntry, error) {
+	meta.RevertedTo = nil
+	return s.savePageAtInternal(urlPath, content, meta, userID, &revisionNote{summary: summary, minor: minor}, time.Now(), precondition)
 }
 
 // SavePageWithoutVersion saves a page without creating a version in the attic.
 // Use this for metadata-only changes (e.g., ACL, title) that shouldn't create history entries.
-func (s *ContentService) SavePageWithoutVersion(urlPath, content string, meta model.ContentMeta, userID string) error {
-	return s.savePageAtInternal(urlPath, content, meta, userID, false, time.Now())
+// Returns model.ErrPreconditionFailed if the precondition (optional) isn't met.
+func (s *ContentService) SavePageWithoutVersion(urlPath, content string, meta model.ContentMeta, userID string, precondition Precondition) error {
+	_, err := s.savePageAtInternal(urlPath, content, meta, userID, nil, time.Now(), precondition)
+	return err
 }
 
-// SavePageAt saves a page with a specific timestamp.
-// This is primarily useful for testing scenarios where you need to create multiple
-// attic versions without waiting for time to pass (since revisions are stored with second precision).
+// SavePageAt saves a page and creates a version in the attic with a specific timestamp.
+// This is primarily useful for testing scenarios that need versions from the past (e.g. retention).
 func (s *ContentService) SavePageAt(urlPath, content string, meta model.ContentMeta, userID string, revisionTime time.Time) error {
-	return s.savePageAtInternal(urlPath, content, meta, userID, true, revisionTime)
+	meta.RevertedTo = nil
+	_, err := s.savePageAtInternal(urlPath, content, meta, userID, &revisionNote{}, revisionTime, nil)
+	return err
+}
+
+// savePageAtInternal locks the page, checks the precondition and saves the page with a specific timestamp.
+// A version is created in the attic unless version is nil.
+func (s *ContentService) savePageAtInternal(urlPath, content string, meta model.ContentMeta, userID string, version *revisionNote, revisionTime time.Time, precondition Precondition) (model.AtticEntry, error) {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	if err := s.checkPrecondition(urlPath, precondition); err != nil {
+		return model.AtticEntry{}, err
+	}
+
+	return s.savePageLocked(urlPath, content, meta, userID, version, revisionTime)
 }
 
-// savePageAtInternal saves a page with a specific timestamp (internal implementation).
-func (s *ContentService) savePageAtInternal(urlPath, content string, meta model.ContentMeta, userID string, createVersion bool, revisionTime time.Time) error {
+// savePageLocked saves a page, which must be locked by the caller.
+// Returns the version created in the attic, which is empty if version is nil.
+func (s *ContentService) savePageLocked(urlPath, content string, meta model.ContentMeta, userID string, version *revisionNote, revisionTime time.Time) (model.AtticEntry, error) {
 	if !s.IsFolder(path.Dir(urlPath)) {
-		return model.ErrParentFolderNotFound
+		return model.AtticEntry{}, model.ErrParentFolderNotFound
 	}
 	if s.IsFolder(urlPath) {
-		return model.ErrPageOrFolderExistsAlready
+		return model.AtticEntry{}, model.ErrPageOrFolderExistsAlready
+	}
+
+	fsPath := filepath.Join("pages", urlPath+".md")
+
+	// Keep the ID of existing pages, IDs given by clients are ignored
+	id, err := s.contentID(fsPath)
+	if err != nil {
+		return model.AtticEntry{}, fmt.Errorf("could not determine page ID: %w", err)
 	}
+	meta.ID = id
 
 	// Set modification metadata
 	meta.ModifiedAt = time.Now().UTC()
 	meta.ModifiedByUserID = userID
 
-	fsPath := filepath.Join("pages", urlPath+".md")
-
 	serializedPage, err := serializeFrontMatter(meta, content)
 	if err != nil {
-		return fmt.Errorf("could not serialize frontmatter: %w", err)
+		return model.AtticEntry{}, fmt.Errorf("could not serialize frontmatter: %w", err)
 	}
 
 	if err := s.storage.WriteFile(fsPath, []byte(serializedPage)); err != nil {
-		return fmt.Errorf("could not write file: %w", err)
+		return model.AtticEntry{}, fmt.Errorf("could not write file: %w", err)
 	}
 
-	if createVersion {
-		revision := revisionTime.Unix()
-		revStr := strconv.FormatInt(revision, 10)
-		atticFile := filepath.Join("attic", urlPath+"."+revStr+".md")
+	var entry model.AtticEntry
+	if version != nil {
+		revision, err := s.createRevision(urlPath, revisionTime, []byte(serializedPage))
+		if err != nil {
+			return model.AtticEntry{}, fmt.Errorf("could not save page to attic: %w", err)
+		}
 
-		if err := s.storage.WriteFile(atticFile, []byte(serializedPage)); err != nil {
-			return fmt.Errorf("could not save page to attic: %w", err)
+		entry = model.AtticEntry{
+			Revision:         revision,
+			ModifiedByUserID: userID,
+			Summary:          version.summary,
+			Minor:            version.minor,
+			Size:             int64(len(serializedPage)),
+		}
+		if err := s.addRevision(urlPath, entry); err != nil {
+			return model.AtticEntry{}, fmt.Errorf("could not record version: %w", err)
 		}
 	}
 
@@ -521,11 +733,11 @@ func (s *ContentService) savePageAtInternal(urlPath, content string, meta model.
 		Content: content,
 		Meta:    meta,
 	}
-	if err := s.index.Index(urlPath, page); err != nil {
+	if err := s.indexDocument(urlPath, page); err != nil {
 		log.Printf("[INDEX] Could not update page %s in index: %v", urlPath, err)
 	}
 
-	return nil
+	return entry, nil
 }
 
 func (s *ContentService) DeletePage(urlPath string) error {
@@ -534,13 +746,16 @@ func (s *ContentService) DeletePage(urlPath string) error {
 
 // deletePageAt deletes a page at the specified time (for testing with custom timestamps).
 func (s *ContentService) deletePageAt(urlPath string, deletedAt time.Time) error {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
 	// Move page and attic entries to trash
 	if err := s.movePageToTrashAt(urlPath, deletedAt); err != nil {
 		return err
 	}
 
 	// Update search index
-	if err := s.index.Delete(urlPath); err != nil {
+	if err := s.unindexDocument(urlPath); err != nil {
 		log.Printf("[INDEX] Could not delete page %s from index: %v", urlPath, err)
 	}
 
@@ -564,7 +779,7 @@ func (s *ContentService) movePageToTrashAt(urlPath string, deletedAt time.Time)
 	}
 
 	// Move all attic entries for this page
-	atticEntries, err := s.ListAttic(urlPath)
+	atticEntries, err := s.listAtticLocked(urlPath)
 	if err != nil {
 		// If attic directory doesn't exist or is empty, that's fine
 		return nil
@@ -580,6 +795,10 @@ func (s *ContentService) movePageToTrashAt(urlPath string, deletedAt time.Time)
 		}
 	}
 
+	if err := s.moveRevisionsFile(urlPath, filepath.Join(trashDir, revisionsFileName(pageName))); err != nil {
+		return fmt.Errorf("could not move revisions to trash: %w", err)
+	}
+
 	return nil
 }
 
@@ -591,6 +810,12 @@ func (s *ContentService) CreateFolder(urlPath string, meta model.ContentMeta) er
 		return model.ErrPageOrFolderExistsAlready
 	}
 
+	id, err := newContentID()
+	if err != nil {
+		return fmt.Errorf("could not generate folder ID: %w", err)
+	}
+	meta.ID = id
+
 	serialized, err := serializeFrontMatter(meta, "")
 	if err != nil {
 		return fmt.Errorf("could not serialize frontmatter: %w", err)
@@ -608,10 +833,10 @@ func (s *ContentService) CreateFolder(urlPath string, meta model.ContentMeta) er
 
 	// Update search index
 	folder := model.Folder{
-		Content: nil,
-		Meta:    meta,
+		Url:  urlPath,
+		Meta: meta,
 	}
-	if err := s.index.Index(urlPath, folder); err != nil {
+	if err := s.indexDocument(urlPath, folder); err != nil {
 		log.Printf("[INDEX] Could not add folder %s to index: %v", urlPath, err)
 	}
 
@@ -656,13 +881,18 @@ func (s *ContentService) ReadFolder(urlPath string) (model.Folder, error) {
 			IsFolder: fi.IsDir(),
 		}
 
+		// Entries removed since the directory was read are skipped
 		if e.IsFolder {
 			meta, err := s.ReadFolderMeta(e.Url)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
 			if err != nil {
 				return model.Folder{}, fmt.Errorf("could not read folder %s: %w", e.Url, err)
 			}
 			e.Title = meta.Title
 			e.ACL = meta.ACL
+			e.ExtendACL = meta.ExtendACL
 		} else {
 			if !strings.HasPrefix(e.Name, "_") && strings.HasSuffix(e.Name, ".md") {
 				e.Name = strings.TrimSuffix(e.Name, ".md")
@@ -672,12 +902,16 @@ func (s *ContentService) ReadFolder(urlPath string) (model.Folder, error) {
 			}
 
 			page, err := s.ReadPage(e.Url, nil)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
 			if err != nil {
 				return model.Folder{}, fmt.Errorf("could not read page %s: %w", e.Url, err)
 			}
 
 			e.Title = page.Meta.Title
 			e.ACL = page.Meta.ACL
+			e.ExtendACL = page.Meta.ExtendACL
 		}
 
 		folderEntries = append(folderEntries, e)
@@ -698,9 +932,30 @@ func (s *ContentService) ReadFolder(urlPath string) (model.Folder, error) {
 	return folder, nil
 }
 
-func (s *ContentService) SaveFolder(urlPath string, meta model.ContentMeta) error {
+// SaveFolder saves the metadata of a folder.
+// Returns model.ErrPreconditionFailed if the precondition (optional) isn't met.
+func (s *ContentService) SaveFolder(urlPath string, meta model.ContentMeta, precondition Precondition) error {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	if err := s.checkPrecondition(urlPath, precondition); err != nil {
+		return err
+	}
+
+	return s.saveFolderLocked(urlPath, meta)
+}
+
+// saveFolderLocked saves the metadata of a folder, which must be locked by the caller
+func (s *ContentService) saveFolderLocked(urlPath string, meta model.ContentMeta) error {
 	indexPath := filepath.Join("pages", urlPath, "_index.md")
 
+	// Keep the ID of the folder, IDs given by clients are ignored
+	id, err := s.contentID(indexPath)
+	if err != nil {
+		return fmt.Errorf("could not determine folder ID: %w", err)
+	}
+	meta.ID = id
+
 	serialized, err := serializeFrontMatter(meta, "")
 	if err != nil {
 		return fmt.Errorf("could not serialize frontmatter: %w", err)
@@ -713,10 +968,10 @@ func (s *ContentService) SaveFolder(urlPath string, meta model.ContentMeta) erro
 	// Update search index
 	if urlPath != "" {
 		folder := model.Folder{
-			Content: nil,
-			Meta:    meta,
+			Url:  urlPath,
+			Meta: meta,
 		}
-		if err := s.index.Index(urlPath, folder); err != nil {
+		if err := s.indexDocument(urlPath, folder); err != nil {
 			log.Printf("[INDEX] Could not update folder %s in index: %v", urlPath, err)
 		}
 	}
@@ -741,7 +996,7 @@ func (s *ContentService) DeleteEmptyFolder(urlPath string) error {
 	}
 
 	// Update search index
-	if err := s.index.Delete(urlPath); err != nil {
+	if err := s.unindexDocument(urlPath); err != nil {
 		log.Printf("[INDEX] Could not delete folder %s from index: %v", urlPath, err)
 	}
 
@@ -863,24 +1118,84 @@ func (s *ContentService) readAncestorsRecursive(urlPath string, ancestors []mode
 }
 
 // GetEffectivePermissions returns the effective ACL for content by checking the content's own ACL
-// and falling back to ancestor ACLs. Returns an empty slice if no ACL is found (should never
+// and falling back to ancestor ACLs. ACLs with ExtendACL set are merged with the ACL inherited
+// from the parent folder, see InheritACL. Returns an empty slice if no ACL is found (should never
 // occur in reality).
 func (s *ContentService) GetEffectivePermissions(meta model.ContentMeta, ancestorsMetas []model.UrlAndMeta) []model.AccessRule {
-	if meta.ACL != nil {
-		return *meta.ACL
+	acl, _ := s.GetEffectivePermissionsWithOrigins("", meta, ancestorsMetas)
+	return acl
+}
+
+// GetEffectivePermissionsWithOrigins returns the effective ACL like GetEffectivePermissions,
+// together with the URL of the page or folder defining each rule.
+// urlPath is the URL of the content the metadata belongs to.
+func (s *ContentService) GetEffectivePermissionsWithOrigins(urlPath string, meta model.ContentMeta, ancestorsMetas []model.UrlAndMeta) ([]model.AccessRule, []string) {
+	own := func() []string {
+		origins := make([]string, len(*meta.ACL))
+		for i := range origins {
+			origins[i] = urlPath
+		}
+		return origins
+	}
+
+	if meta.ACL != nil && !meta.ExtendACL {
+		return *meta.ACL, own()
+	}
+
+	inherited, inheritedOrigins := []model.AccessRule{}, []string{}
+	if len(ancestorsMetas) > 0 {
+		inherited, inheritedOrigins = s.GetEffectivePermissionsWithOrigins(ancestorsMetas[0].Url, ancestorsMetas[0].ContentMeta, ancestorsMetas[1:])
+	}
+
+	if meta.ACL == nil {
+		return inherited, inheritedOrigins
 	}
 
-	for i := range ancestorsMetas {
-		if ancestorsMetas[i].ACL != nil {
-			return *ancestorsMetas[i].ACL
+	acl := InheritACL(meta.ACL, true, inherited)
+	origins := own()
+	for i, rule := range inherited {
+		if !slices.ContainsFunc(*meta.ACL, func(r model.AccessRule) bool { return r.Subject == rule.Subject }) {
+			origins = append(origins, inheritedOrigins[i])
 		}
 	}
 
-	return []model.AccessRule{}
+	return acl, origins
+}
+
+// InheritACL returns the effective ACL of content with the given own ACL,
+// given the effective ACL of its parent folder:
+// - Without own ACL, the inherited ACL applies.
+// - Without extend, the own ACL replaces the inherited ACL.
+// - With extend, the own rules replace the inherited rules for the same subject, other inherited rules are kept.
+func InheritACL(acl *[]model.AccessRule, extend bool, inherited []model.AccessRule) []model.AccessRule {
+	if acl == nil {
+		return inherited
+	}
+	if !extend {
+		return *acl
+	}
+
+	merged := slices.Clone(*acl)
+	for _, rule := range inherited {
+		if !slices.ContainsFunc(*acl, func(r model.AccessRule) bool { return r.Subject == rule.Subject }) {
+			merged = append(merged, rule)
+		}
+	}
+
+	return merged
 }
 
 // ListAttic lists all attic entries (revisions) for a given page, sorted by revision number ascending.
 func (s *ContentService) ListAttic(urlPath string) ([]model.AtticEntry, error) {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	return s.listAtticLocked(urlPath)
+}
+
+// listAtticLocked lists the attic entries of a page, which must be locked by the caller.
+// Details of versions missing in the sidecar file are read from the versions, the sidecar file isn't changed.
+func (s *ContentService) listAtticLocked(urlPath string) ([]model.AtticEntry, error) {
 	pageName := path.Base(urlPath)
 	parentDir := filepath.Join("attic", filepath.Dir(urlPath))
 
@@ -920,16 +1235,149 @@ func (s *ContentService) ListAttic(urlPath string) ([]model.AtticEntry, error) {
 		return atticEntries[i].Revision < atticEntries[j].Revision
 	})
 
+	// Take details from the sidecar file, versions missing there are read from the files
+	recorded, err := s.readRevisions(urlPath)
+	if err != nil {
+		return nil, err
+	}
+	byRevision := map[int64]model.AtticEntry{}
+	for _, entry := range recorded {
+		byRevision[entry.Revision] = entry
+	}
+
+	for i := range atticEntries {
+		entry, found := byRevision[atticEntries[i].Revision]
+		if !found {
+			if entry, err = s.readRevisionFromFile(urlPath, atticEntries[i].Revision); err != nil {
+				return nil, err
+			}
+		}
+		atticEntries[i] = entry
+
+		if i > 0 {
+			atticEntries[i].Delta = entry.Size - atticEntries[i-1].Size
+		} else {
+			atticEntries[i].Delta = entry.Size
+		}
+	}
+
 	return atticEntries, nil
 }
 
+// MergePage merges the changes made to a page based on revision baseRevision
+// with the changes saved to the page in the meantime.
+// Content is merged line by line, title and tags are merged as a whole.
+// Returns the merged page and the conflicting regions, if any.
+func (s *ContentService) MergePage(urlPath string, baseRevision int64, changed model.Page) (model.Page, []model.MergeConflict, error) {
+	if !s.IsAtticPage(urlPath, baseRevision) {
+		return model.Page{}, nil, model.ErrNotFound
+	}
+
+	base, err := s.ReadPage(urlPath, &baseRevision)
+	if err != nil {
+		return model.Page{}, nil, fmt.Errorf("could not read base revision: %w", err)
+	}
+
+	current, err := s.ReadPage(urlPath, nil)
+	if err != nil {
+		return model.Page{}, nil, fmt.Errorf("could not read current page: %w", err)
+	}
+
+	// Bounded, as the content is sent by the client and might be arbitrarily large
+	lines, conflicts := diff.MergeBounded(
+		strings.Split(base.Content, "\n"),
+		strings.Split(changed.Content, "\n"),
+		strings.Split(current.Content, "\n"),
+		maxDiffLines,
+	)
+
+	merged := changed
+	merged.Content = strings.Join(lines, "\n")
+
+	// Keep title changed in the meantime unless the client changed it as well
+	if changed.Meta.Title == base.Meta.Title {
+		merged.Meta.Title = current.Meta.Title
+	}
+
+	// Apply tags added and removed by the client to current tags
+	merged.Meta.Tags = slices.DeleteFunc(slices.Clone(current.Meta.Tags), func(tag string) bool {
+		return slices.Contains(base.Meta.Tags, tag) && !slices.Contains(changed.Meta.Tags, tag)
+	})
+	for _, tag := range changed.Meta.Tags {
+		if !slices.Contains(base.Meta.Tags, tag) && !slices.Contains(merged.Meta.Tags, tag) {
+			merged.Meta.Tags = append(merged.Meta.Tags, tag)
+		}
+	}
+
+	mergeConflicts := []model.MergeConflict{}
+	for _, c := range conflicts {
+		mergeConflicts = append(mergeConflicts, model.MergeConflict{
+			Line:   c.BaseIndex + 1,
+			Base:   c.Base,
+			Client: c.Ours,
+			Server: c.Theirs,
+		})
+	}
+
+	return merged, mergeConflicts, nil
+}
+
+// RevertPage restores the content and title of a page from an attic revision.
+// Other metadata (e.g. ACL, tags) is kept. A new version is created, which records the revision it restored.
+// Returns the reverted page and the version created,
+// model.ErrNotFound if the page or revision doesn't exist
+// and model.ErrPreconditionFailed if the precondition (optional) isn't met.
+func (s *ContentService) RevertPage(urlPath string, revision int64, userID string, precondition Precondition) (model.Page, model.AtticEntry, error) {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	if !s.IsPage(urlPath) || !s.IsAtticPage(urlPath, revision) {
+		return model.Page{}, model.AtticEntry{}, model.ErrNotFound
+	}
+	if err := s.checkPrecondition(urlPath, precondition); err != nil {
+		return model.Page{}, model.AtticEntry{}, err
+	}
+
+	old, err := s.ReadPage(urlPath, &revision)
+	if err != nil {
+		return model.Page{}, model.AtticEntry{}, fmt.Errorf("could not read revision: %w", err)
+	}
+	current, err := s.ReadPage(urlPath, nil)
+	if err != nil {
+		return model.Page{}, model.AtticEntry{}, fmt.Errorf("could not read page: %w", err)
+	}
+
+	meta := current.Meta
+	meta.Title = old.Meta.Title
+	meta.RevertedTo = &revision
+
+	entry, err := s.savePageLocked(urlPath, old.Content, meta, userID, &revisionNote{}, time.Now())
+	if err != nil {
+		return model.Page{}, model.AtticEntry{}, err
+	}
+
+	page, err := s.ReadPage(urlPath, nil)
+	if err != nil {
+		return model.Page{}, model.AtticEntry{}, err
+	}
+	return page, entry, nil
+}
+
 // MovePage moves a page from sourcePath to destinationPath, including all attic entries.
-func (s *ContentService) MovePage(sourcePath, destinationPath string) error {
+// Returns model.ErrPreconditionFailed if the precondition (optional) of the page isn't met, before anything is changed.
+func (s *ContentService) MovePage(sourcePath, destinationPath string, precondition Precondition) error {
+	unlock := s.locks.LockAll(sourcePath, destinationPath)
+	defer unlock()
+
 	// Validate source exists
 	if !s.IsPage(sourcePath) {
 		return model.ErrNotFound
 	}
 
+	if err := s.checkPrecondition(sourcePath, precondition); err != nil {
+		return err
+	}
+
 	// Validate destination parent folder exists
 	if !s.IsFolder(path.Dir(destinationPath)) {
 		return model.ErrParentFolderNotFound
@@ -953,14 +1401,14 @@ func (s *ContentService) MovePage(sourcePath, destinationPath string) error {
 	}
 
 	// Update search index: delete old, add new
-	if err := s.index.Delete(sourcePath); err != nil {
+	if err := s.unindexDocument(sourcePath); err != nil {
 		log.Printf("[INDEX] Could not delete old page %s from index: %v", sourcePath, err)
 	}
 	page, err := s.ReadPage(destinationPath, nil)
 	if err != nil {
 		return fmt.Errorf("could not read moved page: %w", err)
 	}
-	if err := s.index.Index(destinationPath, page); err != nil {
+	if err := s.indexDocument(destinationPath, page); err != nil {
 		log.Printf("[INDEX] Could not index new page %s: %v", destinationPath, err)
 	}
 
@@ -968,7 +1416,11 @@ func (s *ContentService) MovePage(sourcePath, destinationPath string) error {
 }
 
 // MoveFolder moves a folder from sourcePath to destinationPath, including all content and attic entries.
-func (s *ContentService) MoveFolder(sourcePath, destinationPath string) error {
+// Returns model.ErrPreconditionFailed if the precondition (optional) of the folder isn't met, before anything is changed.
+func (s *ContentService) MoveFolder(sourcePath, destinationPath string, precondition Precondition) error {
+	unlock := s.locks.LockAll(sourcePath, destinationPath)
+	defer unlock()
+
 	// Validate source exists
 	if !s.IsFolder(sourcePath) {
 		return model.ErrNotFound
@@ -979,6 +1431,10 @@ func (s *ContentService) MoveFolder(sourcePath, destinationPath string) error {
 		return model.ErrCannotMoveRoot
 	}
 
+	if err := s.checkPrecondition(sourcePath, precondition); err != nil {
+		return err
+	}
+
 	// Validate destination doesn't already exist
 	if s.IsPage(destinationPath) || s.IsFolder(destinationPath) {
 		return model.ErrDestinationExists
@@ -1006,7 +1462,7 @@ func (s *ContentService) MoveFolder(sourcePath, destinationPath string) error {
 	}
 
 	// Index the new folder and all its contents
-	if err := s.indexFolder(destinationPath, &s.index); err != nil {
+	if err := s.indexSubtree(destinationPath); err != nil {
 		log.Println("[INDEX] Could not index new folder:", err)
 	}
 
@@ -1041,7 +1497,7 @@ func (s *ContentService) ensureParentFoldersExist(urlPath string) error {
 
 // moveAtticEntries moves all attic entries for a page from oldPath to newPath.
 func (s *ContentService) moveAtticEntries(oldPath, newPath string) error {
-	atticEntries, err := s.ListAttic(oldPath)
+	atticEntries, err := s.listAtticLocked(oldPath)
 	if err != nil {
 		// If attic directory doesn't exist, that's fine - no entries to move
 		return nil
@@ -1057,11 +1513,14 @@ func (s *ContentService) moveAtticEntries(oldPath, newPath string) error {
 		}
 	}
 
-	return nil
+	return s.moveRevisionsFile(oldPath, revisionsFile(newPath))
 }
 
 // DeleteAtticEntry deletes a single attic entry (version) for a page.
 func (s *ContentService) DeleteAtticEntry(urlPath string, revision int64) error {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
 	revStr := strconv.FormatInt(revision, 10)
 	atticPath := filepath.Join("attic", urlPath+"."+revStr+".md")
 
@@ -1069,7 +1528,11 @@ func (s *ContentService) DeleteAtticEntry(urlPath string, revision int64) error
 		return model.ErrNotFound
 	}
 
-	return s.storage.DeleteFile(atticPath)
+	if err := s.storage.DeleteFile(atticPath); err != nil {
+		return err
+	}
+
+	return s.removeRevision(urlPath, revision)
 }
 
 // ListAllPages returns the URLs of all pages in the wiki by recursively walking the pages directory.
@@ -1115,6 +1578,130 @@ func (s *ContentService) listAllPagesRecursive(urlPath string) ([]string, error)
 	return pages, nil
 }
 
+// SetSubtreeACL changes the ACLs of all pages and folders in the folder, see model.SubtreeACLMode.
+// With mode apply, acl and extend are also set on the folder itself, with mode reset they are ignored.
+// Each page and folder is read and saved while it is locked, so concurrent changes aren't overwritten.
+// If a page or folder can't be changed, the others are changed nevertheless and the failure is reported.
+// Returns the URLs of the pages and folders whose ACL changed. If dryRun is true, nothing is saved.
+// Returns model.ErrPreconditionFailed if the precondition (optional) of the folder isn't met, before anything is changed.
+func (s *ContentService) SetSubtreeACL(urlPath string, mode model.SubtreeACLMode, acl *[]model.AccessRule, extend bool, userID string, dryRun bool, precondition Precondition) ([]string, []model.SubtreeACLFailure, error) {
+	if mode == model.SubtreeACLReset {
+		acl, extend = nil, false
+	}
+
+	affected := []string{}
+	failed := []model.SubtreeACLFailure{}
+
+	if mode == model.SubtreeACLApply {
+		meta, err := s.ReadFolderMeta(urlPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dryRun {
+			if !aclEqual(meta.ACL, acl) || meta.ExtendACL != extend {
+				affected = append(affected, urlPath)
+			}
+		} else {
+			changed, err := s.updateACL(urlPath, true, acl, extend, userID, precondition)
+			if err != nil {
+				return nil, nil, err
+			}
+			if changed {
+				affected = append(affected, urlPath)
+			}
+		}
+	} else if !dryRun {
+		unlock := s.locks.Lock(urlPath)
+		err := s.checkPrecondition(urlPath, precondition)
+		unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	affected, failed = s.setSubtreeACLRecursive(urlPath, acl, extend, userID, dryRun, affected, failed)
+	return affected, failed, nil
+}
+
+func (s *ContentService) setSubtreeACLRecursive(urlPath string, acl *[]model.AccessRule, extend bool, userID string, dryRun bool, affected []string, failed []model.SubtreeACLFailure) ([]string, []model.SubtreeACLFailure) {
+	folder, err := s.ReadFolder(urlPath)
+	if err != nil {
+		return affected, append(failed, model.SubtreeACLFailure{Url: urlPath, Error: err.Error()})
+	}
+
+	for _, entry := range folder.Content {
+		if dryRun {
+			if !aclEqual(entry.ACL, acl) || entry.ExtendACL != extend {
+				affected = append(affected, entry.Url)
+			}
+		} else {
+			changed, err := s.updateACL(entry.Url, entry.IsFolder, acl, extend, userID, nil)
+			if err != nil {
+				failed = append(failed, model.SubtreeACLFailure{Url: entry.Url, Error: err.Error()})
+			} else if changed {
+				affected = append(affected, entry.Url)
+			}
+		}
+
+		if entry.IsFolder {
+			affected, failed = s.setSubtreeACLRecursive(entry.Url, acl, extend, userID, dryRun, affected, failed)
+		}
+	}
+
+	return affected, failed
+}
+
+// updateACL replaces the ACL of a page or folder, without creating a new version of pages.
+// The content is read while it is locked, so concurrent changes aren't overwritten.
+// Returns false if the ACL was unchanged.
+func (s *ContentService) updateACL(urlPath string, isFolder bool, acl *[]model.AccessRule, extend bool, userID string, precondition Precondition) (bool, error) {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	if err := s.checkPrecondition(urlPath, precondition); err != nil {
+		return false, err
+	}
+
+	if isFolder {
+		if !s.IsFolder(urlPath) {
+			return false, model.ErrNotFound
+		}
+		meta, err := s.ReadFolderMeta(urlPath)
+		if err != nil {
+			return false, err
+		}
+		if aclEqual(meta.ACL, acl) && meta.ExtendACL == extend {
+			return false, nil
+		}
+		meta.ACL, meta.ExtendACL = acl, extend
+		return true, s.saveFolderLocked(urlPath, meta)
+	}
+
+	if !s.IsPage(urlPath) {
+		return false, model.ErrNotFound
+	}
+	page, err := s.ReadPage(urlPath, nil)
+	if err != nil {
+		return false, err
+	}
+	if aclEqual(page.Meta.ACL, acl) && page.Meta.ExtendACL == extend {
+		return false, nil
+	}
+	page.Meta.ACL, page.Meta.ExtendACL = acl, extend
+	_, err = s.savePageLocked(urlPath, page.Content, page.Meta, userID, nil, time.Now())
+	return true, err
+}
+
+// aclEqual compares the subjects and operations of two ACLs, nil means inherited
+func aclEqual(a, b *[]model.AccessRule) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return slices.EqualFunc(*a, *b, func(x, y model.AccessRule) bool {
+		return x.Subject == y.Subject && slices.Equal(x.Operations, y.Operations) && slices.Equal(x.Deny, y.Deny)
+	})
+}
+
 // WriteBackup writes a complete backup ZIP archive to the provided writer.
 // The backup always includes content directories (pages, attic, trash).
 // Config and users can be optionally included via BackupOptions.
@@ -1136,11 +1723,16 @@ func (s *ContentService) WriteBackup(w io.Writer, opts BackupOptions) error {
 		}
 	}
 
-	// Optionally add users.yml
+	// Optionally add users.yml and groups.yml, as groups reference users
 	if opts.IncludeUsers {
 		if err := s.addUsersToZip(zipWriter); err != nil {
 			return fmt.Errorf("could not add users to archive: %w", err)
 		}
+		if s.storage.Exists("groups.yml") {
+			if err := s.addFileToZip(zipWriter, "groups.yml", "groups.yml"); err != nil {
+				return fmt.Errorf("could not add groups to archive: %w", err)
+			}
+		}
 	}
 
 	if err := zipWriter.Close(); err != nil {
@@ -1268,18 +1860,32 @@ func (s *ContentService) RestoreBackup(zipReader *zip.Reader) (bool, error) {
 		}
 	}
 
+	// Groups reference users, so existing groups are discarded if users are restored,
+	// even if the backup doesn't contain groups.yml
+	if hasUsers && s.storage.Exists("groups.yml") {
+		if err := s.storage.DeleteFile("groups.yml"); err != nil {
+			return false, fmt.Errorf("could not delete groups.yml: %w", err)
+		}
+	}
+
 	// Extract files from ZIP
 	for _, f := range zipReader.File {
 		if f.FileInfo().IsDir() {
 			continue
 		}
 
+		// Groups are only restored together with the users they reference
+		if f.Name == "groups.yml" && !hasUsers {
+			continue
+		}
+
 		// Only restore specific paths
 		if !strings.HasPrefix(f.Name, "pages/") &&
 			!strings.HasPrefix(f.Name, "attic/") &&
 			!strings.HasPrefix(f.Name, "trash/") &&
 			f.Name != "config.yml" &&
-			f.Name != "users.yml" {
+			f.Name != "users.yml" &&
+			f.Name != "groups.yml" {
 			continue
 		}
 
@@ -3,6 +3,9 @@ package service
 import (
 	"archive/zip"
 	"bytes"
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -74,7 +77,274 @@ func TestDeleteAtticEntry_NotFound(t *testing.T) {
 	r.ErrorIs(err, model.ErrNotFound)
 }
 
+// TestSavePage_RevisionsDontCollide tests that saving a page twice at the same time keeps both versions
+func TestSavePage_RevisionsDontCollide(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	now := time.Now()
+	r.NoError(contentService.SavePageAt("testpage", "Content v1", model.ContentMeta{Title: "Test Page"}, "", now))
+	r.NoError(contentService.SavePageAt("testpage", "Content v2", model.ContentMeta{Title: "Test Page"}, "", now))
+
+	entries, err := contentService.ListAttic("testpage")
+	r.NoError(err)
+	r.Len(entries, 2)
+	r.Equal(now.UnixMilli(), entries[0].Revision)
+	r.Equal(now.UnixMilli()+1, entries[1].Revision)
+
+	v1, err := contentService.ReadPage("testpage", &entries[0].Revision)
+	r.NoError(err)
+	r.Equal("Content v1", v1.Content)
+	v2, err := contentService.ReadPage("testpage", &entries[1].Revision)
+	r.NoError(err)
+	r.Equal("Content v2", v2.Content)
+}
+
+// TestSavePage_Precondition tests that of several concurrent saves based on the same version, only one succeeds
+func TestSavePage_Precondition(t *testing.T) {
+	r := require.New(t)
+	store := NewFsStorage(t.TempDir())
+	configService := NewConfigService(store)
+	contentService := NewContentService(store, configService)
+
+	r.NoError(contentService.SavePage("testpage", "Content v1", model.ContentMeta{Title: "Test Page"}, ""))
+	etag, err := contentService.ETag("testpage")
+	r.NoError(err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = contentService.SavePageWithSummary("testpage", "Content "+strconv.Itoa(i), model.ContentMeta{Title: "Test Page"}, "", "", false,
+				func(current string) bool { return current == etag })
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			r.ErrorIs(err, model.ErrPreconditionFailed)
+		}
+	}
+	r.Equal(1, succeeded)
+
+	entries, err := contentService.ListAttic("testpage")
+	r.NoError(err)
+	r.Len(entries, 2)
+}
+
+// TestMove_Precondition tests that pages and folders are only moved if they still match the precondition
+func TestMove_Precondition(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	r.NoError(contentService.SavePage("page", "Content v1", model.ContentMeta{}, ""))
+	r.NoError(contentService.CreateFolder("folder", model.ContentMeta{}))
+	pageETag, err := contentService.ETag("page")
+	r.NoError(err)
+	folderETag, err := contentService.ETag("folder")
+	r.NoError(err)
+
+	// Changed in the meantime
+	r.NoError(contentService.SavePage("page", "Content v2", model.ContentMeta{}, ""))
+	r.NoError(contentService.SaveFolder("folder", model.ContentMeta{Title: "Folder"}, nil))
+
+	err = contentService.MovePage("page", "moved-page", func(current string) bool { return current == pageETag })
+	r.ErrorIs(err, model.ErrPreconditionFailed)
+	r.True(contentService.IsPage("page"))
+	r.False(contentService.IsPage("moved-page"))
+
+	err = contentService.MoveFolder("folder", "moved-folder", func(current string) bool { return current == folderETag })
+	r.ErrorIs(err, model.ErrPreconditionFailed)
+	r.True(contentService.IsFolder("folder"))
+	r.False(contentService.IsFolder("moved-folder"))
+
+	// Current version
+	pageETag, err = contentService.ETag("page")
+	r.NoError(err)
+	r.NoError(contentService.MovePage("page", "moved-page", func(current string) bool { return current == pageETag }))
+	r.True(contentService.IsPage("moved-page"))
+}
+
+// TestCreateRevision_Exclusive tests that concurrent saves at the same time reserve different revisions,
+// even without the lock of the page
+func TestCreateRevision_Exclusive(t *testing.T) {
+	r := require.New(t)
+	store := NewFsStorage(t.TempDir())
+	configService := NewConfigService(store)
+	contentService := NewContentService(store, configService)
+
+	now := time.Now()
+	revisions := make([]int64, 10)
+	var wg sync.WaitGroup
+	for i := range revisions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			revisions[i], err = contentService.createRevision("testpage", now, []byte("Content "+strconv.Itoa(i)))
+			r.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(revisions)
+	r.Equal(now.UnixMilli(), revisions[0])
+	r.Len(slices.Compact(revisions), 10)
+}
+
+// TestListAttic_LegacyRevisions tests that versions named with Unix timestamps in seconds are still listed in order
+func TestListAttic_LegacyRevisions(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	legacyTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	r.NoError(mock.WriteFile("attic/testpage."+strconv.FormatInt(legacyTime.Unix(), 10)+".md", []byte("Legacy content")))
+	r.NoError(contentService.SavePage("testpage", "Content", model.ContentMeta{Title: "Test Page"}, ""))
+
+	entries, err := contentService.ListAttic("testpage")
+	r.NoError(err)
+	r.Len(entries, 2)
+	r.Equal(legacyTime.Unix(), entries[0].Revision)
+	r.True(RevisionTime(entries[0].Revision).Equal(legacyTime))
+	r.WithinDuration(time.Now(), RevisionTime(entries[1].Revision), time.Minute)
+
+	legacy, err := contentService.ReadPage("testpage", &entries[0].Revision)
+	r.NoError(err)
+	r.Equal("Legacy content", legacy.Content)
+}
+
+// TestListAttic_ReadOnly tests that listing versions doesn't write the sidecar file, missing details are added on the next save
+func TestListAttic_ReadOnly(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	legacyTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	r.NoError(mock.WriteFile("pages/testpage.md", []byte("Legacy content")))
+	r.NoError(mock.WriteFile("attic/testpage."+strconv.FormatInt(legacyTime.Unix(), 10)+".md", []byte("Legacy content")))
+
+	entries, err := contentService.ListAttic("testpage")
+	r.NoError(err)
+	r.Len(entries, 1)
+	r.False(mock.Exists(revisionsFile("testpage")))
+
+	r.NoError(contentService.SavePage("testpage", "Content", model.ContentMeta{Title: "Test Page"}, ""))
+	recorded, err := contentService.readRevisions("testpage")
+	r.NoError(err)
+	r.Len(recorded, 2)
+}
+
+// TestSavePage_ConcurrentSummaries tests that concurrent saves don't lose the details of their versions
+func TestSavePage_ConcurrentSummaries(t *testing.T) {
+	r := require.New(t)
+	store := NewFsStorage(t.TempDir())
+	configService := NewConfigService(store)
+	contentService := NewContentService(store, configService)
+
+	// Revision -> summary of the versions returned by the saves
+	var versions sync.Map
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := "Change " + strconv.Itoa(i)
+			version, err := contentService.SavePageWithSummary("testpage", summary, model.ContentMeta{}, "", summary, false, nil)
+			r.NoError(err)
+			versions.Store(version.Revision, version.Summary)
+		}()
+	}
+	wg.Wait()
+
+	recorded, err := contentService.readRevisions("testpage")
+	r.NoError(err)
+	r.Len(recorded, 10)
+	for _, entry := range recorded {
+		r.NotEmpty(entry.Summary)
+
+		// Each save returns its own version
+		summary, ok := versions.Load(entry.Revision)
+		r.True(ok)
+		r.Equal(entry.Summary, summary)
+	}
+}
+
 // TestWriteBackup_ContentOnly tests backup of content directories without config/users
+// TestEnsureID tests that content created before IDs were introduced gets its ID on first access
+func TestEnsureID(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	r.NoError(mock.WriteFile("pages/_index.md", []byte("---\nacl: []\n---\n")))
+	r.NoError(mock.WriteFile("pages/folder/_index.md", []byte("---\ntitle: Folder\n---\n")))
+	legacyPage := []byte("---\ntitle: Page\n---\nContent")
+	r.NoError(mock.WriteFile("pages/folder/page.md", legacyPage))
+	r.NoError(mock.WriteFile("pages/other.md", []byte("---\nid: existing\n---\nOther")))
+
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	// Files aren't changed on startup
+	stored, err := mock.ReadFile("pages/folder/page.md")
+	r.NoError(err)
+	r.Equal(legacyPage, stored)
+
+	folderID, err := contentService.EnsureID("folder")
+	r.NoError(err)
+	r.Len(folderID, contentIDLength)
+	folder, err := contentService.ReadFolder("folder")
+	r.NoError(err)
+	r.Equal(folderID, folder.Meta.ID)
+	r.Equal("Folder", folder.Meta.Title)
+
+	pageID, err := contentService.EnsureID("folder/page")
+	r.NoError(err)
+	r.Len(pageID, contentIDLength)
+	page, err := contentService.ReadPage("folder/page", nil)
+	r.NoError(err)
+	r.Equal(pageID, page.Meta.ID)
+	r.Equal("Content", page.Content)
+	r.True(page.Meta.ModifiedAt.IsZero())
+
+	// Assigned IDs are kept
+	id, err := contentService.EnsureID("folder/page")
+	r.NoError(err)
+	r.Equal(pageID, id)
+	id, err = contentService.EnsureID("other")
+	r.NoError(err)
+	r.Equal("existing", id)
+
+	// No versions are created
+	attic, err := contentService.ListAttic("folder/page")
+	r.NoError(err)
+	r.Empty(attic)
+
+	// The index contains the assigned IDs
+	item, err := contentService.ResolveID(pageID)
+	r.NoError(err)
+	r.Equal("folder/page", item.Url)
+
+	// The root folder has no ID
+	id, err = contentService.EnsureID("")
+	r.NoError(err)
+	r.Empty(id)
+
+	_, err = contentService.EnsureID("missing")
+	r.ErrorIs(err, model.ErrNotFound)
+}
+
 func TestWriteBackup_ContentOnly(t *testing.T) {
 	r := require.New(t)
 	mock := newMockStorage()
@@ -138,6 +408,7 @@ func TestWriteBackup_WithConfigAndUsers(t *testing.T) {
 
 	// Setup users
 	mock.files["users.yml"] = []byte("- id: user1\n  username: testuser\n")
+	mock.files["groups.yml"] = []byte("- id: group1\n  name: Editors\n  members: [user1]\n")
 
 	contentService := NewContentService(mock, configService)
 
@@ -164,6 +435,7 @@ func TestWriteBackup_WithConfigAndUsers(t *testing.T) {
 
 	r.True(fileNames["config.yml"], "should have config")
 	r.True(fileNames["users.yml"], "should have users")
+	r.True(fileNames["groups.yml"], "should have groups")
 
 	// Verify config has JWT secret stripped
 	for _, f := range zipReader.File {
@@ -264,6 +536,7 @@ func TestRestoreBackup_WithUsers(t *testing.T) {
 	err = dstConfigService.Write(dstCfg)
 	r.NoError(err)
 	destSecret := dstCfg.JwtSecret
+	dstMock.files["groups.yml"] = []byte("- id: group1\n  name: Stale\n  members: [user2]\n")
 
 	dstService := NewContentService(dstMock, dstConfigService)
 
@@ -280,6 +553,9 @@ func TestRestoreBackup_WithUsers(t *testing.T) {
 	r.NoError(err)
 	r.Contains(string(usersData), "testuser")
 
+	// Verify groups of the replaced users were removed, as the backup doesn't contain groups.yml
+	r.False(dstMock.Exists("groups.yml"))
+
 	// Verify JWT secret was regenerated (not the old one from backup or the dest one)
 	config, err := dstConfigService.Read()
 	r.NoError(err)
@@ -370,3 +646,75 @@ func TestListAllPages(t *testing.T) {
 	r.Contains(pages, "folder/page3")
 	r.Contains(pages, "folder/subfolder/page4")
 }
+
+// TestSetSubtreeACL_Failures tests that failures are reported and don't stop changing the other content
+func TestSetSubtreeACL_Failures(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	acl := []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead}}}
+	r.NoError(contentService.CreateFolder("folder", model.ContentMeta{}))
+	r.NoError(contentService.CreateFolder("folder/sub", model.ContentMeta{}))
+	r.NoError(contentService.CreateFolder("folder/sub/broken", model.ContentMeta{}))
+	r.NoError(mock.WriteFile("pages/folder/sub/broken/_index.md", []byte("---\nacl: [\n---\n")))
+	r.NoError(contentService.SavePage("folder/page", "Content", model.ContentMeta{}, ""))
+
+	affected, failed, err := contentService.SetSubtreeACL("folder", model.SubtreeACLApply, &acl, false, "", false, nil)
+	r.NoError(err)
+	r.ElementsMatch([]string{"folder", "folder/page", "folder/sub"}, affected)
+	r.Len(failed, 1)
+	r.Equal("folder/sub", failed[0].Url)
+
+	page, err := contentService.ReadPage("folder/page", nil)
+	r.NoError(err)
+	r.Equal(&acl, page.Meta.ACL)
+
+	// The precondition of the folder is checked before anything is changed
+	_, _, err = contentService.SetSubtreeACL("folder", model.SubtreeACLReset, nil, false, "", false,
+		func(string) bool { return false })
+	r.ErrorIs(err, model.ErrPreconditionFailed)
+	page, err = contentService.ReadPage("folder/page", nil)
+	r.NoError(err)
+	r.NotNil(page.Meta.ACL)
+}
+
+func TestGetEffectivePermissions(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+
+	allRead := model.AccessRule{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead}}
+	userWrite := model.AccessRule{Subject: "user:a", Operations: []model.AccessOp{model.AccessOpWrite}}
+	userDeny := model.AccessRule{Subject: "user:a", Deny: []model.AccessOp{model.AccessOpRead}}
+	anonRead := model.AccessRule{Subject: "anonymous", Operations: []model.AccessOp{model.AccessOpRead}}
+
+	root := model.UrlAndMeta{Url: "", ContentMeta: model.ContentMeta{ACL: &[]model.AccessRule{allRead}}}
+
+	// Own ACL replaces inherited ACL
+	acl := contentService.GetEffectivePermissions(model.ContentMeta{ACL: &[]model.AccessRule{userWrite}}, []model.UrlAndMeta{root})
+	r.Equal([]model.AccessRule{userWrite}, acl)
+
+	// Without own ACL, nearest ACL is inherited
+	acl = contentService.GetEffectivePermissions(model.ContentMeta{}, []model.UrlAndMeta{{Url: "folder"}, root})
+	r.Equal([]model.AccessRule{allRead}, acl)
+
+	// Extended ACLs are merged along the ancestors, own rules replace inherited rules for the same subject
+	folder := model.UrlAndMeta{Url: "folder", ContentMeta: model.ContentMeta{ACL: &[]model.AccessRule{userWrite}, ExtendACL: true}}
+	acl = contentService.GetEffectivePermissions(model.ContentMeta{ACL: &[]model.AccessRule{userDeny, anonRead}, ExtendACL: true}, []model.UrlAndMeta{folder, root})
+	r.Equal([]model.AccessRule{userDeny, anonRead, allRead}, acl)
+
+	// Origins of the rules
+	acl, origins := contentService.GetEffectivePermissionsWithOrigins("folder/page", model.ContentMeta{ACL: &[]model.AccessRule{userDeny, anonRead}, ExtendACL: true}, []model.UrlAndMeta{folder, root})
+	r.Equal([]model.AccessRule{userDeny, anonRead, allRead}, acl)
+	r.Equal([]string{"folder/page", "folder/page", ""}, origins)
+
+	_, origins = contentService.GetEffectivePermissionsWithOrigins("folder/page", model.ContentMeta{}, []model.UrlAndMeta{folder, root})
+	r.Equal([]string{"folder", ""}, origins)
+
+	// Extending ACL without ancestors
+	acl = contentService.GetEffectivePermissions(model.ContentMeta{ACL: &[]model.AccessRule{userWrite}, ExtendACL: true}, nil)
+	r.Equal([]model.AccessRule{userWrite}, acl)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/tfabritius/plainpage/libs/diff"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// maxDiffLines is the maximum number of lines compared line by line, apart from unchanged lines at the start and end.
+// Larger changes are shown as replacing all lines in between, and conflict as a whole when merging.
+const maxDiffLines = 2000
+
+// maxDiffWords is the maximum number of words of two lines compared word by word
+const maxDiffWords = 1000
+
+// DiffRevisions compares the revision from of a page with the revision to,
+// or with the current version if to is nil.
+// Returns model.ErrNotFound if the page or one of the revisions doesn't exist.
+func (s *ContentService) DiffRevisions(urlPath string, from int64, to *int64) (model.RevisionDiff, error) {
+	if !s.IsPage(urlPath) || !s.IsAtticPage(urlPath, from) || (to != nil && !s.IsAtticPage(urlPath, *to)) {
+		return model.RevisionDiff{}, model.ErrNotFound
+	}
+
+	oldPage, err := s.ReadPage(urlPath, &from)
+	if err != nil {
+		return model.RevisionDiff{}, err
+	}
+	newPage, err := s.ReadPage(urlPath, to)
+	if err != nil {
+		return model.RevisionDiff{}, err
+	}
+
+	lines, compared := diffLines(oldPage.Content, newPage.Content)
+
+	return model.RevisionDiff{
+		From:       from,
+		To:         to,
+		Lines:      lines,
+		Simplified: !compared,
+		Meta:       diffMeta(oldPage.Meta, newPage.Meta),
+	}, nil
+}
+
+// diffLines computes the line diff of two texts, including word diffs of changed lines.
+// Returns false if the texts differ in too many lines to be compared line by line.
+func diffLines(oldText, newText string) ([]model.DiffLine, bool) {
+	edits, compared := diff.DiffBounded(splitLines(oldText), splitLines(newText), maxDiffLines)
+
+	lines := []model.DiffLine{}
+	for i := 0; i < len(edits); {
+		if edits[i].Op == diff.Equal {
+			lines = append(lines, model.DiffLine{
+				Type: model.DiffEqual, Text: edits[i].Text,
+				OldLine: edits[i].AIndex + 1, NewLine: edits[i].BIndex + 1,
+			})
+			i++
+			continue
+		}
+
+		// Collect block of changed lines
+		deleted, inserted := []diff.Edit{}, []diff.Edit{}
+		for ; i < len(edits) && edits[i].Op != diff.Equal; i++ {
+			if edits[i].Op == diff.Delete {
+				deleted = append(deleted, edits[i])
+			} else {
+				inserted = append(inserted, edits[i])
+			}
+		}
+
+		// Lines replacing each other are compared word by word
+		oldWords := make([][]model.DiffSegment, len(deleted))
+		newWords := make([][]model.DiffSegment, len(inserted))
+		for j := 0; compared && j < len(deleted) && j < len(inserted); j++ {
+			oldWords[j], newWords[j] = diffWords(deleted[j].Text, inserted[j].Text)
+		}
+
+		for j, e := range deleted {
+			lines = append(lines, model.DiffLine{Type: model.DiffDelete, Text: e.Text, OldLine: e.AIndex + 1, Words: oldWords[j]})
+		}
+		for j, e := range inserted {
+			lines = append(lines, model.DiffLine{Type: model.DiffInsert, Text: e.Text, NewLine: e.BIndex + 1, Words: newWords[j]})
+		}
+	}
+
+	return lines, compared
+}
+
+// splitLines splits a text into lines, an empty text has no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffWords computes the word diff of two lines.
+// Returns the segments of the old line (equal or deleted) and of the new line (equal or inserted),
+// or nothing if the lines differ in too many words.
+func diffWords(oldLine, newLine string) ([]model.DiffSegment, []model.DiffSegment) {
+	oldSegments, newSegments := []model.DiffSegment{}, []model.DiffSegment{}
+
+	appendSegment := func(segments []model.DiffSegment, t model.DiffType, text string) []model.DiffSegment {
+		if n := len(segments); n > 0 && segments[n-1].Type == t {
+			segments[n-1].Text += text
+			return segments
+		}
+		return append(segments, model.DiffSegment{Type: t, Text: text})
+	}
+
+	edits, compared := diff.DiffBounded(diff.SplitWords(oldLine), diff.SplitWords(newLine), maxDiffWords)
+	if !compared {
+		return nil, nil
+	}
+
+	for _, e := range edits {
+		switch e.Op {
+		case diff.Equal:
+			oldSegments = appendSegment(oldSegments, model.DiffEqual, e.Text)
+			newSegments = appendSegment(newSegments, model.DiffEqual, e.Text)
+		case diff.Delete:
+			oldSegments = appendSegment(oldSegments, model.DiffDelete, e.Text)
+		case diff.Insert:
+			newSegments = appendSegment(newSegments, model.DiffInsert, e.Text)
+		}
+	}
+
+	return oldSegments, newSegments
+}
+
+// diffMeta compares title, tags and ACL of two versions
+func diffMeta(oldMeta, newMeta model.ContentMeta) model.MetaDiff {
+	metaDiff := model.MetaDiff{}
+
+	if oldMeta.Title != newMeta.Title {
+		metaDiff.Title = &model.TitleChange{Old: oldMeta.Title, New: newMeta.Title}
+	}
+
+	for _, tag := range newMeta.Tags {
+		if !slices.Contains(oldMeta.Tags, tag) {
+			metaDiff.AddedTags = append(metaDiff.AddedTags, tag)
+		}
+	}
+	for _, tag := range oldMeta.Tags {
+		if !slices.Contains(newMeta.Tags, tag) {
+			metaDiff.RemovedTags = append(metaDiff.RemovedTags, tag)
+		}
+	}
+
+	if !reflect.DeepEqual(oldMeta.ACL, newMeta.ACL) || oldMeta.ExtendACL != newMeta.ExtendACL {
+		metaDiff.ACL = &model.ACLChange{
+			Old:       oldMeta.ACL,
+			New:       newMeta.ACL,
+			OldExtend: oldMeta.ExtendACL,
+			NewExtend: newMeta.ExtendACL,
+		}
+	}
+
+	return metaDiff
+}
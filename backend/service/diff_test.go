@@ -0,0 +1,91 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func TestDiffLines(t *testing.T) {
+	lines, compared := diffLines("first\nsecond line\nthird\nfourth", "first\nchanged line\nthird\nnew\nfourth")
+	assert.True(t, compared)
+
+	assert.Equal(t, []model.DiffLine{
+		{Type: model.DiffEqual, Text: "first", OldLine: 1, NewLine: 1},
+		{Type: model.DiffDelete, Text: "second line", OldLine: 2, Words: []model.DiffSegment{
+			{Type: model.DiffDelete, Text: "second"},
+			{Type: model.DiffEqual, Text: " line"},
+		}},
+		{Type: model.DiffInsert, Text: "changed line", NewLine: 2, Words: []model.DiffSegment{
+			{Type: model.DiffInsert, Text: "changed"},
+			{Type: model.DiffEqual, Text: " line"},
+		}},
+		{Type: model.DiffEqual, Text: "third", OldLine: 3, NewLine: 3},
+		{Type: model.DiffInsert, Text: "new", NewLine: 4},
+		{Type: model.DiffEqual, Text: "fourth", OldLine: 4, NewLine: 5},
+	}, lines)
+
+	lines, _ = diffLines("", "")
+	assert.Equal(t, []model.DiffLine{}, lines)
+	lines, _ = diffLines("", "new")
+	assert.Equal(t, []model.DiffLine{{Type: model.DiffInsert, Text: "new", NewLine: 1}}, lines)
+}
+
+func TestDiffLines_TooManyChanges(t *testing.T) {
+	oldLines, newLines := []string{"first"}, []string{"first"}
+	for i := 0; i < maxDiffLines; i++ {
+		oldLines = append(oldLines, "old")
+		newLines = append(newLines, "new")
+	}
+
+	lines, compared := diffLines(strings.Join(oldLines, "\n"), strings.Join(newLines, "\n"))
+	assert.False(t, compared)
+	assert.Len(t, lines, 1+2*maxDiffLines)
+	assert.Equal(t, model.DiffLine{Type: model.DiffEqual, Text: "first", OldLine: 1, NewLine: 1}, lines[0])
+	assert.Equal(t, model.DiffLine{Type: model.DiffDelete, Text: "old", OldLine: 2}, lines[1])
+	assert.Equal(t, model.DiffLine{Type: model.DiffInsert, Text: "new", NewLine: 2}, lines[1+maxDiffLines])
+}
+
+func TestDiffWords(t *testing.T) {
+	oldSegments, newSegments := diffWords("The quick brown fox.", "The slow brown fox!")
+
+	assert.Equal(t, []model.DiffSegment{
+		{Type: model.DiffEqual, Text: "The "},
+		{Type: model.DiffDelete, Text: "quick"},
+		{Type: model.DiffEqual, Text: " brown fox"},
+		{Type: model.DiffDelete, Text: "."},
+	}, oldSegments)
+	assert.Equal(t, []model.DiffSegment{
+		{Type: model.DiffEqual, Text: "The "},
+		{Type: model.DiffInsert, Text: "slow"},
+		{Type: model.DiffEqual, Text: " brown fox"},
+		{Type: model.DiffInsert, Text: "!"},
+	}, newSegments)
+}
+
+func TestDiffWords_TooManyChanges(t *testing.T) {
+	oldSegments, newSegments := diffWords(strings.Repeat("old ", maxDiffWords), strings.Repeat("new ", maxDiffWords))
+	assert.Nil(t, oldSegments)
+	assert.Nil(t, newSegments)
+}
+
+func TestDiffMeta(t *testing.T) {
+	acl := []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead}}}
+
+	assert.Equal(t, model.MetaDiff{}, diffMeta(
+		model.ContentMeta{Title: "Title", Tags: []string{"a"}, ACL: &acl},
+		model.ContentMeta{Title: "Title", Tags: []string{"a"}, ACL: &[]model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead}}}},
+	))
+
+	assert.Equal(t, model.MetaDiff{
+		Title:       &model.TitleChange{Old: "Old", New: "New"},
+		AddedTags:   []string{"c"},
+		RemovedTags: []string{"a"},
+		ACL:         &model.ACLChange{Old: nil, New: &acl},
+	}, diffMeta(
+		model.ContentMeta{Title: "Old", Tags: []string{"a", "b"}},
+		model.ContentMeta{Title: "New", Tags: []string{"b", "c"}, ACL: &acl},
+	))
+}
@@ -71,6 +71,31 @@ func (fss *fsStorage) WriteFile(fsPath string, content []byte) error {
 	return nil
 }
 
+func (fss *fsStorage) CreateFile(fsPath string, content []byte) error {
+	fsPath = filepath.Join(fss.DataDir, fsPath)
+
+	if err := fss.createDir(fsPath); err != nil {
+		return fmt.Errorf("could not createDir: %w", err)
+	}
+
+	// O_EXCL makes creating the file atomic, so it can be used to reserve a file name
+	f, err := os.OpenFile(fsPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create file: %w", err)
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write file: %w", err)
+	}
+
+	return nil
+}
+
 func (fss *fsStorage) DeleteFile(fsPath string) error {
 	fsPath = filepath.Join(fss.DataDir, fsPath)
 	err := os.Remove(fsPath)
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tfabritius/plainpage/libs/utils"
+	"github.com/tfabritius/plainpage/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Groups are stored in groups.yml next to users.yml and are managed by the UserService,
+// as they are checked together with users when evaluating ACLs.
+// As ACL checks need them often, groups are cached in memory after being read once.
+
+func (s *UserService) ReadAllGroups() ([]model.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readAllGroupsUnlocked()
+}
+
+// readAllGroupsUnlocked returns a copy of the cached groups, reading groups.yml if needed.
+// Callers may modify the returned groups.
+func (s *UserService) readAllGroupsUnlocked() ([]model.Group, error) {
+	// Readers run concurrently under s.mu.RLock, so the cache has its own lock
+	s.groupsMu.Lock()
+	defer s.groupsMu.Unlock()
+
+	if s.groups == nil {
+		groups, err := s.loadGroups()
+		if err != nil {
+			return nil, err
+		}
+		s.groups = groups
+	}
+
+	return cloneGroups(s.groups), nil
+}
+
+func (s *UserService) loadGroups() ([]model.Group, error) {
+	if !s.storage.Exists("groups.yml") {
+		return []model.Group{}, nil
+	}
+
+	bytes, err := s.storage.ReadFile("groups.yml")
+	if err != nil {
+		return nil, fmt.Errorf("could not read groups.yml: %w", err)
+	}
+
+	groups := []model.Group{}
+	if err := yaml.Unmarshal(bytes, &groups); err != nil {
+		return nil, fmt.Errorf("could not parse YAML: %w", err)
+	}
+
+	return groups, nil
+}
+
+func (s *UserService) saveAllGroupsUnlocked(groups []model.Group) error {
+	bytes, err := yaml.Marshal(&groups)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	s.groupsMu.Lock()
+	defer s.groupsMu.Unlock()
+
+	if err := s.storage.WriteFile("groups.yml", bytes); err != nil {
+		// The file might have been written partially, so read it again next time
+		s.groups = nil
+		return fmt.Errorf("could not write groups.yml: %w", err)
+	}
+
+	s.groups = cloneGroups(groups)
+
+	return nil
+}
+
+// InvalidateGroups discards the cached groups, so they are read from groups.yml again.
+// Needs to be called after groups.yml was changed in the storage directly, e.g. by restoring a backup.
+func (s *UserService) InvalidateGroups() {
+	s.groupsMu.Lock()
+	defer s.groupsMu.Unlock()
+	s.groups = nil
+}
+
+func cloneGroups(groups []model.Group) []model.Group {
+	clone := make([]model.Group, len(groups))
+	for i, g := range groups {
+		clone[i] = g
+		clone[i].Members = slices.Clone(g.Members)
+	}
+	return clone
+}
+
+func (s *UserService) GetGroupById(id string) (model.Group, error) {
+	groups, err := s.ReadAllGroups()
+	if err != nil {
+		return model.Group{}, fmt.Errorf("could not read groups: %w", err)
+	}
+
+	if group := filterGroupById(groups, id); group != nil {
+		return *group, nil
+	}
+
+	return model.Group{}, model.ErrNotFound
+}
+
+func filterGroupById(groups []model.Group, id string) *model.Group {
+	for i := range groups {
+		if groups[i].ID == id {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+// validateGroupUnlocked checks that the group has a unique name and all members exist.
+// Duplicate members are removed.
+func (s *UserService) validateGroupUnlocked(groups []model.Group, group *model.Group) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" {
+		return model.ErrInvalidGroupName
+	}
+
+	for _, g := range groups {
+		if g.ID != group.ID && strings.EqualFold(g.Name, group.Name) {
+			return model.ErrGroupExistsAlready
+		}
+	}
+
+	users, err := s.readAllUnlocked()
+	if err != nil {
+		return err
+	}
+
+	members := []string{}
+	for _, userID := range group.Members {
+		if s.filterById(users, userID) == nil {
+			return fmt.Errorf("%w: %s", model.ErrInvalidGroupMember, userID)
+		}
+		if !slices.Contains(members, userID) {
+			members = append(members, userID)
+		}
+	}
+	group.Members = members
+
+	return nil
+}
+
+func (s *UserService) CreateGroup(name string, members []string) (model.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, err := s.readAllGroupsUnlocked()
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	id, err := utils.GenerateRandomString(6)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	group := model.Group{
+		ID:      id,
+		Name:    name,
+		Members: members,
+	}
+
+	if err := s.validateGroupUnlocked(groups, &group); err != nil {
+		return model.Group{}, err
+	}
+
+	if err := s.saveAllGroupsUnlocked(append(groups, group)); err != nil {
+		return model.Group{}, err
+	}
+
+	return group, nil
+}
+
+func (s *UserService) SaveGroup(group model.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, err := s.readAllGroupsUnlocked()
+	if err != nil {
+		return fmt.Errorf("could not read groups: %w", err)
+	}
+
+	existingGroup := filterGroupById(groups, group.ID)
+	if existingGroup == nil {
+		return model.ErrNotFound
+	}
+
+	if err := s.validateGroupUnlocked(groups, &group); err != nil {
+		return err
+	}
+
+	existingGroup.Name = group.Name
+	existingGroup.Members = group.Members
+
+	if err := s.saveAllGroupsUnlocked(groups); err != nil {
+		return fmt.Errorf("could not save groups: %w", err)
+	}
+
+	return nil
+}
+
+func (s *UserService) DeleteGroup(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, err := s.readAllGroupsUnlocked()
+	if err != nil {
+		return fmt.Errorf("could not read groups: %w", err)
+	}
+
+	if filterGroupById(groups, id) == nil {
+		return model.ErrNotFound
+	}
+
+	groups = slices.DeleteFunc(groups, func(g model.Group) bool { return g.ID == id })
+
+	if err := s.saveAllGroupsUnlocked(groups); err != nil {
+		return fmt.Errorf("could not save groups: %w", err)
+	}
+
+	return nil
+}
+
+// GroupIDsOfUser returns the IDs of all groups the user is a member of
+func (s *UserService) GroupIDsOfUser(userID string) ([]string, error) {
+	groups, err := s.ReadAllGroups()
+	if err != nil {
+		return nil, fmt.Errorf("could not read groups: %w", err)
+	}
+
+	ids := []string{}
+	for _, group := range groups {
+		if slices.Contains(group.Members, userID) {
+			ids = append(ids, group.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// removeMemberUnlocked removes the user from all groups
+func (s *UserService) removeMemberUnlocked(userID string) error {
+	groups, err := s.readAllGroupsUnlocked()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i := range groups {
+		if slices.Contains(groups[i].Members, userID) {
+			groups[i].Members = slices.DeleteFunc(groups[i].Members, func(id string) bool { return id == userID })
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return s.saveAllGroupsUnlocked(groups)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/tfabritius/plainpage/libs/utils"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// contentIDLength is the number of characters of page and folder IDs
+const contentIDLength = 12
+
+// newContentID generates a random ID for a page or folder
+func newContentID() (string, error) {
+	return utils.GenerateRandomString(contentIDLength)
+}
+
+// contentID returns the ID stored in the existing file at fsPath,
+// or a new ID if the file doesn't exist or doesn't have an ID yet
+func (s *ContentService) contentID(fsPath string) (string, error) {
+	if s.storage.Exists(fsPath) {
+		bytes, err := s.storage.ReadFile(fsPath)
+		if err != nil {
+			return "", err
+		}
+		if meta, _, err := parseFrontMatter(string(bytes)); err == nil && meta.ID != "" {
+			return meta.ID, nil
+		}
+	}
+
+	return newContentID()
+}
+
+// EnsureID returns the ID of a page or folder. Content created before IDs were introduced
+// gets its ID assigned on first access, without creating a version or changing the modification metadata.
+// The root folder has no ID. Returns model.ErrNotFound if there is no page or folder at urlPath.
+func (s *ContentService) EnsureID(urlPath string) (string, error) {
+	unlock := s.locks.Lock(urlPath)
+	defer unlock()
+
+	var fsPath string
+	var isFolder bool
+	if s.IsPage(urlPath) {
+		fsPath = filepath.Join("pages", urlPath+".md")
+	} else if s.IsFolder(urlPath) {
+		if urlPath == "" {
+			return "", nil
+		}
+		fsPath = filepath.Join("pages", urlPath, "_index.md")
+		isFolder = true
+	} else {
+		return "", model.ErrNotFound
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return "", err
+	}
+	meta, content, err := parseFrontMatter(string(bytes))
+	if err != nil {
+		return "", fmt.Errorf("could not parse frontmatter of %s: %w", fsPath, err)
+	}
+	if meta.ID != "" {
+		return meta.ID, nil
+	}
+
+	if meta.ID, err = newContentID(); err != nil {
+		return "", err
+	}
+
+	serialized, err := serializeFrontMatter(meta, content)
+	if err != nil {
+		return "", fmt.Errorf("could not serialize frontmatter: %w", err)
+	}
+	if err := s.storage.WriteFile(fsPath, []byte(serialized)); err != nil {
+		return "", fmt.Errorf("could not write file: %w", err)
+	}
+
+	// Update search index, so the content can be found by its ID
+	var doc any = model.Page{Url: urlPath, Content: content, Meta: meta}
+	if isFolder {
+		doc = model.Folder{Url: urlPath, Meta: meta}
+	}
+	if err := s.indexDocument(urlPath, doc); err != nil {
+		log.Printf("[INDEX] Could not update %s in index: %v", urlPath, err)
+	}
+
+	return meta.ID, nil
+}
+
+// ResolveID returns the page or folder with the given ID, including its effective ACL.
+// Returns model.ErrNotFound if there is none.
+func (s *ContentService) ResolveID(id string) (model.ContentSummary, error) {
+	q := bleve.NewTermQuery(id)
+	q.SetField("meta.id")
+
+	items, err := s.FindContent(q, 1)
+	if err != nil {
+		return model.ContentSummary{}, err
+	}
+	if len(items) == 0 {
+		return model.ContentSummary{}, model.ErrNotFound
+	}
+
+	return items[0], nil
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/tfabritius/plainpage/libs/markdown"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// schemeRegex matches the scheme of absolute URLs like https: or mailto:
+var schemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// ParseLinks returns the URLs of all pages and folders the page links to, without duplicates.
+// Relative links are resolved against the page's URL, external links and links to the
+// root folder or to application routes (e.g. /_search) are ignored.
+func ParseLinks(urlPath, content string) []string {
+	links := []string{}
+	for _, destination := range markdown.Links(content) {
+		if target, ok := resolveLink(urlPath, destination); ok && !slices.Contains(links, target) {
+			links = append(links, target)
+		}
+	}
+	return links
+}
+
+// resolveLink resolves a link destination found on the page urlPath to the URL of a page or folder
+func resolveLink(urlPath, destination string) (string, bool) {
+	if schemeRegex.MatchString(destination) || strings.HasPrefix(destination, "//") {
+		// External link
+		return "", false
+	}
+
+	// Remove fragment and query
+	if i := strings.IndexAny(destination, "#?"); i >= 0 {
+		destination = destination[:i]
+	}
+	if destination == "" {
+		// Link within the page
+		return "", false
+	}
+
+	destination, err := url.PathUnescape(destination)
+	if err != nil {
+		return "", false
+	}
+
+	// Relative links are resolved by the browser against the page's URL
+	if !strings.HasPrefix(destination, "/") {
+		destination = path.Join(path.Dir("/"+urlPath), destination)
+	}
+	target := strings.Trim(path.Clean(destination), "/")
+
+	if target == "" {
+		return "", false
+	}
+	for _, segment := range strings.Split(target, "/") {
+		if strings.HasPrefix(segment, "_") {
+			return "", false
+		}
+	}
+
+	return target, true
+}
+
+// OutgoingLinks returns the URLs of all pages and folders the page links to
+func (s *ContentService) OutgoingLinks(urlPath string) ([]string, error) {
+	if !s.IsPage(urlPath) {
+		return []string{}, nil
+	}
+
+	page, err := s.ReadPage(urlPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLinks(urlPath, page.Content), nil
+}
+
+// Backlinks returns all pages linking to the given page or folder. Results include the effective ACL.
+func (s *ContentService) Backlinks(urlPath string) ([]model.ContentSummary, error) {
+	q := bleve.NewTermQuery(urlPath)
+	q.SetField("links")
+
+	count, err := s.indexDocCount()
+	if err != nil {
+		return nil, err
+	}
+	return s.FindContent(q, int(count))
+}
+
+// LinkGraph returns the URLs of all pages with the URLs of the pages and folders they link to
+func (s *ContentService) LinkGraph() (map[string][]string, error) {
+	count, err := s.indexDocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	search := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	search.Size = int(count)
+	search.Fields = []string{"links"}
+	results, err := s.searchIndex(search)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := map[string][]string{}
+	for _, hit := range results.Hits {
+		if !s.IsPage(hit.ID) {
+			continue
+		}
+
+		// Stored fields with a single value are not returned as a slice
+		links := []string{}
+		switch v := hit.Fields["links"].(type) {
+		case string:
+			links = append(links, v)
+		case []any:
+			for _, link := range v {
+				if link, ok := link.(string); ok {
+					links = append(links, link)
+				}
+			}
+		}
+		graph[hit.ID] = links
+	}
+
+	return graph, nil
+}
+
+// LinkReport lists all links to non-existent pages or folders and all orphan pages,
+// i.e. pages no other page links to. Folders aren't reported as orphans.
+func (s *ContentService) LinkReport() (model.LinkReport, error) {
+	report := model.LinkReport{
+		BrokenLinks: []model.BrokenLink{},
+		Orphans:     []model.LinkedContent{},
+	}
+
+	graph, err := s.LinkGraph()
+	if err != nil {
+		return report, err
+	}
+
+	sources := slices.Sorted(maps.Keys(graph))
+
+	exists := map[string]bool{}
+	linked := map[string]bool{}
+	for _, source := range sources {
+		for _, target := range graph[source] {
+			if target != source {
+				linked[target] = true
+			}
+
+			if _, found := exists[target]; !found {
+				exists[target] = s.IsPage(target) || s.IsFolder(target)
+			}
+			if !exists[target] {
+				report.BrokenLinks = append(report.BrokenLinks, model.BrokenLink{Source: source, Target: target})
+			}
+		}
+	}
+
+	for _, urlPath := range sources {
+		if linked[urlPath] {
+			continue
+		}
+
+		page, err := s.ReadPage(urlPath, nil)
+		if err != nil {
+			return report, err
+		}
+		report.Orphans = append(report.Orphans, model.LinkedContent{Url: urlPath, Title: page.Meta.Title, Exists: true})
+	}
+
+	return report, nil
+}
+
+// RewrittenPage is a page changed by RewriteLinksAfterMove, with the version created by the change
+type RewrittenPage struct {
+	Url     string
+	Version model.AtticEntry
+}
+
+// RewriteLinksAfterMove updates links broken by moving the page or folder sourcePath to destinationPath:
+// links to the moved content are changed to point to its new URL, and relative links on moved pages are
+// adjusted to their new location. Changed pages are saved as minor edits by userID.
+// Only pages for which canWrite returns true are changed, the others are returned as skipped.
+func (s *ContentService) RewriteLinksAfterMove(sourcePath, destinationPath, userID string, canWrite func(model.ContentSummary) bool) (rewritten []RewrittenPage, skipped []model.ContentSummary, err error) {
+	rewritten, skipped = []RewrittenPage{}, []model.ContentSummary{}
+
+	// Pages not moved still have the old URLs in the index, moved pages are found by their new URL
+	linksQuery := bleve.NewTermQuery(sourcePath)
+	linksQuery.SetField("links")
+	linksBelowQuery := bleve.NewPrefixQuery(sourcePath + "/")
+	linksBelowQuery.SetField("links")
+	movedQuery := bleve.NewTermQuery(destinationPath)
+	movedQuery.SetField("url")
+	movedBelowQuery := bleve.NewPrefixQuery(destinationPath + "/")
+	movedBelowQuery.SetField("url")
+
+	count, err := s.indexDocCount()
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates, err := s.FindContent(bleve.NewDisjunctionQuery(linksQuery, linksBelowQuery, movedQuery, movedBelowQuery), int(count))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, candidate := range candidates {
+		if candidate.IsFolder {
+			continue
+		}
+
+		page, etag, err := s.readPageWithETag(candidate.Url)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		oldUrl := movedUrl(candidate.Url, destinationPath, sourcePath)
+		content := markdown.RewriteLinks(page.Content, func(destination string) string {
+			return rewriteMovedLink(destination, oldUrl, candidate.Url, sourcePath, destinationPath)
+		})
+		if content == page.Content {
+			continue
+		}
+
+		if !canWrite(candidate) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+
+		summary := fmt.Sprintf("Updated links after moving %s to %s", sourcePath, destinationPath)
+		// Pages changed in the meantime are skipped instead of overwriting the changes
+		version, err := s.SavePageWithSummary(candidate.Url, content, page.Meta, userID, summary, true,
+			func(current string) bool { return current == etag })
+		if errors.Is(err, model.ErrPreconditionFailed) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rewritten = append(rewritten, RewrittenPage{Url: candidate.Url, Version: version})
+	}
+
+	return rewritten, skipped, nil
+}
+
+// rewriteMovedLink returns the link destination on the page (previously at oldUrl, now at newUrl)
+// pointing to the same page or folder as before moving sourcePath to destinationPath
+func rewriteMovedLink(destination, oldUrl, newUrl, sourcePath, destinationPath string) string {
+	oldTarget, ok := resolveLink(oldUrl, destination)
+	if !ok {
+		return destination
+	}
+	target := movedUrl(oldTarget, sourcePath, destinationPath)
+	if currentTarget, _ := resolveLink(newUrl, destination); currentTarget == target {
+		return destination
+	}
+
+	// Keep fragment and query
+	suffix := ""
+	if i := strings.IndexAny(destination, "#?"); i >= 0 {
+		suffix = destination[i:]
+	}
+
+	// Keep absolute links absolute and relative links relative
+	if strings.HasPrefix(destination, "/") {
+		return "/" + target + suffix
+	}
+	return relativeUrl(path.Dir("/"+newUrl), "/"+target) + suffix
+}
+
+// movedUrl returns the new URL of urlPath after moving sourcePath to destinationPath
+func movedUrl(urlPath, sourcePath, destinationPath string) string {
+	if urlPath == sourcePath {
+		return destinationPath
+	}
+	if rest, found := strings.CutPrefix(urlPath, sourcePath+"/"); found {
+		return destinationPath + "/" + rest
+	}
+	return urlPath
+}
+
+// relativeUrl returns the relative URL from the folder fromDir to target (both absolute)
+func relativeUrl(fromDir, target string) string {
+	from := strings.Split(strings.Trim(fromDir, "/"), "/")
+	to := strings.Split(strings.Trim(target, "/"), "/")
+	if from[0] == "" {
+		from = []string{}
+	}
+
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	parts := []string{}
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+	return strings.Join(parts, "/")
+}
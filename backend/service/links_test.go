@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLinks(t *testing.T) {
+	testCases := []struct {
+		name    string
+		urlPath string
+		content string
+		links   []string
+	}{
+		{"absolute", "docs/page", "[a](/other/page)", []string{"other/page"}},
+		{"relative sibling", "docs/page", "[a](sibling)", []string{"docs/sibling"}},
+		{"relative with dot", "docs/page", "[a](./sibling)", []string{"docs/sibling"}},
+		{"relative parent", "docs/sub/page", "[a](../other)", []string{"docs/other"}},
+		{"relative from root page", "page", "[a](other)", []string{"other"}},
+		{"beyond root", "page", "[a](../../other)", []string{"other"}},
+		{"trailing slash", "page", "[a](/folder/)", []string{"folder"}},
+		{"fragment and query", "docs/page", "[a](other#section) [b](/x?y=z)", []string{"docs/other", "x"}},
+		{"escaped", "page", "[a](/some%20page)", []string{"some page"}},
+		{"duplicates", "page", "[a](/x) [b](x) [c](/x#y)", []string{"x"}},
+		{"external", "page", "[a](https://example.com/x) [b](mailto:a@b.c) [c](//example.com)", []string{}},
+		{"anchor", "page", "[a](#section)", []string{}},
+		{"root", "docs/page", "[a](/) [b](..)", []string{}},
+		{"application routes", "page", "[a](/_search) [b](/_admin/users)", []string{}},
+		{"reference", "docs/page", "[a][x]\n\n[x]: other", []string{"docs/other"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.links, ParseLinks(tc.urlPath, tc.content))
+		})
+	}
+}
+
+func TestRewriteMovedLink(t *testing.T) {
+	testCases := []struct {
+		name        string
+		destination string
+		oldUrl      string
+		newUrl      string
+		expected    string
+	}{
+		{"absolute link to moved page", "/docs/old", "other", "other", "/docs/new"},
+		{"relative link to moved page", "old", "docs/page", "docs/page", "new"},
+		{"relative link to moved page in other folder", "../docs/old#section", "blog/post", "blog/post", "../docs/new#section"},
+		{"unrelated link", "/docs/other", "page", "page", "/docs/other"},
+		{"external link", "https://example.com/docs/old", "page", "page", "https://example.com/docs/old"},
+		{"anchor", "#old", "page", "page", "#old"},
+		{"self link of moved page", "old", "docs/old", "docs/new", "new"},
+		{"relative link from moved page", "other", "docs/old", "docs/new", "other"},
+		{"absolute link from moved page", "/docs/other", "docs/old", "docs/new", "/docs/other"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, rewriteMovedLink(tc.destination, tc.oldUrl, tc.newUrl, "docs/old", "docs/new"))
+		})
+	}
+
+	// Moving a folder
+	folderCases := []struct {
+		destination string
+		oldUrl      string
+		newUrl      string
+		expected    string
+	}{
+		{"/a/b/page", "x", "x", "/c/b/page"},
+		{"a/b", "x", "x", "c/b"},
+		{"b/page", "a/index", "a/index", "../c/b/page"},
+		{"../other", "a/b/page", "c/b/page", "../../a/other"},
+		{"sibling", "a/b/page", "c/b/page", "sibling"},
+		{"../../top", "a/b/page", "c/b/page", "../../top"},
+	}
+	for _, tc := range folderCases {
+		result := rewriteMovedLink(tc.destination, tc.oldUrl, tc.newUrl, "a/b", "c/b")
+		assert.Equal(t, tc.expected, result, tc.destination+" on "+tc.oldUrl)
+	}
+}
+
+func TestRelativeUrl(t *testing.T) {
+	assert.Equal(t, "page", relativeUrl("/", "/page"))
+	assert.Equal(t, "b", relativeUrl("/a", "/a/b"))
+	assert.Equal(t, "../c", relativeUrl("/a/b", "/a/c"))
+	assert.Equal(t, "../../x/y", relativeUrl("/a/b", "/x/y"))
+	assert.Equal(t, "../a", relativeUrl("/a", "/a"))
+}
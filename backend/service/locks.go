@@ -0,0 +1,67 @@
+package service
+
+import (
+	"sort"
+	"sync"
+)
+
+// keyedMutex provides a mutex per key, e.g. per page.
+// Mutexes are created on demand and removed once nobody holds or waits for them.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refCountedMutex
+}
+
+type refCountedMutex struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: map[string]*refCountedMutex{}}
+}
+
+// Lock locks the mutex of the key and returns the function to unlock it
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	m, found := k.locks[key]
+	if !found {
+		m = &refCountedMutex{}
+		k.locks[key] = m
+	}
+	m.refs++
+	k.mu.Unlock()
+
+	m.Lock()
+
+	return func() {
+		m.Unlock()
+
+		k.mu.Lock()
+		m.refs--
+		if m.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// LockAll locks the mutexes of several keys in a fixed order, so concurrent calls can't deadlock
+func (k *keyedMutex) LockAll(keys ...string) func() {
+	keys = append([]string{}, keys...)
+	sort.Strings(keys)
+
+	unlocks := []func(){}
+	for i, key := range keys {
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+		unlocks = append(unlocks, k.Lock(key))
+	}
+
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
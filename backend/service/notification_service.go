@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tfabritius/plainpage/libs/mail"
+	"github.com/tfabritius/plainpage/model"
+	"gopkg.in/yaml.v3"
+)
+
+// DigestInterval is the time between two digests of changes
+const DigestInterval = 24 * time.Hour
+
+func NewNotificationService(store model.Storage, sender mail.Sender) *NotificationService {
+	return &NotificationService{
+		storage: store,
+		sender:  sender,
+	}
+}
+
+// NotificationService delivers email notifications to subscribers.
+// The time of the last digest is stored in notifications.yml.
+type NotificationService struct {
+	storage model.Storage
+	sender  mail.Sender
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+type notificationState struct {
+	LastDigest time.Time `yaml:"lastDigest"`
+
+	// Pending contains the start of the digests that couldn't be delivered by user ID
+	Pending map[string]time.Time `yaml:"pending,omitempty"`
+}
+
+// Enabled returns whether sending emails is configured
+func (s *NotificationService) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sender != nil
+}
+
+// SetSender replaces the sender used to deliver emails. A nil sender disables notifications.
+func (s *NotificationService) SetSender(sender mail.Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender = sender
+}
+
+// Go runs f in the background, e.g. to notify subscribers without delaying the request causing a change.
+// Panics are logged.
+func (s *NotificationService) Go(f func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[notifications] %v", err)
+			}
+		}()
+		f()
+	}()
+}
+
+// Send delivers a message
+func (s *NotificationService) Send(msg mail.Message) error {
+	s.mu.Lock()
+	sender := s.sender
+	s.mu.Unlock()
+
+	if sender == nil {
+		return nil
+	}
+
+	return sender.Send(msg)
+}
+
+// Wait blocks until everything started with Go is done
+func (s *NotificationService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *NotificationService) readState() (notificationState, error) {
+	state := notificationState{}
+	if !s.storage.Exists("notifications.yml") {
+		return state, nil
+	}
+
+	bytes, err := s.storage.ReadFile("notifications.yml")
+	if err != nil {
+		return state, fmt.Errorf("could not read notifications.yml: %w", err)
+	}
+
+	if err := yaml.Unmarshal(bytes, &state); err != nil {
+		return state, fmt.Errorf("could not parse YAML: %w", err)
+	}
+
+	return state, nil
+}
+
+// LastDigest returns the time the last digest was sent, or the zero time if none was sent yet
+func (s *NotificationService) LastDigest() (time.Time, error) {
+	state, err := s.readState()
+	return state.LastDigest, err
+}
+
+// PendingDigests returns the start of the digests that couldn't be delivered by user ID
+func (s *NotificationService) PendingDigests() (map[string]time.Time, error) {
+	state, err := s.readState()
+	if state.Pending == nil {
+		state.Pending = map[string]time.Time{}
+	}
+	return state.Pending, err
+}
+
+// SetLastDigest stores the time the last digest was sent, keeping pending digests
+func (s *NotificationService) SetLastDigest(t time.Time) error {
+	state, err := s.readState()
+	if err != nil {
+		return err
+	}
+
+	return s.FinishDigest(t, state.Pending)
+}
+
+// FinishDigest stores the time the last digest was sent, together with the start of the digests
+// that couldn't be delivered by user ID, so they can be sent again with the next digest
+func (s *NotificationService) FinishDigest(t time.Time, pending map[string]time.Time) error {
+	state := notificationState{LastDigest: t.UTC()}
+	if len(pending) > 0 {
+		state.Pending = map[string]time.Time{}
+		for userID, since := range pending {
+			state.Pending[userID] = since.UTC()
+		}
+	}
+
+	bytes, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WriteFile("notifications.yml", bytes)
+}
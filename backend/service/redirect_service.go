@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tfabritius/plainpage/model"
+	"gopkg.in/yaml.v3"
+)
+
+func NewRedirectService(store model.Storage, content *ContentService) *RedirectService {
+	s := RedirectService{
+		storage: store,
+		content: content,
+	}
+
+	// Initialize redirects.yml
+	if !s.storage.Exists("redirects.yml") {
+		err := s.saveAllUnlocked([]model.Redirect{})
+		if err != nil {
+			log.Fatalln("Could not create redirects.yml:", err)
+		}
+	}
+
+	return &s
+}
+
+// RedirectService keeps track of the previous URLs of moved pages and folders
+type RedirectService struct {
+	storage model.Storage
+	content *ContentService
+	mu      sync.RWMutex
+}
+
+func (s *RedirectService) readAllUnlocked() ([]model.Redirect, error) {
+	bytes, err := s.storage.ReadFile("redirects.yml")
+	if err != nil {
+		return nil, fmt.Errorf("could not read redirects.yml: %w", err)
+	}
+
+	redirects := []model.Redirect{}
+	if err := yaml.Unmarshal(bytes, &redirects); err != nil {
+		return nil, fmt.Errorf("could not parse YAML: %w", err)
+	}
+
+	return redirects, nil
+}
+
+func (s *RedirectService) saveAllUnlocked(redirects []model.Redirect) error {
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	bytes, err := yaml.Marshal(&redirects)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WriteFile("redirects.yml", bytes)
+}
+
+// ReadAll returns all redirects sorted by their source URL.
+// Redirects are marked as stale if content exists at their source URL or their target doesn't exist.
+func (s *RedirectService) ReadAll() ([]model.Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	redirects, err := s.readAllUnlocked()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range redirects {
+		redirects[i].Stale = s.isStale(redirects[i])
+	}
+
+	return redirects, nil
+}
+
+// Add records that the page or folder at from was moved to to.
+// Redirects pointing to from (or its descendants) are updated to point to the new location,
+// redirects from to (or its descendants) are removed as the moved content lives there now.
+func (s *RedirectService) Add(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redirects, err := s.readAllUnlocked()
+	if err != nil {
+		return err
+	}
+
+	updated := []model.Redirect{}
+	for _, redirect := range redirects {
+		if isSameOrDescendant(redirect.From, to) || redirect.From == from {
+			continue
+		}
+		redirect.To = movedUrl(redirect.To, from, to)
+		if redirect.From == redirect.To {
+			continue
+		}
+		updated = append(updated, redirect)
+	}
+
+	updated = append(updated, model.Redirect{From: from, To: to, CreatedAt: time.Now()})
+
+	return s.saveAllUnlocked(updated)
+}
+
+// Resolve returns the current URL of a page or folder that was moved away from urlPath.
+// Redirects of folders also apply to their descendants.
+// Returns false if there is no redirect, if content exists at urlPath, or if the target doesn't exist.
+func (s *RedirectService) Resolve(urlPath string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Redirects never shadow existing content
+	if s.content.IsPage(urlPath) || s.content.IsFolder(urlPath) {
+		return "", false, nil
+	}
+
+	redirects, err := s.readAllUnlocked()
+	if err != nil {
+		return "", false, err
+	}
+
+	// The most specific redirect wins, redirects of folders replaced by new content are ignored
+	var match *model.Redirect
+	for i, redirect := range redirects {
+		if isSameOrDescendant(urlPath, redirect.From) && (match == nil || len(redirect.From) > len(match.From)) &&
+			!s.content.IsFolder(redirect.From) {
+			match = &redirects[i]
+		}
+	}
+	if match == nil {
+		return "", false, nil
+	}
+
+	target := movedUrl(urlPath, match.From, match.To)
+	if !s.content.IsPage(target) && !s.content.IsFolder(target) {
+		return "", false, nil
+	}
+
+	return target, true, nil
+}
+
+// Delete removes the redirect from the given URL
+func (s *RedirectService) Delete(from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redirects, err := s.readAllUnlocked()
+	if err != nil {
+		return err
+	}
+
+	for i, redirect := range redirects {
+		if redirect.From == from {
+			return s.saveAllUnlocked(append(redirects[:i], redirects[i+1:]...))
+		}
+	}
+
+	return model.ErrNotFound
+}
+
+// Prune removes all stale redirects and returns the number of removed redirects
+func (s *RedirectService) Prune() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redirects, err := s.readAllUnlocked()
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := []model.Redirect{}
+	for _, redirect := range redirects {
+		if !s.isStale(redirect) {
+			remaining = append(remaining, redirect)
+		}
+	}
+
+	removed := len(redirects) - len(remaining)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	return removed, s.saveAllUnlocked(remaining)
+}
+
+// DeleteAll removes all redirects (used when restoring a backup, which replaces all content)
+func (s *RedirectService) DeleteAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveAllUnlocked([]model.Redirect{})
+}
+
+// isStale checks if content exists at the source of the redirect or its target doesn't exist
+func (s *RedirectService) isStale(redirect model.Redirect) bool {
+	return s.content.IsPage(redirect.From) || s.content.IsFolder(redirect.From) ||
+		!(s.content.IsPage(redirect.To) || s.content.IsFolder(redirect.To))
+}
+
+// isSameOrDescendant checks if urlPath equals ancestor or is located below it
+func isSameOrDescendant(urlPath, ancestor string) bool {
+	return urlPath == ancestor || strings.HasPrefix(urlPath, ancestor+"/")
+}
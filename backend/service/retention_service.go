@@ -8,16 +8,18 @@ import (
 	"github.com/tfabritius/plainpage/model"
 )
 
-// RetentionService handles automatic cleanup of trash and attic based on retention policies
+// RetentionService handles automatic cleanup of trash, attic and change journal based on retention policies
 type RetentionService struct {
 	content *ContentService
+	changes *ChangeService
 	config  *ConfigService
 }
 
 // NewRetentionService creates a new retention service
-func NewRetentionService(content *ContentService, config *ConfigService) *RetentionService {
+func NewRetentionService(content *ContentService, changes *ChangeService, config *ConfigService) *RetentionService {
 	return &RetentionService{
 		content: content,
+		changes: changes,
 		config:  config,
 	}
 }
@@ -95,12 +97,12 @@ func (s *RetentionService) cleanupPageAttic(pageUrl string, policy model.AtticRe
 
 	// Step 1: Delete versions older than maxAgeDays (but not the most recent one)
 	if policy.MaxAgeDays > 0 {
-		cutoff := time.Now().Add(-time.Duration(policy.MaxAgeDays) * 24 * time.Hour).Unix()
+		cutoff := time.Now().Add(-time.Duration(policy.MaxAgeDays) * 24 * time.Hour)
 
 		remaining := []model.AtticEntry{}
 		for i, entry := range entries {
 			isNewest := i == len(entries)-1 // Last entry is the newest
-			if entry.Revision < cutoff && !isNewest {
+			if RevisionTime(entry.Revision).Before(cutoff) && !isNewest {
 				if err := s.content.DeleteAtticEntry(pageUrl, entry.Revision); err != nil {
 					log.Printf("[retention] Failed to delete attic entry %s rev %d: %v", pageUrl, entry.Revision, err)
 					remaining = append(remaining, entry) // Keep in list if deletion failed
@@ -132,7 +134,20 @@ func (s *RetentionService) cleanupPageAttic(pageUrl string, policy model.AtticRe
 	return deleted, nil
 }
 
-// Cleanup runs both trash and attic cleanup based on current configuration
+// CleanupChanges removes changes older than the configured maxAgeDays from the journal.
+// The journal is pruned by whole days, so changes are kept for up to one more day.
+// Returns the number of deleted journal files and any error encountered.
+func (s *RetentionService) CleanupChanges(policy model.ChangesRetention) (int, error) {
+	if policy.MaxAgeDays <= 0 {
+		return 0, nil // Disabled
+	}
+
+	cutoff := time.Now().Add(-time.Duration(policy.MaxAgeDays) * 24 * time.Hour)
+
+	return s.changes.DeleteBefore(cutoff)
+}
+
+// Cleanup runs trash, attic and change journal cleanup based on current configuration
 func (s *RetentionService) Cleanup() error {
 	cfg, err := s.config.Read()
 	if err != nil {
@@ -153,6 +168,13 @@ func (s *RetentionService) Cleanup() error {
 		log.Printf("[retention] Attic cleanup: deleted %d versions", atticDeleted)
 	}
 
+	changesDeleted, err := s.CleanupChanges(cfg.Retention.Changes)
+	if err != nil {
+		log.Printf("[retention] Change journal cleanup error: %v", err)
+	} else if changesDeleted > 0 {
+		log.Printf("[retention] Change journal cleanup: deleted %d days", changesDeleted)
+	}
+
 	return nil
 }
 
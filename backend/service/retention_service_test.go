@@ -28,7 +28,7 @@ func TestCleanupTrash_DeletesOldEntries(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	// Create pages
 	err := contentService.SavePage("old-page", "Content", model.ContentMeta{Title: "Old Page"}, "")
@@ -67,7 +67,7 @@ func TestCleanupTrash_KeepsAllWhenNoneExpired(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	// Create and delete pages recently
 	err := contentService.SavePage("page1", "Content", model.ContentMeta{Title: "Page 1"}, "")
@@ -117,7 +117,7 @@ func TestCleanupAttic_DeletesByAge(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	// Create a page with versions at different times
 	oldRevisionTime := time.Now().Add(-15 * 24 * time.Hour)   // 15 days ago
@@ -142,7 +142,7 @@ func TestCleanupAttic_DeletesByAge(t *testing.T) {
 	entries, err = contentService.ListAttic("testpage")
 	r.NoError(err)
 	r.Len(entries, 1)
-	r.Equal(recentRevisionTime.Unix(), entries[0].Revision)
+	r.Equal(recentRevisionTime.UnixMilli(), entries[0].Revision)
 }
 
 func TestCleanupAttic_DeletesByCount(t *testing.T) {
@@ -150,7 +150,7 @@ func TestCleanupAttic_DeletesByCount(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	now := time.Now()
 
@@ -182,7 +182,7 @@ func TestCleanupAttic_DeletesByAgeAndCount(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	now := time.Now()
 	oldRevisionTime := now.Add(-15 * 24 * time.Hour) // 15 days ago
@@ -222,7 +222,7 @@ func TestCleanupAttic_MultiplePages(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	now := time.Now()
 
@@ -263,7 +263,7 @@ func TestCleanupAttic_KeepsAllWhenBelowMaxVersions(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	now := time.Now()
 
@@ -295,7 +295,7 @@ func TestCleanupAttic_PreservesMostRecentVersion(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	// Create a page with all versions older than maxAgeDays
 	// Both versions are old enough to be deleted by age policy
@@ -321,7 +321,7 @@ func TestCleanupAttic_PreservesMostRecentVersion(t *testing.T) {
 	entries, err = contentService.ListAttic("testpage")
 	r.NoError(err)
 	r.Len(entries, 1)
-	r.Equal(oldRevisionTime.Unix(), entries[0].Revision) // The newest (but still old) version is preserved
+	r.Equal(oldRevisionTime.UnixMilli(), entries[0].Revision) // The newest (but still old) version is preserved
 }
 
 func TestCleanupAttic_PreservesOnlyVersion(t *testing.T) {
@@ -329,7 +329,7 @@ func TestCleanupAttic_PreservesOnlyVersion(t *testing.T) {
 	mock := newMockStorage()
 	configService := NewConfigService(mock)
 	contentService := NewContentService(mock, configService)
-	retentionService := NewRetentionService(contentService, configService)
+	retentionService := NewRetentionService(contentService, NewChangeService(mock), configService)
 
 	// Create a page with only one version, and it's old
 	oldRevisionTime := time.Now().Add(-30 * 24 * time.Hour) // 30 days ago
@@ -353,3 +353,30 @@ func TestCleanupAttic_PreservesOnlyVersion(t *testing.T) {
 	r.NoError(err)
 	r.Len(entries, 1)
 }
+
+func TestCleanupChanges(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	contentService := NewContentService(mock, configService)
+	changeService := NewChangeService(mock)
+	retentionService := NewRetentionService(contentService, changeService, configService)
+
+	now := time.Now()
+	r.NoError(changeService.Record(model.Change{Url: "old", Time: now.Add(-30 * 24 * time.Hour)}))
+	r.NoError(changeService.Record(model.Change{Url: "new", Time: now}))
+
+	// Disabled
+	deleted, err := retentionService.CleanupChanges(model.ChangesRetention{MaxAgeDays: 0})
+	r.NoError(err)
+	r.Equal(0, deleted)
+
+	deleted, err = retentionService.CleanupChanges(model.ChangesRetention{MaxAgeDays: 7})
+	r.NoError(err)
+	r.Equal(1, deleted)
+
+	changes, _, err := changeService.List(0, 10, func(model.Change) bool { return true })
+	r.NoError(err)
+	r.Len(changes, 1)
+	r.Equal("new", changes[0].Url)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tfabritius/plainpage/model"
+	"gopkg.in/yaml.v3"
+)
+
+// legacyRevisionLimit separates revisions in seconds (saved before millisecond precision was introduced)
+// from revisions in milliseconds: all millisecond timestamps since 1973 are larger.
+const legacyRevisionLimit = 100_000_000_000
+
+// RevisionTime returns the time a version was saved.
+// Revisions are Unix timestamps in milliseconds, older revisions are Unix timestamps in seconds.
+func RevisionTime(revision int64) time.Time {
+	if revision < legacyRevisionLimit {
+		return time.Unix(revision, 0)
+	}
+	return time.UnixMilli(revision)
+}
+
+// createRevision saves a new version of a page saved at t to the attic and returns its revision.
+// If the page already has a version with the same timestamp, the next free millisecond is used.
+// The file is created exclusively, so concurrent saves cannot pick the same revision.
+func (s *ContentService) createRevision(urlPath string, t time.Time, content []byte) (int64, error) {
+	revision := t.UnixMilli()
+	for {
+		atticFile := filepath.Join("attic", urlPath+"."+strconv.FormatInt(revision, 10)+".md")
+
+		err := s.storage.CreateFile(atticFile, content)
+		if errors.Is(err, fs.ErrExist) {
+			revision++
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		return revision, nil
+	}
+}
+
+// revisionNote describes the change recorded together with a new version of a page
+type revisionNote struct {
+	summary string
+	minor   bool
+}
+
+// revisionsFileName returns the name of the sidecar file holding the details of all versions of a page.
+// The file is stored next to the versions in the attic: {pageName}.revisions.yml
+func revisionsFileName(pageName string) string {
+	return pageName + ".revisions.yml"
+}
+
+// revisionsFile returns the path of the sidecar file of a page
+func revisionsFile(urlPath string) string {
+	return filepath.Join("attic", filepath.Dir(urlPath), revisionsFileName(path.Base(urlPath)))
+}
+
+// readRevisions reads the sidecar file of a page. Returns an empty list if it doesn't exist.
+// All access to the sidecar file requires the page to be locked, see ContentService.locks.
+func (s *ContentService) readRevisions(urlPath string) ([]model.AtticEntry, error) {
+	fsPath := revisionsFile(urlPath)
+	if !s.storage.Exists(fsPath) {
+		return []model.AtticEntry{}, nil
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", fsPath, err)
+	}
+
+	entries := []model.AtticEntry{}
+	if err := yaml.Unmarshal(bytes, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse YAML: %w", err)
+	}
+
+	return entries, nil
+}
+
+// saveRevisions writes the sidecar file of a page, sorted by revision.
+// The file is removed if there are no entries left.
+func (s *ContentService) saveRevisions(urlPath string, entries []model.AtticEntry) error {
+	if len(entries) == 0 {
+		if s.storage.Exists(revisionsFile(urlPath)) {
+			return s.storage.DeleteFile(revisionsFile(urlPath))
+		}
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Revision < entries[j].Revision
+	})
+
+	bytes, err := yaml.Marshal(&entries)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WriteFile(revisionsFile(urlPath), bytes)
+}
+
+// addRevision records the details of a new version in the sidecar file of a page, which must be locked by the caller.
+// Details of older versions missing in the sidecar file are added as well.
+func (s *ContentService) addRevision(urlPath string, entry model.AtticEntry) error {
+	entries, err := s.listAtticLocked(urlPath)
+	if err != nil {
+		return err
+	}
+
+	// Replace stale details of a version with the same revision
+	for i := range entries {
+		if entries[i].Revision == entry.Revision {
+			entries[i] = entry
+			return s.saveRevisions(urlPath, entries)
+		}
+	}
+
+	return s.saveRevisions(urlPath, append(entries, entry))
+}
+
+// removeRevision removes a version from the sidecar file of a page, which must be locked by the caller
+func (s *ContentService) removeRevision(urlPath string, revision int64) error {
+	entries, err := s.readRevisions(urlPath)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Revision == revision {
+			return s.saveRevisions(urlPath, append(entries[:i], entries[i+1:]...))
+		}
+	}
+
+	return nil
+}
+
+// readRevisionFromFile determines the details of a version saved before the sidecar file was introduced
+func (s *ContentService) readRevisionFromFile(urlPath string, revision int64) (model.AtticEntry, error) {
+	fsPath := filepath.Join("attic", urlPath+"."+strconv.FormatInt(revision, 10)+".md")
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return model.AtticEntry{}, err
+	}
+
+	entry := model.AtticEntry{Revision: revision, Size: int64(len(bytes))}
+	if meta, _, err := parseFrontMatter(string(bytes)); err == nil {
+		entry.ModifiedByUserID = meta.ModifiedByUserID
+	}
+
+	return entry, nil
+}
+
+// moveRevisionsFile moves the sidecar file of a page (if it exists) to destPath
+func (s *ContentService) moveRevisionsFile(urlPath, destPath string) error {
+	srcPath := revisionsFile(urlPath)
+	if !s.storage.Exists(srcPath) {
+		return nil
+	}
+
+	return s.storage.Rename(srcPath, destPath)
+}
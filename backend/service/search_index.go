@@ -0,0 +1,551 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// indexMappingVersion must be increased whenever the index mapping or the indexed documents change.
+// A persistent index created with a different version is rebuilt from scratch.
+const indexMappingVersion = "5"
+
+// indexBatchSize is the number of documents written at once when (re-)building the index
+const indexBatchSize = 500
+
+var internalKeyMappingVersion = []byte("mappingVersion")
+var internalKeyLastSync = []byte("lastSync")
+
+// searchDocument is the representation of a page or folder in the search index
+type searchDocument struct {
+	Type    string             `json:"-"`
+	Url     string             `json:"url"`
+	Content string             `json:"content"`
+	Links   []string           `json:"links"` // URLs of linked pages and folders
+	Meta    searchDocumentMeta `json:"meta"`
+}
+
+type searchDocumentMeta struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Tags       []string  `json:"tags"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+	ModifiedBy string    `json:"modifiedBy"`
+}
+
+// BleveType returns the type name used for indexing in Bleve
+func (d searchDocument) BleveType() string {
+	return d.Type
+}
+
+// newSearchDocument converts a page or folder into a search document
+func newSearchDocument(doc any) searchDocument {
+	switch d := doc.(type) {
+	case model.Page:
+		return searchDocument{
+			Type:    d.BleveType(),
+			Url:     d.Url,
+			Content: d.Content,
+			Links:   ParseLinks(d.Url, d.Content),
+			Meta:    newSearchDocumentMeta(d.Meta),
+		}
+	case model.Folder:
+		return searchDocument{
+			Type: d.BleveType(),
+			Url:  d.Url,
+			Meta: newSearchDocumentMeta(d.Meta),
+		}
+	default:
+		panic(fmt.Sprintf("unsupported document type %T", doc))
+	}
+}
+
+func newSearchDocumentMeta(meta model.ContentMeta) searchDocumentMeta {
+	return searchDocumentMeta{
+		ID:         meta.ID,
+		Title:      meta.Title,
+		Tags:       meta.Tags,
+		ModifiedAt: meta.ModifiedAt,
+		ModifiedBy: meta.ModifiedByUserID,
+	}
+}
+
+// internalKeyHash returns the key under which the hash of an indexed document's file is stored
+func internalKeyHash(id string) []byte {
+	return []byte("hash:" + id)
+}
+
+// internalKeyStat returns the key under which the modification time and size of
+// an indexed document's file are stored, as seen by the last synchronization
+func internalKeyStat(id string) []byte {
+	return []byte("stat:" + id)
+}
+
+// fileStat returns the modification time and size of a file
+func fileStat(info fs.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+}
+
+// openIndex opens the persistent search index and brings it up to date.
+// A new index is created if there is none yet, if it cannot be opened, or if its mapping is outdated.
+// Without persistent index, a new in-memory index is created.
+func (s *ContentService) openIndex() error {
+	if s.indexDir == "" {
+		return s.RecreateIndex()
+	}
+
+	idx, err := bleve.Open(s.indexDir)
+	if err != nil {
+		if !errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+			log.Printf("[INDEX] Could not open search index, recreating it: %v", err)
+		}
+		return s.RecreateIndex()
+	}
+
+	version, err := idx.GetInternal(internalKeyMappingVersion)
+	if err != nil {
+		_ = idx.Close()
+		return err
+	}
+	if string(version) != indexMappingVersion {
+		log.Println("Search index is outdated.")
+		if err := idx.Close(); err != nil {
+			return err
+		}
+		return s.RecreateIndex()
+	}
+
+	s.index = idx
+	return s.reconcileIndex()
+}
+
+// Close closes the search index
+func (s *ContentService) Close() error {
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+
+	return s.index.Close()
+}
+
+// newIndex creates a new empty index, either in memory or in a temporary directory next to the index directory.
+// The existing index stays in use until the new one is swapped in by replaceIndexLocked.
+func (s *ContentService) newIndex() (bleve.Index, error) {
+	if s.indexDir == "" {
+		return bleve.NewMemOnly(s.createIndexMapping())
+	}
+
+	newDir := s.indexDir + ".new"
+	if err := os.RemoveAll(newDir); err != nil {
+		return nil, fmt.Errorf("could not remove incomplete index: %w", err)
+	}
+
+	return bleve.New(newDir, s.createIndexMapping())
+}
+
+// discardIndex closes and removes a new index created by newIndex, which couldn't be built
+func (s *ContentService) discardIndex(idx bleve.Index) {
+	if err := idx.Close(); err != nil {
+		log.Printf("[INDEX] Could not close incomplete index: %v", err)
+	}
+	if s.indexDir != "" {
+		if err := os.RemoveAll(s.indexDir + ".new"); err != nil {
+			log.Printf("[INDEX] Could not remove incomplete index: %v", err)
+		}
+	}
+}
+
+// replaceIndexLocked replaces the search index by idx, which has been created by newIndex.
+// A persistent index is moved to the index directory, the previous index is restored if that fails.
+// The caller must hold indexLock.
+func (s *ContentService) replaceIndexLocked(idx bleve.Index) error {
+	if s.indexDir == "" {
+		previous := s.index
+		s.index = idx
+
+		if previous != nil {
+			return previous.Close()
+		}
+		return nil
+	}
+
+	if err := idx.Close(); err != nil {
+		return fmt.Errorf("could not close new index: %w", err)
+	}
+
+	if s.index != nil {
+		if err := s.index.Close(); err != nil {
+			return fmt.Errorf("could not close index: %w", err)
+		}
+		s.index = nil
+	}
+
+	// Keep the previous index until the new one has been opened
+	oldDir := s.indexDir + ".old"
+	if err := os.RemoveAll(oldDir); err != nil {
+		return fmt.Errorf("could not remove previous index: %w", err)
+	}
+	if err := os.Rename(s.indexDir, oldDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Join(fmt.Errorf("could not move index: %w", err), s.reopenIndex())
+	}
+
+	var err error
+	if err = os.Rename(s.indexDir+".new", s.indexDir); err == nil {
+		s.index, err = bleve.Open(s.indexDir)
+	}
+	if err != nil {
+		err = fmt.Errorf("could not open new index: %w", err)
+		if restoreErr := os.RemoveAll(s.indexDir); restoreErr != nil {
+			return errors.Join(err, restoreErr)
+		}
+		if restoreErr := os.Rename(oldDir, s.indexDir); restoreErr != nil && !errors.Is(restoreErr, os.ErrNotExist) {
+			return errors.Join(err, restoreErr)
+		}
+		return errors.Join(err, s.reopenIndex())
+	}
+
+	if err := os.RemoveAll(oldDir); err != nil {
+		log.Printf("[INDEX] Could not remove previous index: %v", err)
+	}
+	return nil
+}
+
+// reopenIndex opens the persistent index again after replacing it failed.
+// The caller must hold indexLock.
+func (s *ContentService) reopenIndex() error {
+	idx, err := bleve.Open(s.indexDir)
+	if err != nil {
+		return fmt.Errorf("could not reopen index: %w", err)
+	}
+	s.index = idx
+	return nil
+}
+
+// recordIndexChange records that a page or folder (and with tree everything below it) changed,
+// if the index is being recreated. The caller must hold indexLock for reading.
+func (s *ContentService) recordIndexChange(id string, tree bool) {
+	s.indexChangesMu.Lock()
+	defer s.indexChangesMu.Unlock()
+
+	if s.indexChanges != nil {
+		s.indexChanges[id] = s.indexChanges[id] || tree
+	}
+}
+
+// applyIndexChanges updates the pages and folders changed while the new index idx was built
+func (s *ContentService) applyIndexChanges(idx bleve.Index, changes map[string]bool) error {
+	batch := idx.NewBatch()
+	for id, tree := range changes {
+		if tree && s.IsFolder(id) {
+			if err := s.indexTree(id, idx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch {
+		case id == "":
+			// Root folder is not indexed
+		case s.IsPage(id):
+			page, err := s.ReadPage(id, nil)
+			if err != nil {
+				return fmt.Errorf("could not read page %s: %w", id, err)
+			}
+			if err := s.addToBatch(batch, id, page); err != nil {
+				return err
+			}
+		case s.IsFolder(id):
+			meta, err := s.ReadFolderMeta(id)
+			if err != nil {
+				return fmt.Errorf("could not read folder %s: %w", id, err)
+			}
+			if err := s.addToBatch(batch, id, model.Folder{Url: id, Meta: meta}); err != nil {
+				return err
+			}
+		default:
+			batch.Delete(id)
+			batch.DeleteInternal(internalKeyHash(id))
+			batch.DeleteInternal(internalKeyStat(id))
+		}
+	}
+
+	return idx.Batch(batch)
+}
+
+// indexDocCount returns the number of documents in the search index
+func (s *ContentService) indexDocCount() (uint64, error) {
+	s.indexLock.RLock()
+	defer s.indexLock.RUnlock()
+
+	return s.index.DocCount()
+}
+
+// searchIndex runs a search request on the search index
+func (s *ContentService) searchIndex(req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	s.indexLock.RLock()
+	defer s.indexLock.RUnlock()
+
+	return s.index.Search(req)
+}
+
+// setIndexSyncState stores the mapping version and the time the index was last synchronized with the pages
+func setIndexSyncState(idx bleve.Index, syncedAt time.Time) error {
+	if err := idx.SetInternal(internalKeyMappingVersion, []byte(indexMappingVersion)); err != nil {
+		return err
+	}
+
+	syncedAtBytes, err := syncedAt.MarshalText()
+	if err != nil {
+		return err
+	}
+	return idx.SetInternal(internalKeyLastSync, syncedAtBytes)
+}
+
+// reconcileIndex updates the index with all pages and folders whose files
+// were modified since the last synchronization or differ from the file seen then, and removes deleted ones.
+// It runs on startup, before the index is used concurrently.
+func (s *ContentService) reconcileIndex() error {
+	startedAt := time.Now()
+
+	var lastSync time.Time
+	if lastSyncBytes, err := s.index.GetInternal(internalKeyLastSync); err != nil {
+		return err
+	} else if err := lastSync.UnmarshalText(lastSyncBytes); err != nil {
+		// Check all files
+		lastSync = time.Time{}
+	}
+
+	log.Println("Updating search index...")
+
+	batch := s.index.NewBatch()
+	seen := map[string]bool{}
+	updated := 0
+	if err := s.reconcileFolder("", lastSync, batch, seen, &updated); err != nil {
+		return err
+	}
+
+	// Remove documents of pages and folders that don't exist anymore
+	removed := 0
+	ids, err := s.indexedIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			batch.Delete(id)
+			batch.DeleteInternal(internalKeyHash(id))
+			batch.DeleteInternal(internalKeyStat(id))
+			removed++
+		}
+	}
+
+	if err := s.index.Batch(batch); err != nil {
+		return err
+	}
+
+	if err := setIndexSyncState(s.index, startedAt); err != nil {
+		return err
+	}
+
+	log.Printf("done (%v updated, %v removed).", updated, removed)
+	return nil
+}
+
+// reconcileFolder checks all pages and folders below urlPath and adds those changed since lastSync to the batch.
+// Files with an older modification time are compared as well if their modification time or size differs from the last synchronization.
+// The IDs of all existing pages and folders are recorded in seen.
+func (s *ContentService) reconcileFolder(urlPath string, lastSync time.Time, batch *bleve.Batch, seen map[string]bool, updated *int) error {
+	entries, err := s.storage.ReadDirectory(filepath.Join("pages", urlPath))
+	if err != nil {
+		return fmt.Errorf("could not read folder %s: %w", urlPath, err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			if err := s.reconcileFolder(path.Join(urlPath, e.Name()), lastSync, batch, seen, updated); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var id string
+		var isFolder bool
+		if e.Name() == "_index.md" {
+			if urlPath == "" {
+				// Root folder is not indexed
+				continue
+			}
+			id = urlPath
+			isFolder = true
+		} else if !strings.HasPrefix(e.Name(), "_") && strings.HasSuffix(e.Name(), ".md") {
+			id = path.Join(urlPath, strings.TrimSuffix(e.Name(), ".md"))
+		} else {
+			continue
+		}
+		seen[id] = true
+
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		stat := fileStat(info)
+		if info.ModTime().Before(lastSync) {
+			// Files restored from a backup may keep their original modification time,
+			// so they are compared unless they look the same as at the last synchronization
+			indexedStat, err := s.index.GetInternal(internalKeyStat(id))
+			if err != nil {
+				return err
+			}
+			if string(indexedStat) == stat {
+				continue
+			}
+		}
+
+		changed, err := s.reconcileDocument(batch, id, isFolder)
+		if err != nil {
+			return err
+		}
+		batch.SetInternal(internalKeyStat(id), []byte(stat))
+		if changed {
+			*updated++
+		}
+
+		if batch.Size() >= indexBatchSize {
+			if err := s.index.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+
+	return nil
+}
+
+// reconcileDocument adds a page or folder to the batch if its file differs from the indexed version
+func (s *ContentService) reconcileDocument(batch *bleve.Batch, id string, isFolder bool) (bool, error) {
+	fsPath := filepath.Join("pages", id+".md")
+	if isFolder {
+		fsPath = filepath.Join("pages", id, "_index.md")
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return false, err
+	}
+	hash := hashContent(bytes)
+
+	indexedHash, err := s.index.GetInternal(internalKeyHash(id))
+	if err != nil {
+		return false, err
+	}
+	if string(indexedHash) == hash {
+		return false, nil
+	}
+
+	var doc any
+	if isFolder {
+		meta, err := s.ReadFolderMeta(id)
+		if err != nil {
+			return false, fmt.Errorf("could not read folder %s: %w", id, err)
+		}
+		doc = model.Folder{Url: id, Meta: meta}
+	} else {
+		page, err := s.ReadPage(id, nil)
+		if err != nil {
+			return false, fmt.Errorf("could not read page %s: %w", id, err)
+		}
+		doc = page
+	}
+
+	if err := batch.Index(id, newSearchDocument(doc)); err != nil {
+		return false, err
+	}
+	batch.SetInternal(internalKeyHash(id), []byte(hash))
+	return true, nil
+}
+
+// indexedIDs returns the IDs of all documents in the index
+func (s *ContentService) indexedIDs() ([]string, error) {
+	count, err := s.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	search := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	search.Size = int(count)
+	results, err := s.index.Search(search)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+// addToBatch adds a page or folder to the batch, together with the hash of its file
+func (s *ContentService) addToBatch(batch *bleve.Batch, id string, doc any) error {
+	fsPath := filepath.Join("pages", id+".md")
+	if _, isFolder := doc.(model.Folder); isFolder {
+		fsPath = filepath.Join("pages", id, "_index.md")
+	}
+
+	bytes, err := s.storage.ReadFile(fsPath)
+	if err != nil {
+		return err
+	}
+
+	if err := batch.Index(id, newSearchDocument(doc)); err != nil {
+		return err
+	}
+	batch.SetInternal(internalKeyHash(id), []byte(hashContent(bytes)))
+	return nil
+}
+
+// indexDocument adds or updates a page or folder in the search index
+func (s *ContentService) indexDocument(id string, doc any) error {
+	s.indexLock.RLock()
+	defer s.indexLock.RUnlock()
+
+	s.recordIndexChange(id, false)
+
+	batch := s.index.NewBatch()
+	if err := s.addToBatch(batch, id, doc); err != nil {
+		return err
+	}
+	return s.index.Batch(batch)
+}
+
+// indexSubtree adds or updates a folder and all its contents in the search index
+func (s *ContentService) indexSubtree(urlPath string) error {
+	s.indexLock.RLock()
+	defer s.indexLock.RUnlock()
+
+	s.recordIndexChange(urlPath, true)
+
+	return s.indexTree(urlPath, s.index)
+}
+
+// unindexDocument removes a page or folder from the search index
+func (s *ContentService) unindexDocument(id string) error {
+	s.indexLock.RLock()
+	defer s.indexLock.RUnlock()
+
+	s.recordIndexChange(id, false)
+
+	batch := s.index.NewBatch()
+	batch.Delete(id)
+	batch.DeleteInternal(internalKeyHash(id))
+	batch.DeleteInternal(internalKeyStat(id))
+	return s.index.Batch(batch)
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func searchUrls(t *testing.T, s *ContentService, q string) []string {
+	hits, err := s.Search(q)
+	require.NoError(t, err)
+
+	urls := []string{}
+	for _, hit := range hits {
+		urls = append(urls, hit.Url)
+	}
+	return urls
+}
+
+// hookStorage runs onRead once when the file at hookPath is read
+type hookStorage struct {
+	model.Storage
+	hookPath string
+	onRead   func()
+	once     sync.Once
+}
+
+func (s *hookStorage) ReadFile(fsPath string) ([]byte, error) {
+	if fsPath == s.hookPath && s.onRead != nil {
+		s.once.Do(s.onRead)
+	}
+	return s.Storage.ReadFile(fsPath)
+}
+
+func TestPersistentIndex(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	store := NewFsStorage(dir)
+	configService := NewConfigService(store)
+	options := ContentOptions{IndexDir: filepath.Join(t.TempDir(), "index")}
+
+	// Create index
+	contentService := NewContentServiceWithOptions(store, configService, options)
+	r.NoError(contentService.CreateFolder("folder", model.ContentMeta{Title: "Folder"}))
+	r.NoError(contentService.SavePage("folder/apple", "apple", model.ContentMeta{Title: "Apple"}, ""))
+	r.NoError(contentService.SavePage("banana", "banana", model.ContentMeta{Title: "Banana"}, ""))
+	r.ElementsMatch([]string{"folder/apple"}, searchUrls(t, contentService, "apple"))
+	r.NoError(contentService.Close())
+
+	// Index is reused
+	contentService = NewContentServiceWithOptions(store, configService, options)
+	r.ElementsMatch([]string{"folder/apple"}, searchUrls(t, contentService, "apple"))
+	r.ElementsMatch([]string{"banana"}, searchUrls(t, contentService, "banana"))
+	r.NoError(contentService.Close())
+
+	// Change files while not running
+	r.NoError(store.WriteFile("pages/cherry.md", []byte("---\ntitle: Cherry\n---\ncherry")))
+	r.NoError(store.WriteFile("pages/banana.md", []byte("---\ntitle: Banana\n---\nbanana split")))
+	r.NoError(store.DeleteFile("pages/folder/apple.md"))
+
+	// Index is updated on start
+	contentService = NewContentServiceWithOptions(store, configService, options)
+	r.Empty(searchUrls(t, contentService, "apple"))
+	r.ElementsMatch([]string{"cherry"}, searchUrls(t, contentService, "cherry"))
+	r.ElementsMatch([]string{"banana"}, searchUrls(t, contentService, "split"))
+	r.ElementsMatch([]string{"folder"}, searchUrls(t, contentService, "folder"))
+	r.NoError(contentService.Close())
+
+	// Files restored with an older modification time are updated on start
+	r.NoError(store.WriteFile("pages/banana.md", []byte("---\nid: banana\ntitle: Banana\n---\nbanana bread")))
+	old := time.Now().Add(-24 * time.Hour)
+	r.NoError(os.Chtimes(filepath.Join(dir, "pages", "banana.md"), old, old))
+
+	contentService = NewContentServiceWithOptions(store, configService, options)
+	r.ElementsMatch([]string{"banana"}, searchUrls(t, contentService, "bread"))
+	r.Empty(searchUrls(t, contentService, "split"))
+	r.NoError(contentService.Close())
+
+	// Index is rebuilt if the mapping version changed
+	idx, err := bleve.Open(options.IndexDir)
+	r.NoError(err)
+	r.NoError(idx.SetInternal(internalKeyMappingVersion, []byte("outdated")))
+	r.NoError(idx.Delete("cherry"))
+	r.NoError(idx.Close())
+
+	contentService = NewContentServiceWithOptions(store, configService, options)
+	r.ElementsMatch([]string{"cherry"}, searchUrls(t, contentService, "cherry"))
+	r.NoError(contentService.Close())
+}
+
+func TestRecreatePersistentIndex(t *testing.T) {
+	r := require.New(t)
+
+	store := NewFsStorage(t.TempDir())
+	configService := NewConfigService(store)
+	options := ContentOptions{IndexDir: filepath.Join(t.TempDir(), "index")}
+
+	contentService := NewContentServiceWithOptions(store, configService, options)
+	defer contentService.Close()
+	r.NoError(contentService.SavePage("apple", "apple", model.ContentMeta{}, ""))
+
+	// Index is replaced by a complete new one
+	r.NoError(store.WriteFile("pages/banana.md", []byte("---\ntitle: Banana\n---\nbanana")))
+	r.NoError(contentService.RecreateIndex())
+	r.ElementsMatch([]string{"apple"}, searchUrls(t, contentService, "apple"))
+	r.ElementsMatch([]string{"banana"}, searchUrls(t, contentService, "banana"))
+	r.NoDirExists(options.IndexDir + ".new")
+	r.NoDirExists(options.IndexDir + ".old")
+
+	// Existing index is kept if the new one can't be built
+	r.NoError(store.WriteFile("pages/broken.md", []byte("---\ntitle: [\n---\n")))
+	r.Error(contentService.RecreateIndex())
+	r.ElementsMatch([]string{"apple"}, searchUrls(t, contentService, "apple"))
+	r.NoError(contentService.SavePage("cherry", "cherry", model.ContentMeta{}, ""))
+	r.ElementsMatch([]string{"cherry"}, searchUrls(t, contentService, "cherry"))
+	r.NoDirExists(options.IndexDir + ".new")
+}
+
+func TestRecreateIndexKeepsConcurrentChanges(t *testing.T) {
+	r := require.New(t)
+
+	store := &hookStorage{Storage: NewFsStorage(t.TempDir()), hookPath: "pages/apple.md"}
+	configService := NewConfigService(store)
+	options := ContentOptions{IndexDir: filepath.Join(t.TempDir(), "index")}
+
+	contentService := NewContentServiceWithOptions(store, configService, options)
+	defer contentService.Close()
+	r.NoError(contentService.SavePage("apple", "apple", model.ContentMeta{}, ""))
+	r.NoError(contentService.SavePage("cherry", "cherry", model.ContentMeta{}, ""))
+	r.NoError(contentService.CreateFolder("folder", model.ContentMeta{}))
+	r.NoError(contentService.SavePage("folder/grape", "grape", model.ContentMeta{}, ""))
+
+	// Pages are changed while the new index is built
+	store.onRead = func() {
+		r.NoError(contentService.SavePage("banana", "banana", model.ContentMeta{}, ""))
+		r.NoError(contentService.DeletePage("cherry"))
+		r.NoError(contentService.MoveFolder("folder", "moved", nil))
+	}
+	r.NoError(contentService.RecreateIndex())
+
+	r.ElementsMatch([]string{"apple"}, searchUrls(t, contentService, "apple"))
+	r.ElementsMatch([]string{"banana"}, searchUrls(t, contentService, "banana"))
+	r.Empty(searchUrls(t, contentService, "cherry"))
+	r.ElementsMatch([]string{"moved/grape"}, searchUrls(t, contentService, "grape"))
+}
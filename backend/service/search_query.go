@@ -0,0 +1,294 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// searchFields maps the field names usable in search queries to fields in the index
+var searchFields = map[string]string{
+	"title":      "meta.title",
+	"content":    "content",
+	"tags":       "meta.tags",
+	"url":        "url",
+	"modifiedby": "meta.modifiedBy",
+}
+
+// maxSearchClauses limits the number of terms and phrases in a search query
+const maxSearchClauses = 20
+
+// minSearchPrefixLength is the minimum number of characters before a wildcard
+const minSearchPrefixLength = 2
+
+// searchClause is a single term or phrase of a search query
+type searchClause struct {
+	occur  rune   // '+' (required), '-' (excluded) or 0 (optional)
+	field  string // field in the index, empty for all fields
+	value  string
+	phrase bool
+	prefix bool
+}
+
+// ParseSearchQuery parses a search query and converts it into a Bleve query.
+//
+// The query consists of terms separated by whitespace:
+//   - word: pages containing the word
+//   - "some words": pages containing the exact phrase
+//   - wor*: pages containing a word starting with the prefix
+//   - field:value: restricts the term to one field (title, content, tags, url, modifiedBy)
+//   - +term: term is required, -term: term is excluded
+//
+// resolveUser converts usernames given for modifiedBy into user IDs.
+// If resolveUser is nil, the values are used as user IDs.
+func ParseSearchQuery(q string, resolveUser func(username string) (string, bool)) (query.Query, error) {
+	clauses, err := parseSearchClauses(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clauses) == 0 {
+		return bleve.NewMatchNoneQuery(), nil
+	}
+
+	boolQuery := bleve.NewBooleanQuery()
+	hasPositive := false
+	for _, c := range clauses {
+		clauseQuery := c.query(resolveUser)
+		switch c.occur {
+		case '+':
+			boolQuery.AddMust(clauseQuery)
+			hasPositive = true
+		case '-':
+			boolQuery.AddMustNot(clauseQuery)
+		default:
+			boolQuery.AddShould(clauseQuery)
+			hasPositive = true
+		}
+	}
+
+	// Queries consisting of exclusions only match everything else
+	if !hasPositive {
+		boolQuery.AddMust(bleve.NewMatchAllQuery())
+	}
+
+	return boolQuery, nil
+}
+
+func invalidSearchQuery(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", model.ErrInvalidSearchQuery, fmt.Sprintf(format, args...))
+}
+
+// parseSearchClauses splits a search query into its clauses and validates them
+func parseSearchClauses(q string) ([]searchClause, error) {
+	runes := []rune(q)
+	n := len(runes)
+	isSpace := func(i int) bool { return i < n && unicode.IsSpace(runes[i]) }
+
+	clauses := []searchClause{}
+	i := 0
+	for {
+		for isSpace(i) {
+			i++
+		}
+		if i >= n {
+			break
+		}
+
+		c := searchClause{}
+		if runes[i] == '+' || runes[i] == '-' {
+			c.occur = runes[i]
+			i++
+		}
+
+		// Read field name or beginning of value
+		start := i
+		for i < n && !isSpace(i) && runes[i] != '"' && runes[i] != ':' {
+			i++
+		}
+		fieldName := ""
+		if i < n && runes[i] == ':' {
+			// Other prefixes, e.g. of times or URLs like 12:30 or http://host, are part of the term
+			if field, found := searchFields[strings.ToLower(string(runes[start:i]))]; found {
+				fieldName = string(runes[start:i])
+				c.field = field
+				i++
+				start = i
+			}
+		}
+
+		if i < n && runes[i] == '"' {
+			if i != start {
+				return nil, invalidSearchQuery("unexpected quote in %q", string(runes[start:i+1]))
+			}
+			i++
+			for i < n && runes[i] != '"' {
+				i++
+			}
+			if i >= n {
+				return nil, invalidSearchQuery("missing closing quote")
+			}
+			c.value = strings.TrimSpace(string(runes[start+1 : i]))
+			c.phrase = true
+			i++
+			if i < n && !isSpace(i) {
+				return nil, invalidSearchQuery("missing whitespace after closing quote")
+			}
+		} else {
+			for i < n && !isSpace(i) {
+				if runes[i] == '"' {
+					return nil, invalidSearchQuery("unexpected quote in %q", string(runes[start:i+1]))
+				}
+				i++
+			}
+			c.value = string(runes[start:i])
+		}
+
+		if c.value == "" {
+			if fieldName != "" {
+				return nil, invalidSearchQuery("missing search term after %q", fieldName+":")
+			}
+			return nil, invalidSearchQuery("missing search term")
+		}
+
+		if !c.phrase {
+			value, isPrefix := strings.CutSuffix(c.value, "*")
+			if strings.Contains(value, "*") {
+				return nil, invalidSearchQuery("wildcard is only allowed at the end of a term: %q", c.value)
+			}
+			if isPrefix {
+				if len([]rune(value)) < minSearchPrefixLength {
+					return nil, invalidSearchQuery("at least %d characters required before wildcard: %q", minSearchPrefixLength, c.value)
+				}
+				if c.field == "meta.modifiedBy" {
+					return nil, invalidSearchQuery("wildcard not supported for %q", fieldName)
+				}
+				c.value = value
+				c.prefix = true
+			}
+		}
+
+		clauses = append(clauses, c)
+		if len(clauses) > maxSearchClauses {
+			return nil, invalidSearchQuery("too many terms (at most %d allowed)", maxSearchClauses)
+		}
+	}
+
+	return clauses, nil
+}
+
+// query converts the clause into a Bleve query
+func (c searchClause) query(resolveUser func(username string) (string, bool)) query.Query {
+	switch c.field {
+	case "url":
+		// URLs are indexed as keywords
+		value := strings.TrimPrefix(c.value, "/")
+		if c.prefix {
+			q := bleve.NewPrefixQuery(value)
+			q.SetField(c.field)
+			return q
+		}
+		q := bleve.NewTermQuery(value)
+		q.SetField(c.field)
+		return q
+
+	case "meta.modifiedBy":
+		userID := c.value
+		if resolveUser != nil {
+			var found bool
+			if userID, found = resolveUser(c.value); !found {
+				return bleve.NewMatchNoneQuery()
+			}
+		}
+		q := bleve.NewTermQuery(userID)
+		q.SetField(c.field)
+		return q
+	}
+
+	if c.phrase {
+		q := bleve.NewMatchPhraseQuery(c.value)
+		q.SetField(c.field)
+		return q
+	}
+
+	if c.prefix {
+		q := bleve.NewPrefixQuery(strings.ToLower(c.value))
+		q.SetField(c.field)
+		return q
+	}
+
+	q := bleve.NewMatchQuery(c.value)
+	q.SetField(c.field)
+	return q
+}
+
+// SearchFilter restricts the results of a search
+type SearchFilter struct {
+	// Folder restricts results to the folder and its descendants
+	Folder string
+	// Tags must all be present
+	Tags []string
+	// ModifiedAfter and ModifiedBefore restrict the time of the last modification (inclusive, zero means unbounded)
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// ModifiedBy is the username of the user who last modified the content
+	ModifiedBy string
+}
+
+// IsEmpty returns true if the filter doesn't restrict the results
+func (f SearchFilter) IsEmpty() bool {
+	return f.Folder == "" && len(f.Tags) == 0 &&
+		f.ModifiedAfter.IsZero() && f.ModifiedBefore.IsZero() && f.ModifiedBy == ""
+}
+
+// BuildSearchQuery parses the search query (see ParseSearchQuery) and restricts it by the filter.
+// If a filter is given, an empty search query matches all content.
+func BuildSearchQuery(q string, filter SearchFilter, resolveUser func(username string) (string, bool)) (query.Query, error) {
+	var searchQuery query.Query
+	if strings.TrimSpace(q) == "" && !filter.IsEmpty() {
+		searchQuery = bleve.NewMatchAllQuery()
+	} else {
+		var err error
+		if searchQuery, err = ParseSearchQuery(q, resolveUser); err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.IsEmpty() {
+		return searchQuery, nil
+	}
+
+	conjunction := bleve.NewConjunctionQuery(searchQuery)
+
+	if folder := strings.Trim(filter.Folder, "/"); folder != "" {
+		folderQuery := bleve.NewTermQuery(folder)
+		folderQuery.SetField("url")
+		descendantsQuery := bleve.NewPrefixQuery(folder + "/")
+		descendantsQuery.SetField("url")
+		conjunction.AddQuery(bleve.NewDisjunctionQuery(folderQuery, descendantsQuery))
+	}
+
+	for _, tag := range filter.Tags {
+		tagQuery := bleve.NewTermQuery(tag)
+		tagQuery.SetField("meta.tag")
+		conjunction.AddQuery(tagQuery)
+	}
+
+	if !filter.ModifiedAfter.IsZero() || !filter.ModifiedBefore.IsZero() {
+		inclusive := true
+		dateQuery := bleve.NewDateRangeInclusiveQuery(filter.ModifiedAfter, filter.ModifiedBefore, &inclusive, &inclusive)
+		dateQuery.SetField("meta.modifiedAt")
+		conjunction.AddQuery(dateQuery)
+	}
+
+	if filter.ModifiedBy != "" {
+		conjunction.AddQuery(searchClause{field: "meta.modifiedBy", value: filter.ModifiedBy}.query(resolveUser))
+	}
+
+	return conjunction, nil
+}
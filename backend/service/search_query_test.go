@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tfabritius/plainpage/model"
+)
+
+func TestParseSearchClauses(t *testing.T) {
+	testCases := []struct {
+		name    string
+		q       string
+		clauses []searchClause
+	}{
+		{"empty", "  ", []searchClause{}},
+		{"terms", "foo  bar", []searchClause{{value: "foo"}, {value: "bar"}}},
+		{"phrase", `"foo bar" baz`, []searchClause{{value: "foo bar", phrase: true}, {value: "baz"}}},
+		{"operators", "+foo -bar", []searchClause{{occur: '+', value: "foo"}, {occur: '-', value: "bar"}}},
+		{"prefix", "fo*", []searchClause{{value: "fo", prefix: true}}},
+		{"fields", `title:foo -Tags:"a b" url:docs/*`, []searchClause{
+			{field: "meta.title", value: "foo"},
+			{occur: '-', field: "meta.tags", value: "a b", phrase: true},
+			{field: "url", value: "docs/", prefix: true},
+		}},
+		{"modifiedBy", "modifiedBy:alice", []searchClause{{field: "meta.modifiedBy", value: "alice"}}},
+		{"hyphenated word", "e-mail", []searchClause{{value: "e-mail"}}},
+		{"unknown field", "author:alice", []searchClause{{value: "author:alice"}}},
+		{"time", "12:30", []searchClause{{value: "12:30"}}},
+		{"url", "http://host/path", []searchClause{{value: "http://host/path"}}},
+		{"colon after word", "-Note: foo", []searchClause{{occur: '-', value: "Note:"}, {value: "foo"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clauses, err := parseSearchClauses(tc.q)
+			require.NoError(t, err)
+			assert.Equal(t, tc.clauses, clauses)
+		})
+	}
+}
+
+func TestParseSearchClausesInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		q    string
+	}{
+		{"missing value after field", "title:"},
+		{"lone operator", "foo -"},
+		{"unterminated phrase", `"foo bar`},
+		{"empty phrase", `""`},
+		{"quote inside word", `foo"bar"`},
+		{"text after phrase", `"foo"bar`},
+		{"wildcard in the middle", "f*o"},
+		{"wildcard only", "*"},
+		{"short prefix", "f*"},
+		{"prefix on modifiedBy", "modifiedBy:al*"},
+		{"too many terms", "a b c d e f g h i j k l m n o p q r s t u"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSearchQuery(tc.q, nil)
+			assert.ErrorIs(t, err, model.ErrInvalidSearchQuery)
+		})
+	}
+}
@@ -64,6 +64,13 @@ func (m *mockStorage) WriteFile(fsPath string, data []byte) error {
 	return nil
 }
 
+func (m *mockStorage) CreateFile(fsPath string, data []byte) error {
+	if m.Exists(fsPath) {
+		return fmt.Errorf("could not create file %s: %w", fsPath, fs.ErrExist)
+	}
+	return m.WriteFile(fsPath, data)
+}
+
 func (m *mockStorage) DeleteFile(fsPath string) error {
 	if _, ok := m.files[fsPath]; !ok {
 		return fmt.Errorf("could not remove file %s", fsPath)
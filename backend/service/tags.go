@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"path"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/tfabritius/plainpage/model"
+)
+
+// ListTaggedContent returns all pages and folders carrying the given tag,
+// or carrying any tag if tag is empty. Results include the effective ACL.
+func (s *ContentService) ListTaggedContent(tag string) ([]model.ContentSummary, error) {
+	var tagQuery query.Query
+	if tag == "" {
+		q := bleve.NewWildcardQuery("*")
+		q.SetField("meta.tag")
+		tagQuery = q
+	} else {
+		q := bleve.NewTermQuery(tag)
+		q.SetField("meta.tag")
+		tagQuery = q
+	}
+
+	count, err := s.indexDocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FindContent(tagQuery, int(count))
+}
+
+// TagFacets counts the pages and folders matching the query by tag using the search index,
+// without checking permissions. Returns the size most frequent tags, sorted by descending count and by name.
+func (s *ContentService) TagFacets(q query.Query, size int) ([]model.TagCount, error) {
+	search := bleve.NewSearchRequest(q)
+	search.Size = 0
+	search.AddFacet("tags", bleve.NewFacetRequest("meta.tag", size))
+
+	results, err := s.searchIndex(search)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []model.TagCount{}
+	if facet := results.Facets["tags"]; facet != nil && facet.Terms != nil {
+		for _, term := range facet.Terms.Terms() {
+			tags = append(tags, model.TagCount{Tag: term.Term, Count: term.Count})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	return tags, nil
+}
+
+// FindContent returns the metadata of up to size pages and folders matching the query, sorted by URL.
+// Unlike SearchWithPagination, no fragments are highlighted. Results include the effective ACL.
+func (s *ContentService) FindContent(q query.Query, size int) ([]model.ContentSummary, error) {
+	search := bleve.NewSearchRequest(q)
+	search.Size = size
+	search.SortBy([]string{"_id"})
+
+	results, err := s.searchIndex(search)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ancestors only depend on the parent folder, so they can be shared between siblings
+	ancestorsCache := map[string][]model.UrlAndMeta{}
+
+	items := []model.ContentSummary{}
+	for _, hit := range results.Hits {
+		item, err := s.readContentSummary(hit.ID, ancestorsCache)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// ReadContentSummary returns the metadata and effective ACL of a page or folder.
+// Returns model.ErrNotFound if neither exists.
+func (s *ContentService) ReadContentSummary(urlPath string) (model.ContentSummary, error) {
+	return s.readContentSummary(urlPath, map[string][]model.UrlAndMeta{})
+}
+
+// readContentSummary reads a page or folder, using and filling the cache of ancestors by parent folder
+func (s *ContentService) readContentSummary(urlPath string, ancestorsCache map[string][]model.UrlAndMeta) (model.ContentSummary, error) {
+	item := model.ContentSummary{Url: urlPath}
+
+	if s.IsPage(urlPath) {
+		page, err := s.ReadPage(urlPath, nil)
+		if err != nil {
+			return item, err
+		}
+		item.Meta = page.Meta
+	} else if s.IsFolder(urlPath) {
+		meta, err := s.ReadFolderMeta(urlPath)
+		if err != nil {
+			return item, err
+		}
+		item.Meta = meta
+		item.IsFolder = true
+	} else {
+		return item, model.ErrNotFound
+	}
+
+	parent := path.Dir(urlPath)
+	ancestors, found := ancestorsCache[parent]
+	if !found {
+		var err error
+		ancestors, err = s.ReadAncestors(urlPath)
+		if err != nil {
+			return item, err
+		}
+		ancestorsCache[parent] = ancestors
+	}
+	item.EffectiveACL = s.GetEffectivePermissions(item.Meta, ancestors)
+
+	return item, nil
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -29,6 +33,14 @@ func NewUserService(store model.Storage, config *ConfigService) *UserService {
 		}
 	}
 
+	// Initialize groups.yml
+	if !s.storage.Exists("groups.yml") {
+		err := s.saveAllGroupsUnlocked([]model.Group{})
+		if err != nil {
+			log.Fatalln("Could not create groups.yml:", err)
+		}
+	}
+
 	return &s
 }
 
@@ -36,6 +48,10 @@ type UserService struct {
 	storage model.Storage
 	config  *ConfigService
 	mu      sync.RWMutex
+
+	// groups caches the content of groups.yml, nil if not read yet
+	groups   []model.Group
+	groupsMu sync.Mutex
 }
 
 func (s *UserService) ReadAll() ([]model.User, error) {
@@ -240,6 +256,7 @@ func (s *UserService) Save(user model.User) error {
 	existingUser.Username = user.Username
 	existingUser.DisplayName = user.DisplayName
 	existingUser.PasswordHash = user.PasswordHash
+	existingUser.Email = user.Email
 
 	if err := s.saveAllUnlocked(users); err != nil {
 		return fmt.Errorf("could not save users: %w", err)
@@ -248,7 +265,42 @@ func (s *UserService) Save(user model.User) error {
 	return nil
 }
 
-func (s *UserService) DeleteByUsername(username string) error {
+const feedTokenLength = 32
+
+// hashFeedToken returns the hash of a feed token as stored in users.yml
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateFeedToken generates a new feed token for the user, replacing the previous one.
+// Only a hash of the token is stored.
+func (s *UserService) CreateFeedToken(userID string) (string, error) {
+	token, err := utils.GenerateRandomString(feedTokenLength)
+	if err != nil {
+		return "", fmt.Errorf("could not generate feed token: %w", err)
+	}
+
+	if err := s.setFeedTokenHash(userID, hashFeedToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// DeleteFeedToken revokes the feed token of the user
+func (s *UserService) DeleteFeedToken(userID string) error {
+	return s.setFeedTokenHash(userID, "")
+}
+
+func (s *UserService) setFeedTokenHash(userID, hash string) error {
+	return s.update(userID, func(user *model.User) {
+		user.FeedTokenHash = hash
+	})
+}
+
+// update modifies the user with the given ID and saves all users
+func (s *UserService) update(userID string, modify func(user *model.User)) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -257,17 +309,121 @@ func (s *UserService) DeleteByUsername(username string) error {
 		return fmt.Errorf("could not read users: %w", err)
 	}
 
+	user := s.filterById(users, userID)
+	if user == nil {
+		return model.ErrNotFound
+	}
+
+	modify(user)
+
+	if err := s.saveAllUnlocked(users); err != nil {
+		return fmt.Errorf("could not save users: %w", err)
+	}
+
+	return nil
+}
+
+// GetByFeedToken returns the user the feed token belongs to
+func (s *UserService) GetByFeedToken(token string) (model.User, error) {
+	users, err := s.ReadAll()
+	if err != nil {
+		return model.User{}, fmt.Errorf("could not read users: %w", err)
+	}
+
+	hash := []byte(hashFeedToken(token))
+	for _, user := range users {
+		if user.FeedTokenHash != "" && subtle.ConstantTimeCompare([]byte(user.FeedTokenHash), hash) == 1 {
+			return user, nil
+		}
+	}
+
+	return model.User{}, model.ErrNotFound
+}
+
+// Subscribe lets the user watch the page or folder at urlPath, replacing an existing subscription of it
+func (s *UserService) Subscribe(userID, urlPath string, mode model.NotificationMode) error {
+	return s.update(userID, func(user *model.User) {
+		for i := range user.Subscriptions {
+			if user.Subscriptions[i].Url == urlPath {
+				user.Subscriptions[i].Mode = mode
+				return
+			}
+		}
+		user.Subscriptions = append(user.Subscriptions, model.Subscription{Url: urlPath, Mode: mode})
+	})
+}
+
+// Unsubscribe removes the subscription of the page or folder at urlPath.
+// Returns model.ErrNotFound if the user doesn't watch it.
+func (s *UserService) Unsubscribe(userID, urlPath string) error {
 	found := false
+	err := s.update(userID, func(user *model.User) {
+		user.Subscriptions = slices.DeleteFunc(user.Subscriptions, func(sub model.Subscription) bool {
+			found = found || sub.Url == urlPath
+			return sub.Url == urlPath
+		})
+	})
+	if err == nil && !found {
+		return model.ErrNotFound
+	}
+	return err
+}
+
+// MoveSubscriptions updates the subscriptions of all users after the page or folder at urlPath
+// was moved to destinationPath. Subscriptions of descendants of a folder are updated as well.
+func (s *UserService) MoveSubscriptions(urlPath, destinationPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.readAllUnlocked()
+	if err != nil {
+		return fmt.Errorf("could not read users: %w", err)
+	}
+
+	changed := false
+	for i := range users {
+		for j, sub := range users[i].Subscriptions {
+			if sub.Url == urlPath {
+				users[i].Subscriptions[j].Url = destinationPath
+				changed = true
+			} else if rest, found := strings.CutPrefix(sub.Url, urlPath+"/"); found {
+				users[i].Subscriptions[j].Url = destinationPath + "/" + rest
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := s.saveAllUnlocked(users); err != nil {
+		return fmt.Errorf("could not save users: %w", err)
+	}
+
+	return nil
+}
+
+func (s *UserService) DeleteByUsername(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.readAllUnlocked()
+	if err != nil {
+		return fmt.Errorf("could not read users: %w", err)
+	}
+
+	deletedIDs := []string{}
 
 	for i := 0; i < len(users); {
 		if strings.EqualFold(users[i].Username, username) {
-			found = true
+			deletedIDs = append(deletedIDs, users[i].ID)
 			users = append(users[:i], users[i+1:]...)
 		} else {
 			i++
 		}
 	}
-	if !found {
+	if len(deletedIDs) == 0 {
 		return model.ErrNotFound
 	}
 
@@ -275,9 +431,16 @@ func (s *UserService) DeleteByUsername(username string) error {
 		return fmt.Errorf("could not save users: %w", err)
 	}
 
+	for _, id := range deletedIDs {
+		if err := s.removeMemberUnlocked(id); err != nil {
+			return fmt.Errorf("could not remove user from groups: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// EnhanceACLWithUserInfo adds the users and groups referenced by the subjects to the ACL
 func (s *UserService) EnhanceACLWithUserInfo(acl *[]model.AccessRule) error {
 	if acl != nil {
 		users, err := s.ReadAll()
@@ -285,11 +448,20 @@ func (s *UserService) EnhanceACLWithUserInfo(acl *[]model.AccessRule) error {
 			return fmt.Errorf("could not read users: %w", err)
 		}
 
+		groups, err := s.ReadAllGroups()
+		if err != nil {
+			return fmt.Errorf("could not read groups: %w", err)
+		}
+
 		for i, rule := range *acl {
 			if userId, found := strings.CutPrefix(rule.Subject, "user:"); found {
 				user := s.filterById(users, userId)
 				(*acl)[i].User = user
 			}
+			if groupId, found := strings.CutPrefix(rule.Subject, "group:"); found {
+				group := filterGroupById(groups, groupId)
+				(*acl)[i].Group = group
+			}
 		}
 	}
 
@@ -324,10 +496,41 @@ func (s *UserService) CheckContentPermissions(
 	return s.checkPermissions(acl, userID, op, false)
 }
 
-func (s *UserService) checkPermissions(acl []model.AccessRule, userID string, op model.AccessOp, aclIsApp bool) error {
+// Specificity of ACL subjects, from most to least specific
+const (
+	specificityUser = iota
+	specificityGroup
+	specificityAll
+	specificityAnonymous
+	noMatch = -1
+)
+
+// PermissionDecision describes how a permission check was decided
+type PermissionDecision struct {
+	Allowed bool
+
+	// Allowed because of admin privileges granted in the global ACL
+	Admin bool
 
-	// Allow access if anonymous is allowed
-	if s.compareACL(acl, "anonymous", op) {
+	// Index of the deciding rule in the checked ACL, or in the global ACL if Admin is set.
+	// -1 if no rule decided.
+	Rule int
+}
+
+// checkPermissions checks if the user may perform the operation according to the ACL.
+// The precedence is:
+//  1. Users with admin privileges in the global ACL are always allowed.
+//  2. The rules of the most specific subject matching the user decide:
+//     user:{id}, then group:{id}, then all, then anonymous.
+//     Rules neither allowing nor denying the operation are skipped.
+//  3. If rules of the same specificity allow and deny the operation, e.g. for two groups of the user, deny wins.
+//  4. Without a deciding rule, access is denied.
+func (s *UserService) checkPermissions(acl []model.AccessRule, userID string, op model.AccessOp, aclIsApp bool) error {
+	decision, err := s.decidePermissions(acl, userID, op, aclIsApp)
+	if err != nil {
+		return err
+	}
+	if decision.Allowed {
 		return nil
 	}
 
@@ -338,48 +541,150 @@ func (s *UserService) checkPermissions(acl []model.AccessRule, userID string, op
 		}
 	}
 
-	// Allow if all users are allowed
-	if s.compareACL(acl, "all", op) {
-		return nil
+	// Deny access
+	return &AccessDeniedError{
+		StatusCode: http.StatusForbidden,
 	}
+}
 
-	// Allow if user is allowed
-	if s.compareACL(acl, "user:"+userID, op) {
-		return nil
+// ExplainContentPermissions checks like CheckContentPermissions if the user may perform the operation
+// and returns which rule decided
+func (s *UserService) ExplainContentPermissions(acl []model.AccessRule, userID string, op model.AccessOp) (PermissionDecision, error) {
+	return s.decidePermissions(acl, userID, op, false)
+}
+
+// CheckGroupContentPermissions checks if the ACL allows the operation to all members of the group,
+// based on the rules for the group, all registered users and anonymous users.
+// Rules for individual members and their other groups are not taken into account.
+func (s *UserService) CheckGroupContentPermissions(acl []model.AccessRule, groupID string, op model.AccessOp) error {
+	match := func(subject string) (int, error) {
+		switch subject {
+		case "group:" + groupID:
+			return specificityGroup, nil
+		case "all":
+			return specificityAll, nil
+		case "anonymous":
+			return specificityAnonymous, nil
+		}
+		return noMatch, nil
+	}
+
+	decision, err := s.decideWithMatcher(acl, match, true, op, false)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return &AccessDeniedError{
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
+
+func (s *UserService) decidePermissions(acl []model.AccessRule, userID string, op model.AccessOp, aclIsApp bool) (PermissionDecision, error) {
+	return s.decideWithMatcher(acl, s.subjectMatcher(userID), userID != "", op, aclIsApp)
+}
+
+// decideWithMatcher decides about the operation for the subjects given by the matcher,
+// checking admin privileges only for logged in users
+func (s *UserService) decideWithMatcher(acl []model.AccessRule, match func(subject string) (int, error), loggedIn bool, op model.AccessOp, aclIsApp bool) (PermissionDecision, error) {
+	allowed, rule, err := s.evaluateACL(acl, match, op)
+	if err != nil {
+		return PermissionDecision{}, err
+	}
+	if allowed || !loggedIn {
+		return PermissionDecision{Allowed: allowed, Rule: rule}, nil
 	}
 
 	// Read global ACL
+	globalACL := acl
 	if !aclIsApp {
 		cfg, err := s.config.Read()
 		if err != nil {
-			return err
+			return PermissionDecision{}, err
 		}
-		acl = cfg.ACL
+		globalACL = cfg.ACL
 	}
 
 	// Allow if user has admin privileges
-	if s.compareACL(acl, "user:"+userID, model.AccessOpAdmin) {
-		return nil
+	isAdmin, adminRule, err := s.evaluateACL(globalACL, match, model.AccessOpAdmin)
+	if err != nil {
+		return PermissionDecision{}, err
 	}
-
-	// Deny access
-	return &AccessDeniedError{
-		StatusCode: http.StatusForbidden,
+	if isAdmin {
+		return PermissionDecision{Allowed: true, Admin: true, Rule: adminRule}, nil
 	}
+
+	return PermissionDecision{Allowed: false, Rule: rule}, nil
 }
 
-func (*UserService) compareACL(acl []model.AccessRule, subject string, op model.AccessOp) bool {
-	for _, rule := range acl {
-		if rule.Subject == subject {
-			for _, o := range rule.Operations {
-				if o == op {
-					return true
+// subjectMatcher returns a function determining the specificity of a subject matching the user,
+// or noMatch if it doesn't match. Groups are only read if a group subject is encountered.
+func (s *UserService) subjectMatcher(userID string) func(subject string) (int, error) {
+	var groupIDs []string
+
+	return func(subject string) (int, error) {
+		if subject == "anonymous" {
+			return specificityAnonymous, nil
+		}
+		if userID == "" {
+			return noMatch, nil
+		}
+		if subject == "all" {
+			return specificityAll, nil
+		}
+		if subject == "user:"+userID {
+			return specificityUser, nil
+		}
+		if groupID, found := strings.CutPrefix(subject, "group:"); found {
+			if groupIDs == nil {
+				var err error
+				if groupIDs, err = s.GroupIDsOfUser(userID); err != nil {
+					return noMatch, err
 				}
 			}
-			return false
+			if slices.Contains(groupIDs, groupID) {
+				return specificityGroup, nil
+			}
 		}
+		return noMatch, nil
 	}
-	return false
+}
+
+// evaluateACL checks if the ACL allows the operation, following the precedence described at checkPermissions.
+// Returns the index of the deciding rule, or -1 if no rule decided.
+func (*UserService) evaluateACL(acl []model.AccessRule, match func(subject string) (int, error), op model.AccessOp) (bool, int, error) {
+	decidingSpecificity := noMatch
+	decidingRule := -1
+	allowed := false
+
+	for i, rule := range acl {
+		allows := slices.Contains(rule.Operations, op)
+		denies := slices.Contains(rule.Deny, op)
+		if !allows && !denies {
+			continue
+		}
+
+		specificity, err := match(rule.Subject)
+		if err != nil {
+			return false, -1, err
+		}
+		if specificity == noMatch {
+			continue
+		}
+
+		if decidingSpecificity == noMatch || specificity < decidingSpecificity {
+			decidingSpecificity = specificity
+			decidingRule = i
+			allowed = !denies
+		} else if specificity == decidingSpecificity && denies && allowed {
+			decidingRule = i
+			allowed = false
+		}
+	}
+
+	return allowed, decidingRule, nil
 }
 
 func (*UserService) isUsernameUnique(users []model.User, username string) bool {
@@ -206,3 +206,304 @@ func TestUserService_DeleteByUsername(t *testing.T) {
 	r.Error(err)
 	r.ErrorIs(err, model.ErrNotFound)
 }
+
+func TestUserService_FeedToken(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	user, err := userService.Create("testuser", "test-password", "Test User")
+	r.NoError(err)
+
+	token, err := userService.CreateFeedToken(user.ID)
+	r.NoError(err)
+	r.Len(token, feedTokenLength)
+
+	// Only the hash is stored
+	stored, err := userService.GetById(user.ID)
+	r.NoError(err)
+	r.NotEmpty(stored.FeedTokenHash)
+	r.NotContains(stored.FeedTokenHash, token)
+
+	foundUser, err := userService.GetByFeedToken(token)
+	r.NoError(err)
+	r.Equal(user.ID, foundUser.ID)
+
+	_, err = userService.GetByFeedToken("invalid")
+	r.ErrorIs(err, model.ErrNotFound)
+
+	// Saving the user keeps the token
+	stored.DisplayName = "Changed"
+	r.NoError(userService.Save(stored))
+	_, err = userService.GetByFeedToken(token)
+	r.NoError(err)
+
+	// Creating a new token replaces the old one
+	newToken, err := userService.CreateFeedToken(user.ID)
+	r.NoError(err)
+	_, err = userService.GetByFeedToken(token)
+	r.ErrorIs(err, model.ErrNotFound)
+	_, err = userService.GetByFeedToken(newToken)
+	r.NoError(err)
+
+	r.NoError(userService.DeleteFeedToken(user.ID))
+	_, err = userService.GetByFeedToken(newToken)
+	r.ErrorIs(err, model.ErrNotFound)
+
+	_, err = userService.CreateFeedToken("unknown")
+	r.ErrorIs(err, model.ErrNotFound)
+}
+
+func TestUserService_Subscriptions(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	user, err := userService.Create("testuser", "test-password", "Test User")
+	r.NoError(err)
+
+	r.NoError(userService.Subscribe(user.ID, "docs", model.NotifyImmediately))
+	r.NoError(userService.Subscribe(user.ID, "docs/guide", model.NotifyDigest))
+	r.NoError(userService.Subscribe(user.ID, "other", model.NotifyDigest))
+
+	// Subscribing again replaces the mode
+	r.NoError(userService.Subscribe(user.ID, "docs", model.NotifyDigest))
+
+	// Saving the user keeps the subscriptions
+	r.NoError(userService.Save(user))
+
+	stored, err := userService.GetById(user.ID)
+	r.NoError(err)
+	r.Equal([]model.Subscription{
+		{Url: "docs", Mode: model.NotifyDigest},
+		{Url: "docs/guide", Mode: model.NotifyDigest},
+		{Url: "other", Mode: model.NotifyDigest},
+	}, stored.Subscriptions)
+
+	// Subscriptions follow moved content, including descendants
+	r.NoError(userService.MoveSubscriptions("docs", "manual"))
+	r.NoError(userService.MoveSubscriptions("doc", "ignored"))
+	stored, err = userService.GetById(user.ID)
+	r.NoError(err)
+	r.Equal([]model.Subscription{
+		{Url: "manual", Mode: model.NotifyDigest},
+		{Url: "manual/guide", Mode: model.NotifyDigest},
+		{Url: "other", Mode: model.NotifyDigest},
+	}, stored.Subscriptions)
+
+	r.NoError(userService.Unsubscribe(user.ID, "manual"))
+	r.ErrorIs(userService.Unsubscribe(user.ID, "manual"), model.ErrNotFound)
+	stored, err = userService.GetById(user.ID)
+	r.NoError(err)
+	r.Len(stored.Subscriptions, 2)
+
+	r.ErrorIs(userService.Subscribe("unknown", "docs", model.NotifyDigest), model.ErrNotFound)
+}
+
+func TestUserService_Groups(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	alice, err := userService.Create("testuser-a", "test-password", "Alice")
+	r.NoError(err)
+	bob, err := userService.Create("testuser-b", "test-password", "Bob")
+	r.NoError(err)
+
+	group, err := userService.CreateGroup(" Editors ", []string{alice.ID, alice.ID})
+	r.NoError(err)
+	r.NotEmpty(group.ID)
+	r.Equal("Editors", group.Name)
+	r.Equal([]string{alice.ID}, group.Members)
+
+	_, err = userService.CreateGroup("editors", nil)
+	r.ErrorIs(err, model.ErrGroupExistsAlready)
+	_, err = userService.CreateGroup(" ", nil)
+	r.ErrorIs(err, model.ErrInvalidGroupName)
+	_, err = userService.CreateGroup("Reviewers", []string{"unknown"})
+	r.ErrorIs(err, model.ErrInvalidGroupMember)
+
+	reviewers, err := userService.CreateGroup("Reviewers", []string{alice.ID, bob.ID})
+	r.NoError(err)
+
+	ids, err := userService.GroupIDsOfUser(alice.ID)
+	r.NoError(err)
+	r.ElementsMatch([]string{group.ID, reviewers.ID}, ids)
+
+	// Renaming to the name of another group is rejected
+	reviewers.Name = "EDITORS"
+	r.ErrorIs(userService.SaveGroup(reviewers), model.ErrGroupExistsAlready)
+
+	group.Members = []string{bob.ID}
+	r.NoError(userService.SaveGroup(group))
+	stored, err := userService.GetGroupById(group.ID)
+	r.NoError(err)
+	r.Equal([]string{bob.ID}, stored.Members)
+
+	// Deleted users are removed from all groups
+	r.NoError(userService.DeleteByUsername("testuser-b"))
+	stored, err = userService.GetGroupById(group.ID)
+	r.NoError(err)
+	r.Empty(stored.Members)
+	stored, err = userService.GetGroupById(reviewers.ID)
+	r.NoError(err)
+	r.Equal([]string{alice.ID}, stored.Members)
+
+	r.NoError(userService.DeleteGroup(group.ID))
+	r.ErrorIs(userService.DeleteGroup(group.ID), model.ErrNotFound)
+	_, err = userService.GetGroupById(group.ID)
+	r.ErrorIs(err, model.ErrNotFound)
+	r.ErrorIs(userService.SaveGroup(group), model.ErrNotFound)
+}
+
+func TestUserService_GroupsCache(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	group, err := userService.CreateGroup("Editors", nil)
+	r.NoError(err)
+
+	// Modifying returned groups doesn't affect the cache
+	groups, err := userService.ReadAllGroups()
+	r.NoError(err)
+	groups[0].Name = "Modified"
+	stored, err := userService.GetGroupById(group.ID)
+	r.NoError(err)
+	r.Equal("Editors", stored.Name)
+
+	// Changes to groups.yml are picked up after invalidating the cache
+	r.NoError(mock.WriteFile("groups.yml", []byte("- id: other\n  name: Other\n")))
+	_, err = userService.GetGroupById("other")
+	r.ErrorIs(err, model.ErrNotFound)
+
+	userService.InvalidateGroups()
+	_, err = userService.GetGroupById(group.ID)
+	r.ErrorIs(err, model.ErrNotFound)
+	stored, err = userService.GetGroupById("other")
+	r.NoError(err)
+	r.Equal("Other", stored.Name)
+}
+
+func TestUserService_GroupPermissions(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	user, err := userService.Create("testuser", "test-password", "Test User")
+	r.NoError(err)
+	group, err := userService.CreateGroup("Editors", []string{user.ID})
+	r.NoError(err)
+
+	acl := []model.AccessRule{
+		{Subject: "group:" + group.ID, Operations: []model.AccessOp{model.AccessOpRead}},
+	}
+	r.NoError(userService.CheckContentPermissions(acl, user.ID, model.AccessOpRead))
+	r.Error(userService.CheckContentPermissions(acl, user.ID, model.AccessOpWrite))
+
+	r.NoError(userService.EnhanceACLWithUserInfo(&acl))
+	r.NotNil(acl[0].Group)
+	r.Equal("Editors", acl[0].Group.Name)
+
+	// Admin privileges can be granted to groups in the global ACL
+	cfg, err := configService.Read()
+	r.NoError(err)
+	cfg.ACL = append(cfg.ACL, model.AccessRule{Subject: "group:" + group.ID, Operations: []model.AccessOp{model.AccessOpAdmin}})
+	r.NoError(configService.Write(cfg))
+	r.NoError(userService.CheckContentPermissions(acl, user.ID, model.AccessOpWrite))
+	r.NoError(userService.CheckAppPermissions(user.ID, model.AccessOpAdmin))
+
+	// Removing the user from the group revokes the permissions
+	group.Members = []string{}
+	r.NoError(userService.SaveGroup(group))
+	r.Error(userService.CheckContentPermissions(acl, user.ID, model.AccessOpRead))
+	r.Error(userService.CheckAppPermissions(user.ID, model.AccessOpAdmin))
+}
+
+func TestUserService_CheckGroupContentPermissions(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	user, err := userService.Create("testuser", "test-password", "Test User")
+	r.NoError(err)
+	group, err := userService.CreateGroup("Editors", []string{user.ID})
+	r.NoError(err)
+
+	read := []model.AccessOp{model.AccessOpRead}
+
+	// Rules for the group, all users and anonymous users apply
+	r.NoError(userService.CheckGroupContentPermissions([]model.AccessRule{{Subject: "group:" + group.ID, Operations: read}}, group.ID, model.AccessOpRead))
+	r.NoError(userService.CheckGroupContentPermissions([]model.AccessRule{{Subject: "all", Operations: read}}, group.ID, model.AccessOpRead))
+	r.NoError(userService.CheckGroupContentPermissions([]model.AccessRule{{Subject: "anonymous", Operations: read}}, group.ID, model.AccessOpRead))
+	r.Error(userService.CheckGroupContentPermissions([]model.AccessRule{{Subject: "all", Operations: read}, {Subject: "group:" + group.ID, Deny: read}}, group.ID, model.AccessOpRead))
+
+	// Rules for individual members don't apply
+	r.Error(userService.CheckGroupContentPermissions([]model.AccessRule{{Subject: "user:" + user.ID, Operations: read}}, group.ID, model.AccessOpRead))
+}
+
+func TestUserService_PermissionPrecedence(t *testing.T) {
+	r := require.New(t)
+	mock := newMockStorage()
+	configService := NewConfigService(mock)
+	userService := NewUserService(mock, configService)
+
+	user, err := userService.Create("testuser", "test-password", "Test User")
+	r.NoError(err)
+	editors, err := userService.CreateGroup("Editors", []string{user.ID})
+	r.NoError(err)
+	guests, err := userService.CreateGroup("Guests", []string{user.ID})
+	r.NoError(err)
+
+	read := []model.AccessOp{model.AccessOpRead}
+	tests := []struct {
+		name    string
+		acl     []model.AccessRule
+		userID  string
+		allowed bool
+	}{
+		{"all allowed, user denied", []model.AccessRule{{Subject: "all", Operations: read}, {Subject: "user:" + user.ID, Deny: read}}, user.ID, false},
+		{"all denied, user allowed", []model.AccessRule{{Subject: "all", Deny: read}, {Subject: "user:" + user.ID, Operations: read}}, user.ID, true},
+		{"all allowed, group denied", []model.AccessRule{{Subject: "all", Operations: read}, {Subject: "group:" + editors.ID, Deny: read}}, user.ID, false},
+		{"group denied, user allowed", []model.AccessRule{{Subject: "group:" + editors.ID, Deny: read}, {Subject: "user:" + user.ID, Operations: read}}, user.ID, true},
+		{"one group allowed, other denied", []model.AccessRule{{Subject: "group:" + editors.ID, Operations: read}, {Subject: "group:" + guests.ID, Deny: read}}, user.ID, false},
+		{"allowed and denied in same rule", []model.AccessRule{{Subject: "user:" + user.ID, Operations: read, Deny: read}}, user.ID, false},
+		{"anonymous allowed, all denied", []model.AccessRule{{Subject: "anonymous", Operations: read}, {Subject: "all", Deny: read}}, user.ID, false},
+		{"anonymous allowed, all denied, anonymous user", []model.AccessRule{{Subject: "anonymous", Operations: read}, {Subject: "all", Deny: read}}, "", true},
+		{"rule not mentioning the operation", []model.AccessRule{{Subject: "anonymous", Operations: read}, {Subject: "user:" + user.ID, Operations: []model.AccessOp{model.AccessOpWrite}}}, user.ID, true},
+		{"other user denied", []model.AccessRule{{Subject: "all", Operations: read}, {Subject: "user:other", Deny: read}}, user.ID, true},
+		{"no rule", []model.AccessRule{}, user.ID, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := userService.CheckContentPermissions(tc.acl, tc.userID, model.AccessOpRead)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	// Admins are not affected by deny rules
+	cfg, err := configService.Read()
+	r.NoError(err)
+	cfg.ACL = append(cfg.ACL, model.AccessRule{Subject: "user:" + user.ID, Operations: []model.AccessOp{model.AccessOpAdmin}})
+	r.NoError(configService.Write(cfg))
+	r.NoError(userService.CheckContentPermissions([]model.AccessRule{{Subject: "user:" + user.ID, Deny: read}}, user.ID, model.AccessOpRead))
+
+	// Admin privileges can be denied to members of an admin group
+	cfg.ACL = []model.AccessRule{
+		{Subject: "group:" + editors.ID, Operations: []model.AccessOp{model.AccessOpAdmin}},
+		{Subject: "user:" + user.ID, Deny: []model.AccessOp{model.AccessOpAdmin}},
+	}
+	r.NoError(configService.Write(cfg))
+	r.Error(userService.CheckAppPermissions(user.ID, model.AccessOpAdmin))
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"encoding/json"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -268,13 +269,13 @@ func (s *ConfigTestSuite) TestPatchConfigAppTitle() {
 func (s *ConfigTestSuite) TestPatchConfigErrors() {
 	r := s.Require()
 
-	// Test: Unsupported operation (not "replace")
+	// Test: Unsupported operation
 	{
 		res := s.api("PATCH", "/config", []model.PatchOperation{
-			{Op: "add", Path: "/appTitle", Value: str2json("Test")},
+			{Op: "merge", Path: "/appTitle", Value: str2json("Test")},
 		}, s.adminToken)
 		r.Equal(400, res.Code)
-		r.Contains(res.Body.String(), "operation add not supported")
+		r.Contains(res.Body.String(), "operation merge not supported")
 	}
 
 	// Test: Missing value (null value not allowed for non-nullable string field)
@@ -339,6 +340,66 @@ func (s *ConfigTestSuite) TestPatchConfigMultipleOperations() {
 	r.True(appBody.AllowRegister, "Anonymous should now be able to register")
 }
 
+// TestPatchConfigConditionalACL tests atomic ACL updates guarded by "test" operations
+func (s *ConfigTestSuite) TestPatchConfigConditionalACL() {
+	r := s.Require()
+
+	acl := append(s.defaultAcl, model.AccessRule{Subject: "user:" + s.adminUserID, Operations: []model.AccessOp{model.AccessOpAdmin}})
+	s.saveGlobalAcl(s.adminToken, acl)
+
+	res := s.api("GET", "/config", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	current, _ := jsonbody[model.Config](res)
+
+	rule := model.AccessRule{Subject: "anonymous", Operations: []model.AccessOp{model.AccessOpRegister}}
+	ruleJSON, err := json.Marshal(rule)
+	r.NoError(err)
+	ruleValue := json.RawMessage(ruleJSON)
+
+	// ACL as returned by GET /config (including user info) matches
+	res = s.api("PATCH", "/config", []model.PatchOperation{
+		{Op: "test", Path: "/acl", Value: acl2json(current.ACL)},
+		{Op: "add", Path: "/acl/-", Value: &ruleValue},
+	}, s.adminToken)
+	r.Equal(200, res.Code)
+
+	cfg, err := s.app.Config.Read()
+	r.NoError(err)
+	r.Len(cfg.ACL, len(acl)+1)
+	r.Equal("anonymous", cfg.ACL[len(acl)].Subject)
+
+	// Outdated ACL doesn't match, no operation is applied
+	res = s.api("PATCH", "/config", []model.PatchOperation{
+		{Op: "replace", Path: "/appTitle", Value: str2json("Changed")},
+		{Op: "test", Path: "/acl", Value: acl2json(current.ACL)},
+		{Op: "remove", Path: "/acl/0"},
+	}, s.adminToken)
+	r.Equal(409, res.Code)
+
+	cfg, err = s.app.Config.Read()
+	r.NoError(err)
+	r.Len(cfg.ACL, len(acl)+1)
+	r.Equal("PlainPage", cfg.AppTitle)
+
+	// Operations of a single rule can be changed
+	res = s.api("PATCH", "/config", []model.PatchOperation{
+		{Op: "test", Path: "/acl/" + strconv.Itoa(len(acl)) + "/subject", Value: str2json("anonymous")},
+		{Op: "replace", Path: "/acl/" + strconv.Itoa(len(acl)) + "/subject", Value: str2json("all")},
+	}, s.adminToken)
+	r.Equal(200, res.Code)
+
+	cfg, err = s.app.Config.Read()
+	r.NoError(err)
+	r.Equal("all", cfg.ACL[len(acl)].Subject)
+
+	// User info can't be patched
+	res = s.api("PATCH", "/config", []model.PatchOperation{
+		{Op: "replace", Path: "/acl/0/user/username", Value: str2json("x")},
+	}, s.adminToken)
+	r.Equal(400, res.Code)
+	r.Contains(res.Body.String(), "path not supported")
+}
+
 // TestPatchConfigEmptyOperations tests sending an empty operations array
 func (s *ConfigTestSuite) TestPatchConfigEmptyOperations() {
 	r := s.Require()
@@ -381,6 +442,14 @@ func (s *ConfigTestSuite) TestConfigACLValidation() {
 			},
 			responseCode: 200,
 		},
+		{
+			name: "valid:group-admin",
+			acl: []model.AccessRule{
+				{Subject: "user:" + s.adminUserID, Operations: []model.AccessOp{model.AccessOpAdmin}},
+				{Subject: "group:admins", Operations: []model.AccessOp{model.AccessOpAdmin}},
+			},
+			responseCode: 200,
+		},
 		{
 			name:         "valid:user-admin-register",
 			acl:          []model.AccessRule{{Subject: "user:" + s.adminUserID, Operations: []model.AccessOp{model.AccessOpAdmin, model.AccessOpRegister}}},
@@ -406,8 +475,8 @@ func (s *ConfigTestSuite) TestConfigACLValidation() {
 			responseCode: 400,
 		},
 		{
-			name:         "invalid:subject-group",
-			acl:          []model.AccessRule{{Subject: "group:admins", Operations: []model.AccessOp{model.AccessOpAdmin}}},
+			name:         "invalid:subject-group-empty-id",
+			acl:          []model.AccessRule{{Subject: "group:", Operations: []model.AccessOp{model.AccessOpAdmin}}},
 			responseCode: 400,
 		},
 		{
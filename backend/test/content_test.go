@@ -1,14 +1,24 @@
 package test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"golang.org/x/time/rate"
+
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/tfabritius/plainpage/libs/mail"
 	"github.com/tfabritius/plainpage/model"
+	"github.com/tfabritius/plainpage/server"
 )
 
 type ContentTestSuite struct {
@@ -26,6 +36,11 @@ func (s *ContentTestSuite) SetupSuite() {
 func (s *ContentTestSuite) SetupTest() {
 	r := s.Require()
 
+	// Each test starts with fresh search rate limits
+	s.app.SearchLimiterByIP = server.NewRateLimiter(3, rate.Every(10*time.Second), 15*time.Minute)
+	s.app.SearchLimiterByUser = server.NewRateLimiter(30, rate.Every(2*time.Second), 15*time.Minute)
+	s.handler = s.app.GetHandler()
+
 	// Create folders with ACL
 	folders := []struct {
 		Name string
@@ -95,6 +110,8 @@ func (s *ContentTestSuite) TearDownTest() {
 	r := s.Require()
 
 	r.NoError(s.app.Content.DeleteAll())
+	r.NoError(s.app.Redirects.DeleteAll())
+	r.NoError(s.app.Changes.DeleteAll())
 }
 
 func (s *ContentTestSuite) TestCreatePage() {
@@ -1146,6 +1163,1170 @@ func (s *ContentTestSuite) TestCombinedPatchOperations() {
 	}
 }
 
+func (s *ContentTestSuite) TestPatchTags() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/page", "Content", model.ContentMeta{Title: "Title", Tags: []string{"a"}}, ""))
+	r.NoError(s.app.Content.CreateFolder("published/folder", model.ContentMeta{Title: "Folder"}))
+
+	attic, err := s.app.Content.ListAttic("published/page")
+	r.NoError(err)
+	revisions := len(attic)
+
+	patch := func(url string, ops ...model.PatchOperation) int {
+		return s.api("PATCH", "/pages/"+url, ops, s.userToken).Code
+	}
+	readTags := func() []string {
+		page, err := s.app.Content.ReadPage("published/page", nil)
+		r.NoError(err)
+		return page.Meta.Tags
+	}
+
+	// Replace all tags
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "replace", Path: "/page/meta/tags", Value: strs2json([]string{"b", " c ", "b", ""})}))
+	r.Equal([]string{"b", "c"}, readTags())
+
+	// Standard operations address tags by index
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "add", Path: "/page/meta/tags/-", Value: str2json("d")}))
+	r.Equal([]string{"b", "c", "d"}, readTags())
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "remove", Path: "/page/meta/tags/2"}))
+	r.Equal([]string{"b", "c"}, readTags())
+	r.Equal(409, patch("published/page",
+		model.PatchOperation{Op: "test", Path: "/page/meta/tags/0", Value: str2json("c")},
+		model.PatchOperation{Op: "remove", Path: "/page/meta/tags/0"},
+	))
+	r.Equal([]string{"b", "c"}, readTags())
+
+	// Two users adding tags independently don't overwrite each other
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: str2json("d")}))
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: str2json("e")}))
+	r.Equal([]string{"b", "c", "d", "e"}, readTags())
+
+	// Adding an existing tag doesn't duplicate it
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: str2json("b")}))
+	r.Equal([]string{"b", "c", "d", "e"}, readTags())
+
+	// Remove tags by value, removing a missing tag is ignored
+	r.Equal(200, patch("published/page",
+		model.PatchOperation{Op: "removeTag", Path: "/page/meta/tags", Value: str2json("c")},
+		model.PatchOperation{Op: "removeTag", Path: "/page/meta/tags", Value: str2json("x")},
+	))
+	r.Equal([]string{"b", "d", "e"}, readTags())
+
+	// Tag changes don't create new versions
+	attic, err = s.app.Content.ListAttic("published/page")
+	r.NoError(err)
+	r.Len(attic, revisions)
+
+	// Tag changes are indexed
+	res := s.api("GET", "/tags/d", nil, s.userToken)
+	body, _ := jsonbody[model.GetTagResponse](res)
+	r.Len(body.Items, 1)
+
+	// Invalid tags
+	number := json.RawMessage(`1`)
+	r.Equal(400, patch("published/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: &number}))
+	r.Equal(400, patch("published/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: str2json(" ")}))
+	r.Equal(400, patch("published/page", model.PatchOperation{Op: "removeTag", Path: "/page/meta/tags/0", Value: str2json("b")}))
+
+	// Removing the tags removes all of them
+	r.Equal(200, patch("published/page", model.PatchOperation{Op: "remove", Path: "/page/meta/tags"}))
+	r.Empty(readTags())
+
+	// Folders
+	r.Equal(200, patch("published/folder", model.PatchOperation{Op: "addTag", Path: "/folder/meta/tags", Value: str2json("f")}))
+	folder, err := s.app.Content.ReadFolder("published/folder")
+	r.NoError(err)
+	r.Equal([]string{"f"}, folder.Meta.Tags)
+	r.Equal("Folder", folder.Meta.Title)
+
+	// Tags can't be changed without write permission
+	r.NoError(s.app.Content.SavePage("read-only/page", "Content", model.ContentMeta{}, ""))
+	r.Equal(403, patch("read-only/page", model.PatchOperation{Op: "addTag", Path: "/page/meta/tags", Value: str2json("x")}))
+}
+
+func (s *ContentTestSuite) TestPatchACLOperations() {
+	r := s.Require()
+
+	acl := []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpRead}}}
+	r.NoError(s.app.Content.SavePage("page", "Content", model.ContentMeta{ACL: &acl}, ""))
+
+	res := s.api("GET", "/pages/page", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetContentResponse](res)
+	current := *body.Page.Meta.ACL
+
+	// Add an operation to an existing rule, guarded by the current ACL
+	res = s.api("PATCH", "/pages/page", []model.PatchOperation{
+		{Op: "test", Path: "/page/meta/acl", Value: acl2json(current)},
+		{Op: "add", Path: "/page/meta/acl/0/ops/-", Value: str2json("write")},
+	}, s.adminToken)
+	r.Equal(200, res.Code)
+
+	page, err := s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Equal([]model.AccessOp{model.AccessOpRead, model.AccessOpWrite}, (*page.Meta.ACL)[0].Operations)
+
+	// Outdated ACL is rejected
+	res = s.api("PATCH", "/pages/page", []model.PatchOperation{
+		{Op: "test", Path: "/page/meta/acl", Value: acl2json(current)},
+		{Op: "remove", Path: "/page/meta/acl/0"},
+	}, s.adminToken)
+	r.Equal(409, res.Code)
+
+	page, err = s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Len(*page.Meta.ACL, 1)
+
+	// Invalid operations are validated
+	res = s.api("PATCH", "/pages/page", []model.PatchOperation{
+		{Op: "add", Path: "/page/meta/acl/0/ops/-", Value: str2json("admin")},
+	}, s.adminToken)
+	r.Equal(400, res.Code)
+
+	// Reading the ACL through "test" or "copy" requires admin permission
+	writableACL := []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpRead, model.AccessOpWrite}}}
+	r.NoError(s.app.Content.SavePage("published/page", "Content", model.ContentMeta{ACL: &writableACL}, ""))
+	res = s.api("PATCH", "/pages/published/page", []model.PatchOperation{
+		{Op: "replace", Path: "/page/meta/title", Value: str2json("Title")},
+	}, s.userToken)
+	r.Equal(200, res.Code)
+	res = s.api("PATCH", "/pages/published/page", []model.PatchOperation{
+		{Op: "test", Path: "/page/meta/acl/0/subject", Value: str2json("all")},
+	}, s.userToken)
+	r.Equal(403, res.Code)
+	res = s.api("PATCH", "/pages/published/page", []model.PatchOperation{
+		{Op: "copy", From: strPtr("/page/meta/acl/0/subject"), Path: "/page/meta/title"},
+	}, s.userToken)
+	r.Equal(403, res.Code)
+
+	// Parents of the ACL include it as well
+	metaJSON, err := json.Marshal(model.ContentMeta{ACL: &writableACL})
+	r.NoError(err)
+	metaValue := json.RawMessage(metaJSON)
+	for _, op := range []model.PatchOperation{
+		{Op: "test", Path: "/page/meta", Value: &metaValue},
+		{Op: "test", Path: "/page", Value: &metaValue},
+		{Op: "test", Path: "", Value: &metaValue},
+		{Op: "copy", From: strPtr("/page/meta"), Path: "/page/meta/title"},
+		{Op: "replace", Path: "/folder/meta", Value: &metaValue},
+	} {
+		res = s.api("PATCH", "/pages/published/page", []model.PatchOperation{op}, s.userToken)
+		r.Equal(403, res.Code, op)
+	}
+}
+
+func (s *ContentTestSuite) TestMoveRewritesLinks() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.CreateFolder("published/docs", model.ContentMeta{}))
+	r.NoError(s.app.Content.SavePage("published/docs/target", "[sibling](other) [self](#top)", model.ContentMeta{Title: "Target"}, ""))
+	r.NoError(s.app.Content.SavePage("published/docs/other", "[a](target) [b](/published/docs/target#section)", model.ContentMeta{Title: "Other"}, ""))
+	r.NoError(s.app.Content.SavePage("published/unrelated", "[a](docs/other)", model.ContentMeta{}, ""))
+	r.NoError(s.app.Content.SavePage("read-only/page", "[a](/published/docs/target)", model.ContentMeta{}, ""))
+	r.NoError(s.app.Content.SavePage("admin-only/page", "[a](/published/docs/target)", model.ContentMeta{}, ""))
+
+	readContent := func(url string) string {
+		page, err := s.app.Content.ReadPage(url, nil)
+		r.NoError(err)
+		return page.Content
+	}
+
+	// Move page to other folder
+	res := s.api("PATCH", "/pages/published/docs/target?rewriteLinks=true",
+		[]model.PatchOperation{{Op: "replace", Path: "/page/url", Value: str2json("published/moved")}},
+		s.userToken)
+	r.Equal(200, res.Code)
+	r.Contains(res.Result().Header.Get("Content-Type"), "application/json")
+	body, _ := jsonbody[model.PatchResponse](res)
+	r.ElementsMatch([]string{"published/docs/other", "published/moved"}, body.RewrittenPages)
+	r.Equal([]string{"read-only/page"}, body.SkippedPages)
+
+	r.Equal("[a](../moved) [b](/published/moved#section)", readContent("published/docs/other"))
+	r.Equal("[sibling](docs/other) [self](#top)", readContent("published/moved"))
+	r.Equal("[a](docs/other)", readContent("published/unrelated"))
+	r.Equal("[a](/published/docs/target)", readContent("read-only/page"))
+	r.Equal("[a](/published/docs/target)", readContent("admin-only/page"))
+
+	// Rewrites are saved as new versions by the user who moved the page
+	page, err := s.app.Content.ReadPage("published/docs/other", nil)
+	r.NoError(err)
+	r.Equal(s.userUserID, page.Meta.ModifiedByUserID)
+	attic, err := s.app.Content.ListAttic("published/docs/other")
+	r.NoError(err)
+	latest, err := s.app.Content.ReadPage("published/docs/other", &attic[len(attic)-1].Revision)
+	r.NoError(err)
+	r.Equal(page.Content, latest.Content)
+
+	// Move folder
+	res = s.api("PATCH", "/pages/published/docs?rewriteLinks=true",
+		[]model.PatchOperation{{Op: "replace", Path: "/folder/url", Value: str2json("published/guides")}},
+		s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.PatchResponse](res)
+	r.ElementsMatch([]string{"published/moved", "published/unrelated"}, body.RewrittenPages)
+
+	r.Equal("[a](../moved) [b](/published/moved#section)", readContent("published/guides/other"))
+	r.Equal("[sibling](guides/other) [self](#top)", readContent("published/moved"))
+	r.Equal("[a](guides/other)", readContent("published/unrelated"))
+
+	// Without the option, links are not rewritten
+	res = s.api("PATCH", "/pages/published/unrelated",
+		[]model.PatchOperation{{Op: "replace", Path: "/page/url", Value: str2json("published/renamed")}},
+		s.userToken)
+	r.Equal(200, res.Code)
+	r.Empty(res.Body.String())
+	r.Equal("[a](guides/other)", readContent("published/renamed"))
+}
+
+func (s *ContentTestSuite) TestRedirects() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.CreateFolder("published/docs", model.ContentMeta{}))
+	r.NoError(s.app.Content.SavePage("published/docs/page", "Page", model.ContentMeta{}, ""))
+	r.NoError(s.app.Content.SavePage("published/docs/intro", "Intro", model.ContentMeta{}, ""))
+	r.NoError(s.app.Content.SavePage("published/secret", "Secret", model.ContentMeta{}, ""))
+
+	move := func(url, patchPath, destination string, token *string) {
+		res := s.api("PATCH", "/pages/"+url,
+			[]model.PatchOperation{{Op: "replace", Path: patchPath, Value: str2json(destination)}},
+			token)
+		r.Equal(200, res.Code)
+	}
+	getRedirect := func(url string) string {
+		res := s.api("GET", "/pages/"+url, nil, nil)
+		if res.Code == 404 {
+			return ""
+		}
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		r.Nil(body.Page)
+		r.Nil(body.Folder)
+		return body.Redirect
+	}
+
+	// Moving a page records a redirect
+	move("published/docs/page", "/page/url", "published/page2", s.userToken)
+	r.Equal("published/page2", getRedirect("published/docs/page"))
+
+	// Moving a folder redirects the folder and its descendants
+	move("published/docs", "/folder/url", "published/guides", s.userToken)
+	r.Equal("published/guides", getRedirect("published/docs"))
+	r.Equal("published/guides/intro", getRedirect("published/docs/intro"))
+	r.Equal("", getRedirect("published/docs/missing"))
+
+	// Redirects are updated when the target is moved again
+	move("published/page2", "/page/url", "published/page3", s.userToken)
+	r.Equal("published/page3", getRedirect("published/docs/page"))
+	r.Equal("published/page3", getRedirect("published/page2"))
+
+	// Redirects never shadow new content
+	res := s.api("PUT", "/pages/published/page2",
+		model.PutRequest{Page: &model.Page{Url: "published/page2", Content: "New"}}, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetContentResponse](s.api("GET", "/pages/published/page2", nil, nil))
+	r.NotNil(body.Page)
+	r.Empty(body.Redirect)
+
+	// Redirects aren't followed to content the user can't read
+	move("published/secret", "/page/url", "admin-only/secret", s.adminToken)
+	r.Equal("", getRedirect("published/secret"))
+	body, _ = jsonbody[model.GetContentResponse](s.api("GET", "/pages/published/secret", nil, s.adminToken))
+	r.Equal("admin-only/secret", body.Redirect)
+
+	// Only admins can manage redirects
+	r.Equal(401, s.api("GET", "/redirects", nil, nil).Code)
+	r.Equal(403, s.api("GET", "/redirects", nil, s.userToken).Code)
+	r.Equal(403, s.api("POST", "/redirects/prune", nil, s.userToken).Code)
+	r.Equal(403, s.api("POST", "/redirects/delete", model.DeleteRedirectsRequest{Urls: []string{"published/docs"}}, s.userToken).Code)
+
+	res = s.api("GET", "/redirects", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	list, _ := jsonbody[model.GetRedirectsResponse](res)
+	stale := map[string]bool{}
+	for _, redirect := range list.Redirects {
+		stale[redirect.From] = redirect.Stale
+	}
+	r.Equal(map[string]bool{
+		"published/docs":      false,
+		"published/docs/page": false,
+		"published/page2":     true,
+		"published/secret":    false,
+	}, stale)
+
+	// Prune stale redirects
+	res = s.api("POST", "/redirects/prune", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	pruned, _ := jsonbody[model.PruneRedirectsResponse](res)
+	r.Equal(1, pruned.Removed)
+
+	// Delete redirects
+	res = s.api("POST", "/redirects/delete", model.DeleteRedirectsRequest{Urls: []string{"published/docs"}}, s.adminToken)
+	r.Equal(200, res.Code)
+	r.Equal("", getRedirect("published/docs/intro"))
+	r.Equal("published/page3", getRedirect("published/docs/page"))
+
+	res = s.api("POST", "/redirects/delete", model.DeleteRedirectsRequest{Urls: []string{"published/docs"}}, s.adminToken)
+	r.Equal(404, res.Code)
+
+	list, _ = jsonbody[model.GetRedirectsResponse](s.api("GET", "/redirects", nil, s.adminToken))
+	r.Len(list.Redirects, 2)
+}
+
+func (s *ContentTestSuite) TestChanges() {
+	r := s.Require()
+
+	put := func(url, content, summary string, token *string) {
+		res := s.api("PUT", "/pages/"+url,
+			model.PutRequest{Page: &model.Page{Url: url, Content: content, Meta: model.ContentMeta{Title: "Title of " + url}}, Summary: summary},
+			token)
+		r.Equal(200, res.Code)
+	}
+	getChanges := func(query string, token *string) model.GetChangesResponse {
+		res := s.api("GET", "/changes"+query, nil, token)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetChangesResponse](res)
+		return body
+	}
+	types := func(changes []model.Change) []string {
+		result := []string{}
+		for _, change := range changes {
+			result = append(result, string(change.Type)+" "+change.Url)
+		}
+		return result
+	}
+
+	put("published/page", "Content", "First draft", s.userToken)
+	put("published/page", "Better content", "Improved wording", s.userToken)
+	put("admin-only/secret", "Secret", "", s.adminToken)
+
+	res := s.api("PATCH", "/pages/published/page",
+		[]model.PatchOperation{{Op: "replace", Path: "/page/url", Value: str2json("published/moved")}},
+		s.userToken)
+	r.Equal(200, res.Code)
+
+	r.Equal(200, s.api("DELETE", "/pages/published/moved", nil, s.userToken).Code)
+
+	trash, err := s.app.Content.ListTrash()
+	r.NoError(err)
+	r.Len(trash, 1)
+	res = s.api("POST", "/trash/restore",
+		model.TrashActionRequest{Items: []model.TrashItemRef{{Url: trash[0].Url, DeletedAt: trash[0].DeletedAt}}},
+		s.adminToken)
+	r.Equal(200, res.Code)
+
+	// Changes are listed newest first
+	body := getChanges("", s.userToken)
+	r.Equal([]string{
+		"restore published/moved",
+		"delete published/moved",
+		"move published/moved",
+		"edit published/page",
+		"create published/page",
+	}, types(body.Changes))
+	r.False(body.HasMore)
+
+	restore, del, move, edit, create := body.Changes[0], body.Changes[1], body.Changes[2], body.Changes[3], body.Changes[4]
+	r.Equal("Administrator", restore.ModifiedByDisplayName)
+	r.Equal("user", del.ModifiedByUsername)
+	r.Equal("Title of published/page", del.Title)
+	r.Equal("published/page", move.PreviousUrl)
+	r.Equal("Improved wording", edit.Summary)
+	r.NotZero(edit.Revision)
+	r.Equal("First draft", create.Summary)
+	r.Equal("User", create.ModifiedByDisplayName)
+	r.Less(create.Revision, edit.Revision)
+	r.False(edit.Time.Before(create.Time))
+
+	// Changes of content the caller can't read are hidden
+	r.Equal([]string{
+		"restore published/moved",
+		"delete published/moved",
+		"move published/moved",
+		"edit published/page",
+		"create published/page",
+	}, types(getChanges("", nil).Changes))
+	r.Contains(types(getChanges("", s.adminToken).Changes), "create admin-only/secret")
+
+	// Pagination
+	body = getChanges("?limit=2", s.userToken)
+	r.Equal([]string{"restore published/moved", "delete published/moved"}, types(body.Changes))
+	r.True(body.HasMore)
+	body = getChanges("?limit=2&page=3", s.userToken)
+	r.Equal([]string{"create published/page"}, types(body.Changes))
+	r.False(body.HasMore)
+	r.Equal(3, body.Page)
+	r.Equal(2, body.Limit)
+}
+
+func (s *ContentTestSuite) TestFeeds() {
+	r := s.Require()
+
+	put := func(url, content string, token *string) {
+		res := s.api("PUT", "/pages/"+url,
+			model.PutRequest{Page: &model.Page{Url: url, Content: content, Meta: model.ContentMeta{Title: "Title of " + url}}},
+			token)
+		r.Equal(200, res.Code)
+	}
+	createFeedToken := func(username string, token *string) string {
+		res := s.api("POST", "/auth/users/"+username+"/feed-token", nil, token)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.FeedTokenResponse](res)
+		r.NotEmpty(body.Token)
+		return body.Token
+	}
+
+	put("published/page", "Line one", s.userToken)
+	put("published/page", "Line one\nLine <two>", s.userToken)
+	put("admin-only/secret", "Secret", s.adminToken)
+
+	// Anonymous users get feeds of content readable by anonymous
+	res := s.api("GET", "/feeds/atom/published", nil, nil)
+	r.Equal(200, res.Code)
+	r.Contains(res.Header().Get("Content-Type"), "application/atom+xml")
+	feed := res.Body.String()
+	r.Contains(feed, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	r.Contains(feed, "<title>Edited: Title of published/page</title>")
+	r.Contains(feed, "<title>Created: Title of published/page</title>")
+	r.Contains(feed, "<name>User</name>")
+	// Entries contain a diff snippet
+	r.Contains(feed, "&lt;pre&gt;+ Line &amp;lt;two&amp;gt;&lt;/pre&gt;")
+	r.NotContains(feed, "secret")
+
+	res = s.api("GET", "/feeds/rss/published", nil, nil)
+	r.Equal(200, res.Code)
+	r.Contains(res.Header().Get("Content-Type"), "application/rss+xml")
+	r.Contains(res.Body.String(), "<title>Edited: Title of published/page</title>")
+
+	// Links use the configured public URL instead of the host requested by the client
+	r.Contains(feed, `href="http://example.com/published"`)
+	app := s.app
+	app.BaseURL = "https://wiki.example.com"
+	req := httptest.NewRequest("GET", "/_api/feeds/atom/published", nil)
+	req.Host = "attacker.example.org"
+	req.Header.Set("X-Forwarded-Proto", "http")
+	rec := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(rec, req)
+	r.Equal(200, rec.Code)
+	r.Contains(rec.Body.String(), `href="https://wiki.example.com/published"`)
+	r.NotContains(rec.Body.String(), "attacker")
+
+	r.Equal(401, s.api("GET", "/feeds/atom/admin-only", nil, nil).Code)
+	r.Equal(404, s.api("GET", "/feeds/atom/published/page", nil, nil).Code)
+	r.Equal(404, s.api("GET", "/feeds/json/published", nil, nil).Code)
+
+	// Feed tokens give access to private content
+	r.Equal(403, s.api("POST", "/auth/users/"+TestAdminUsername+"/feed-token", nil, s.userToken).Code)
+	r.Equal(401, s.api("POST", "/auth/users/"+TestUserUsername+"/feed-token", nil, nil).Code)
+	userFeedToken := createFeedToken(TestUserUsername, s.userToken)
+	adminFeedToken := createFeedToken(TestAdminUsername, s.adminToken)
+
+	res = s.api("GET", "/feeds/atom/read-only?token="+userFeedToken, nil, nil)
+	r.Equal(200, res.Code)
+	r.Equal(401, s.api("GET", "/feeds/atom/read-only", nil, nil).Code)
+
+	res = s.api("GET", "/feeds/atom/?token="+userFeedToken, nil, nil)
+	r.Equal(200, res.Code)
+	r.Contains(res.Body.String(), "Title of published/page")
+	r.NotContains(res.Body.String(), "secret")
+
+	res = s.api("GET", "/feeds/atom/?token="+adminFeedToken, nil, nil)
+	r.Equal(200, res.Code)
+	r.Contains(res.Body.String(), "<title>Created: Title of admin-only/secret</title>")
+
+	r.Equal(401, s.api("GET", "/feeds/atom/published?token=invalid", nil, nil).Code)
+
+	// Feed tokens can be revoked
+	r.Equal(200, s.api("POST", "/auth/users/"+TestUserUsername+"/feed-token/delete", nil, s.userToken).Code)
+	r.Equal(401, s.api("GET", "/feeds/atom/published?token="+userFeedToken, nil, nil).Code)
+
+	r.Equal(200, s.api("POST", "/auth/users/"+TestAdminUsername+"/feed-token/delete", nil, s.adminToken).Code)
+	r.Equal(401, s.api("GET", "/feeds/atom?token="+adminFeedToken, nil, nil).Code)
+
+	// Admins can revoke the feed tokens of other users, but not create them
+	r.Equal(403, s.api("POST", "/auth/users/"+TestUserUsername+"/feed-token", nil, s.adminToken).Code)
+	r.Equal(404, s.api("POST", "/auth/users/unknown/feed-token/delete", nil, s.adminToken).Code)
+	userFeedToken = createFeedToken(TestUserUsername, s.userToken)
+	r.Equal(200, s.api("POST", "/auth/users/"+TestUserUsername+"/feed-token/delete", nil, s.adminToken).Code)
+	r.Equal(401, s.api("GET", "/feeds/atom/published?token="+userFeedToken, nil, nil).Code)
+}
+
+func (s *ContentTestSuite) TestNotifications() {
+	r := s.Require()
+
+	sender := &fakeMailSender{}
+	s.app.Notifications.SetSender(sender)
+
+	setEmail := func(username, email string, token *string) int {
+		return s.api("PATCH", "/auth/users/"+username,
+			[]model.PatchOperation{{Op: "replace", Path: "/email", Value: str2json(email)}},
+			token).Code
+	}
+	watch := func(url string, mode model.NotificationMode, token *string) int {
+		return s.api("PUT", "/subscriptions/"+url, model.PutSubscriptionRequest{Mode: mode}, token).Code
+	}
+	put := func(url, content string, token *string) {
+		res := s.api("PUT", "/pages/"+url,
+			model.PutRequest{Page: &model.Page{Url: url, Content: content, Meta: model.ContentMeta{Title: "Title of " + url}}},
+			token)
+		r.Equal(200, res.Code)
+	}
+	sent := func() []mail.Message {
+		s.app.Notifications.Wait()
+		return sender.take()
+	}
+
+	defer func() {
+		s.app.Notifications.SetSender(nil)
+		r.Equal(200, setEmail(TestUserUsername, "", s.userToken))
+		r.Equal(200, setEmail(TestAdminUsername, "", s.adminToken))
+		for _, url := range []string{"published", "read-only"} {
+			_ = s.app.Users.Unsubscribe(s.userUserID, url)
+		}
+		_ = s.app.Users.Unsubscribe(s.adminUserID, "")
+	}()
+
+	r.Equal(400, setEmail(TestUserUsername, "not an address", s.userToken))
+	r.Equal(400, setEmail(TestUserUsername, "User <user@example.com>", s.userToken))
+	r.Equal(200, setEmail(TestUserUsername, "user@example.com", s.userToken))
+	r.Equal(200, setEmail(TestAdminUsername, "admin@example.com", s.adminToken))
+
+	// Users can only watch existing content they can read
+	r.Equal(401, watch("published", model.NotifyImmediately, nil))
+	r.Equal(400, watch("published", "weekly", s.userToken))
+	r.Equal(403, watch("admin-only", model.NotifyImmediately, s.userToken))
+	r.Equal(404, watch("published/nonexistent", model.NotifyImmediately, s.userToken))
+	r.Equal(200, watch("published", model.NotifyImmediately, s.userToken))
+	r.Equal(200, watch("read-only", model.NotifyDigest, s.userToken))
+	r.Equal(200, watch("", model.NotifyDigest, s.adminToken))
+
+	res := s.api("GET", "/subscriptions", nil, s.userToken)
+	r.Equal(200, res.Code)
+	subscriptions, _ := jsonbody[model.GetSubscriptionsResponse](res)
+	r.True(subscriptions.NotificationsEnabled)
+	r.Equal([]model.Subscription{
+		{Url: "published", Mode: model.NotifyImmediately},
+		{Url: "read-only", Mode: model.NotifyDigest},
+	}, subscriptions.Subscriptions)
+
+	res = s.api("GET", "/pages/published", nil, s.userToken)
+	r.Equal(200, res.Code)
+	content, _ := jsonbody[model.GetContentResponse](res)
+	r.Equal(model.NotifyImmediately, content.Watching)
+
+	// Subscribers are notified immediately, but not about their own changes
+	put("published/page", "Line one", s.adminToken)
+	messages := sent()
+	r.Len(messages, 1)
+	r.Equal([]string{"user@example.com"}, messages[0].To)
+	r.Equal("[PlainPage] Created: Title of published/page", messages[0].Subject)
+	r.Contains(messages[0].Body, "By Administrator")
+	r.Contains(messages[0].Body, "+ Line one")
+	r.Contains(messages[0].Body, "because you watch published")
+
+	put("published/page", "Line one\nLine two", s.userToken)
+	put("admin-only/secret", "Secret", s.adminToken)
+	r.Empty(sent())
+
+	// Digests contain all changes since the last digest
+	r.NoError(s.app.Notifications.SetLastDigest(time.Now().Add(-time.Hour)))
+	put("read-only/page", "Read only", s.adminToken)
+
+	// Digests that can't be delivered are sent again, without sending the others twice
+	sender.fail("user@example.com", errors.New("mailbox unavailable"))
+	now := time.Now()
+	r.Error(s.app.SendDigests(now))
+	messages = sent()
+	r.Len(messages, 1)
+	r.Equal([]string{"admin@example.com"}, messages[0].To)
+	r.Equal("[PlainPage] Daily digest: 1 change", messages[0].Subject)
+	r.Contains(messages[0].Body, "Edited: Title of published/page")
+	r.Contains(messages[0].Body, "+ Line two")
+	r.Contains(messages[0].Body, "because you watch the whole wiki")
+	since, err := s.app.Notifications.LastDigest()
+	r.NoError(err)
+	r.True(now.Equal(since))
+	sender.fail("user@example.com", nil)
+
+	r.NoError(s.app.SendDigests(time.Now()))
+	messages = sent()
+	r.Len(messages, 1)
+	r.Equal([]string{"user@example.com"}, messages[0].To)
+	r.Contains(messages[0].Body, "Created: Title of read-only/page")
+
+	r.NoError(s.app.SendDigests(time.Now()))
+	r.Empty(sent())
+
+	// Permissions are checked at send time
+	put("read-only/page", "Read only, edited", s.adminToken)
+	res = s.api("PATCH", "/pages/read-only",
+		[]model.PatchOperation{{Op: "replace", Path: "/folder/meta/acl", Value: acl2json([]model.AccessRule{})}},
+		s.adminToken)
+	r.Equal(200, res.Code)
+	r.NoError(s.app.SendDigests(time.Now()))
+	r.Empty(sent())
+
+	// Users can stop watching content they can't read anymore
+	r.Equal(200, s.api("DELETE", "/subscriptions/read-only", nil, s.userToken).Code)
+	r.Equal(404, s.api("DELETE", "/subscriptions/read-only", nil, s.userToken).Code)
+
+	// Users without email address aren't notified
+	r.Equal(200, setEmail(TestUserUsername, "", s.userToken))
+	put("published/page", "Line three", s.adminToken)
+	r.Empty(sent())
+}
+
+func (s *ContentTestSuite) TestGroups() {
+	r := s.Require()
+
+	// Only admins can manage groups
+	{
+		res := s.api("GET", "/auth/groups", nil, s.userToken)
+		r.Equal(403, res.Code)
+		res = s.api("GET", "/auth/groups", nil, nil)
+		r.Equal(401, res.Code)
+		res = s.api("POST", "/auth/groups", model.PostGroupRequest{Name: "Editors"}, s.userToken)
+		r.Equal(403, res.Code)
+	}
+
+	res := s.api("POST", "/auth/groups", model.PostGroupRequest{Name: "Editors", Members: []string{s.userUserID}}, s.adminToken)
+	r.Equal(200, res.Code)
+	group, _ := jsonbody[model.Group](res)
+	r.NotEmpty(group.ID)
+	r.Equal("Editors", group.Name)
+	r.Equal([]string{s.userUserID}, group.Members)
+
+	defer func() {
+		groups, err := s.app.Users.ReadAllGroups()
+		r.NoError(err)
+		for _, g := range groups {
+			r.NoError(s.app.Users.DeleteGroup(g.ID))
+		}
+	}()
+
+	// Validation
+	{
+		res := s.api("POST", "/auth/groups", model.PostGroupRequest{Name: "editors"}, s.adminToken)
+		r.Equal(409, res.Code)
+		res = s.api("POST", "/auth/groups", model.PostGroupRequest{Name: ""}, s.adminToken)
+		r.Equal(400, res.Code)
+		res = s.api("POST", "/auth/groups", model.PostGroupRequest{Name: "Reviewers", Members: []string{"unknown"}}, s.adminToken)
+		r.Equal(400, res.Code)
+	}
+
+	// List and get
+	{
+		res := s.api("GET", "/auth/groups", nil, s.adminToken)
+		r.Equal(200, res.Code)
+		groups, _ := jsonbody[[]model.Group](res)
+		r.Equal([]model.Group{group}, groups)
+
+		res = s.api("GET", "/auth/groups/"+group.ID, nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.Group](res)
+		r.Equal(group, body)
+
+		res = s.api("GET", "/auth/groups/unknown", nil, s.adminToken)
+		r.Equal(404, res.Code)
+	}
+
+	// Group members get the permissions granted to the group
+	{
+		res := s.api("GET", "/pages/admin-only", nil, s.userToken)
+		r.Equal(403, res.Code)
+
+		acl := []model.AccessRule{{Subject: "group:" + group.ID, Operations: []model.AccessOp{model.AccessOpRead}}}
+		res = s.api("PATCH", "/pages/admin-only",
+			[]model.PatchOperation{{Op: "replace", Path: "/folder/meta/acl", Value: acl2json(acl)}},
+			s.adminToken)
+		r.Equal(200, res.Code)
+
+		res = s.api("GET", "/pages/admin-only", nil, s.userToken)
+		r.Equal(200, res.Code)
+
+		// Admins see the group in the ACL
+		res = s.api("GET", "/pages/admin-only", nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		r.NotNil(body.Folder.Meta.ACL)
+		r.Equal(&group, (*body.Folder.Meta.ACL)[0].Group)
+
+		res = s.api("PUT", "/pages/admin-only/page", model.PutRequest{Page: &model.Page{Meta: model.ContentMeta{Title: "Page"}}}, s.userToken)
+		r.Equal(403, res.Code)
+	}
+
+	// Groups can be granted admin privileges in the global ACL
+	{
+		cfg, err := s.app.Config.Read()
+		r.NoError(err)
+		defaultAcl := cfg.ACL
+
+		s.saveGlobalAcl(s.adminToken, append(slices.Clone(defaultAcl),
+			model.AccessRule{Subject: "group:" + group.ID, Operations: []model.AccessOp{model.AccessOpAdmin}}))
+
+		res := s.api("GET", "/auth/groups", nil, s.userToken)
+		r.Equal(200, res.Code)
+
+		s.saveGlobalAcl(s.adminToken, defaultAcl)
+	}
+
+	// Removing the user from the group revokes the permissions
+	{
+		res := s.api("PATCH", "/auth/groups/"+group.ID,
+			[]model.PatchOperation{{Op: "replace", Path: "/members", Value: strs2json([]string{})}},
+			s.adminToken)
+		r.Equal(200, res.Code)
+
+		res = s.api("GET", "/pages/admin-only", nil, s.userToken)
+		r.Equal(403, res.Code)
+	}
+
+	// Patch validation
+	{
+		res := s.api("PATCH", "/auth/groups/"+group.ID,
+			[]model.PatchOperation{{Op: "replace", Path: "/id", Value: str2json("other")}},
+			s.adminToken)
+		r.Equal(400, res.Code)
+
+		res = s.api("PATCH", "/auth/groups/"+group.ID,
+			[]model.PatchOperation{{Op: "replace", Path: "/members", Value: strs2json([]string{"unknown"})}},
+			s.adminToken)
+		r.Equal(400, res.Code)
+
+		res = s.api("PATCH", "/auth/groups/unknown",
+			[]model.PatchOperation{{Op: "replace", Path: "/name", Value: str2json("Other")}},
+			s.adminToken)
+		r.Equal(404, res.Code)
+	}
+
+	// Delete
+	{
+		res := s.api("DELETE", "/auth/groups/"+group.ID, nil, s.userToken)
+		r.Equal(403, res.Code)
+
+		res = s.api("DELETE", "/auth/groups/"+group.ID, nil, s.adminToken)
+		r.Equal(200, res.Code)
+
+		res = s.api("DELETE", "/auth/groups/"+group.ID, nil, s.adminToken)
+		r.Equal(404, res.Code)
+	}
+}
+
+func (s *ContentTestSuite) TestContentIDs() {
+	r := s.Require()
+
+	getMeta := func(url string) model.ContentMeta {
+		res := s.api("GET", "/pages/"+url, nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		if body.Folder != nil {
+			return body.Folder.Meta
+		}
+		return body.Page.Meta
+	}
+	resolve := func(id string, token *string) (int, model.ResolveIDResponse) {
+		res := s.api("GET", "/id/"+id, nil, token)
+		if res.Code != 200 {
+			return res.Code, model.ResolveIDResponse{}
+		}
+		body, _ := jsonbody[model.ResolveIDResponse](res)
+		return res.Code, body
+	}
+
+	// New pages and folders get an ID
+	res := s.api("PUT", "/pages/published/folder", model.PutRequest{Folder: &model.Folder{}}, s.userToken)
+	r.Equal(200, res.Code)
+	res = s.api("PUT", "/pages/published/folder/page",
+		model.PutRequest{Page: &model.Page{Url: "published/folder/page", Content: "Content"}}, s.userToken)
+	r.Equal(200, res.Code)
+
+	folderID := getMeta("published/folder").ID
+	pageID := getMeta("published/folder/page").ID
+	r.Len(folderID, 12)
+	r.Len(pageID, 12)
+	r.NotEqual(folderID, pageID)
+
+	// IDs are kept when saving and can't be changed by clients
+	res = s.api("PUT", "/pages/published/folder/page",
+		model.PutRequest{Page: &model.Page{Url: "published/folder/page", Content: "Changed", Meta: model.ContentMeta{ID: "other"}}}, s.userToken)
+	r.Equal(200, res.Code)
+	res = s.api("PATCH", "/pages/published/folder",
+		[]model.PatchOperation{{Op: "replace", Path: "/folder/meta/title", Value: str2json("Folder")}}, s.userToken)
+	r.Equal(200, res.Code)
+	r.Equal(pageID, getMeta("published/folder/page").ID)
+	r.Equal(folderID, getMeta("published/folder").ID)
+
+	code, item := resolve(pageID, nil)
+	r.Equal(200, code)
+	r.Equal(model.ResolveIDResponse{Url: "published/folder/page", IsFolder: false}, item)
+
+	// IDs survive moves
+	res = s.api("PATCH", "/pages/published/folder",
+		[]model.PatchOperation{{Op: "replace", Path: "/folder/url", Value: str2json("published/moved")}}, s.userToken)
+	r.Equal(200, res.Code)
+
+	code, item = resolve(folderID, nil)
+	r.Equal(200, code)
+	r.Equal(model.ResolveIDResponse{Url: "published/moved", IsFolder: true}, item)
+	code, item = resolve(pageID, nil)
+	r.Equal(200, code)
+	r.Equal("published/moved/page", item.Url)
+
+	// Existing content without ID gets an ID assigned when it's retrieved
+	r.NoError(s.app.Storage.WriteFile("pages/published/legacy.md", []byte("---\ntitle: Legacy\n---\nContent")))
+	res = s.api("GET", "/pages/published/legacy", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetContentResponse](res)
+	meta := body.Page.Meta
+	r.Len(meta.ID, 12)
+	r.Equal("Legacy", meta.Title)
+	r.True(meta.ModifiedAt.IsZero())
+	r.Equal(meta.ID, getMeta("published/legacy").ID)
+	attic, err := s.app.Content.ListAttic("published/legacy")
+	r.NoError(err)
+	r.Empty(attic)
+
+	// The returned ETag includes the ID
+	etag, err := s.app.Content.ETag("published/legacy")
+	r.NoError(err)
+	r.Equal(etag, res.Header().Get("ETag"))
+
+	code, item = resolve(meta.ID, nil)
+	r.Equal(200, code)
+	r.Equal("published/legacy", item.Url)
+
+	// Or when it's saved
+	r.NoError(s.app.Storage.WriteFile("pages/published/legacy2.md", []byte("---\ntitle: Legacy\n---\nContent")))
+	res = s.api("PUT", "/pages/published/legacy2",
+		model.PutRequest{Page: &model.Page{Content: "Changed", Meta: model.ContentMeta{Title: "Legacy"}}}, s.userToken)
+	r.Equal(200, res.Code)
+	saved, err := s.app.Content.ReadPage("published/legacy2", nil)
+	r.NoError(err)
+	r.Len(saved.Meta.ID, 12)
+
+	// Resolving requires read permission
+	r.NoError(s.app.Content.SavePage("admin-only/page", "Secret", model.ContentMeta{}, ""))
+	secretID := getMeta("admin-only/page").ID
+	code, _ = resolve(secretID, nil)
+	r.Equal(401, code)
+	code, _ = resolve(secretID, s.userToken)
+	r.Equal(403, code)
+	code, item = resolve(secretID, s.adminToken)
+	r.Equal(200, code)
+	r.Equal("admin-only/page", item.Url)
+
+	// Unknown and deleted content
+	code, _ = resolve("unknown", s.adminToken)
+	r.Equal(404, code)
+	r.NoError(s.app.Content.DeletePage("published/moved/page"))
+	code, _ = resolve(pageID, s.adminToken)
+	r.Equal(404, code)
+}
+
+func (s *ContentTestSuite) TestRevertPage() {
+	r := s.Require()
+
+	t1 := time.Now().Add(-2 * time.Hour)
+	t2 := time.Now().Add(-time.Hour)
+	r.NoError(s.app.Content.SavePageAt("published/page", "One", model.ContentMeta{Title: "First"}, "", t1))
+	r.NoError(s.app.Content.SavePageAt("published/page", "Two", model.ContentMeta{Title: "Second", Tags: []string{"tag"}}, "", t2))
+	rev1 := t1.UnixMilli()
+
+	// Revert restores content and title, other metadata is kept
+	res := s.api("POST", "/pages/published/page/revert", model.RevertRequest{Revision: rev1}, s.userToken)
+	r.Equal(200, res.Code)
+	r.NotEmpty(res.Header().Get("ETag"))
+	body, _ := jsonbody[model.RevertResponse](res)
+	r.Equal("One", body.Page.Content)
+	r.Equal("First", body.Page.Meta.Title)
+	r.Equal([]string{"tag"}, body.Page.Meta.Tags)
+	r.Equal(&rev1, body.Page.Meta.RevertedTo)
+	r.Equal("user", body.Page.Meta.ModifiedByUsername)
+
+	// A new version records the reverted revision
+	attic, err := s.app.Content.ListAttic("published/page")
+	r.NoError(err)
+	r.Len(attic, 3)
+	latest, err := s.app.Content.ReadPage("published/page", &attic[2].Revision)
+	r.NoError(err)
+	r.Equal("One", latest.Content)
+	r.Equal(&rev1, latest.Meta.RevertedTo)
+
+	// Saving the page again clears the reference
+	res = s.api("PUT", "/pages/published/page",
+		model.PutRequest{Page: &model.Page{Url: "published/page", Content: "Three", Meta: body.Page.Meta}}, s.userToken)
+	r.Equal(200, res.Code)
+	page, err := s.app.Content.ReadPage("published/page", nil)
+	r.NoError(err)
+	r.Nil(page.Meta.RevertedTo)
+
+	// Preconditions are checked
+	res = s.apiWithHeaders("POST", "/pages/published/page/revert", model.RevertRequest{Revision: rev1},
+		map[string]string{"Authorization": "Bearer " + *s.userToken, "If-Match": `"outdated"`})
+	r.Equal(412, res.Code)
+
+	// Errors
+	r.Equal(404, s.api("POST", "/pages/published/page/revert", model.RevertRequest{Revision: 1}, s.userToken).Code)
+	r.Equal(404, s.api("POST", "/pages/published/missing/revert", model.RevertRequest{Revision: rev1}, s.userToken).Code)
+	r.Equal(404, s.api("POST", "/pages/published/page/unknown", model.RevertRequest{Revision: rev1}, s.userToken).Code)
+	r.Equal(400, s.api("POST", "/pages/published/page/revert", "invalid", s.userToken).Code)
+
+	// Write permission is required
+	r.NoError(s.app.Content.SavePageAt("read-only/page", "One", model.ContentMeta{}, "", t1))
+	r.Equal(401, s.api("POST", "/pages/published/page/revert", model.RevertRequest{Revision: rev1}, nil).Code)
+	r.Equal(403, s.api("POST", "/pages/read-only/page/revert", model.RevertRequest{Revision: rev1}, s.userToken).Code)
+}
+
+func (s *ContentTestSuite) TestAtticDiff() {
+	r := s.Require()
+
+	t1 := time.Now().Add(-2 * time.Hour)
+	t2 := time.Now().Add(-time.Hour)
+	acl := []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead, model.AccessOpWrite}}}
+	r.NoError(s.app.Content.SavePageAt("published/page", "Hello world\nBye", model.ContentMeta{Title: "First", Tags: []string{"a"}}, "", t1))
+	r.NoError(s.app.Content.SavePageAt("published/page", "Hello there\nBye", model.ContentMeta{Title: "Second", Tags: []string{"b"}, ACL: &acl}, "", t2))
+	r.NoError(s.app.Content.SavePageWithoutVersion("published/page", "Hello there\nBye\nNew", model.ContentMeta{Title: "Second", Tags: []string{"b"}, ACL: &acl}, "", nil))
+	rev1, rev2 := t1.UnixMilli(), t2.UnixMilli()
+
+	getDiff := func(query string, token *string) model.RevisionDiff {
+		res := s.api("GET", "/attic/published/page/diff?"+query, nil, token)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.RevisionDiff](res)
+		return body
+	}
+
+	// Between two revisions
+	body := getDiff(fmt.Sprintf("from=%d&to=%d", rev1, rev2), s.adminToken)
+	r.Equal(rev1, body.From)
+	r.Equal(&rev2, body.To)
+	r.Equal([]model.DiffLine{
+		{Type: model.DiffDelete, Text: "Hello world", OldLine: 1, Words: []model.DiffSegment{
+			{Type: model.DiffEqual, Text: "Hello "}, {Type: model.DiffDelete, Text: "world"},
+		}},
+		{Type: model.DiffInsert, Text: "Hello there", NewLine: 1, Words: []model.DiffSegment{
+			{Type: model.DiffEqual, Text: "Hello "}, {Type: model.DiffInsert, Text: "there"},
+		}},
+		{Type: model.DiffEqual, Text: "Bye", OldLine: 2, NewLine: 2},
+	}, body.Lines)
+	r.Equal(&model.TitleChange{Old: "First", New: "Second"}, body.Meta.Title)
+	r.Equal([]string{"b"}, body.Meta.AddedTags)
+	r.Equal([]string{"a"}, body.Meta.RemovedTags)
+	r.NotNil(body.Meta.ACL)
+	r.Nil(body.Meta.ACL.Old)
+	r.Len(*body.Meta.ACL.New, 1)
+
+	// ACL changes are hidden from non-admins
+	body = getDiff(fmt.Sprintf("from=%d&to=%d", rev1, rev2), s.userToken)
+	r.Nil(body.Meta.ACL)
+	r.NotNil(body.Meta.Title)
+
+	// Against the current version
+	body = getDiff(fmt.Sprintf("from=%d", rev2), s.userToken)
+	r.Nil(body.To)
+	r.Equal(model.DiffLine{Type: model.DiffInsert, Text: "New", NewLine: 3}, body.Lines[len(body.Lines)-1])
+	r.Equal(model.MetaDiff{}, body.Meta)
+
+	// Errors
+	r.Equal(404, s.api("GET", "/attic/published/page/diff?from=1", nil, s.userToken).Code)
+	r.Equal(404, s.api("GET", fmt.Sprintf("/attic/published/page/diff?from=%d&to=1", rev1), nil, s.userToken).Code)
+	r.Equal(404, s.api("GET", fmt.Sprintf("/attic/published/missing/diff?from=%d", rev1), nil, s.userToken).Code)
+	r.Equal(400, s.api("GET", "/attic/published/page/diff?from=x", nil, s.userToken).Code)
+	r.Equal(400, s.api("GET", fmt.Sprintf("/attic/published/page/diff?from=%d&to=x", rev1), nil, s.userToken).Code)
+
+	// Read permission is required
+	r.NoError(s.app.Content.SavePageAt("admin-only/page", "Secret", model.ContentMeta{}, "", t1))
+	r.Equal(403, s.api("GET", fmt.Sprintf("/attic/admin-only/page/diff?from=%d", rev1), nil, s.userToken).Code)
+
+	// Pages named "diff" are still accessible
+	r.NoError(s.app.Content.SavePageAt("published/diff", "Page", model.ContentMeta{}, "", t1))
+	res := s.api("GET", "/attic/published/diff", nil, nil)
+	r.Equal(200, res.Code)
+	list, _ := jsonbody[model.GetAtticListResponse](res)
+	r.Len(list.Entries, 1)
+}
+
+// TestConcurrentEditsPrevention tests optimistic concurrency control with ETag and If-Match headers
+func (s *ContentTestSuite) TestConcurrentEditsPrevention() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("page", "Original content", model.ContentMeta{Title: "Title"}, ""))
+
+	withIfMatch := func(etag string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + *s.userToken, "If-Match": etag}
+	}
+
+	// GET returns an ETag
+	res := s.api("GET", "/pages/page", nil, s.userToken)
+	r.Equal(200, res.Code)
+	etag := res.Header().Get("ETag")
+	r.NotEmpty(etag)
+
+	// ETag is stable as long as the page doesn't change
+	res = s.api("GET", "/pages/page", nil, s.userToken)
+	r.Equal(etag, res.Header().Get("ETag"))
+
+	// First save with matching ETag succeeds
+	res = s.apiWithHeaders("PUT", "/pages/page",
+		model.PutRequest{Page: &model.Page{Content: "First edit", Meta: model.ContentMeta{Title: "Title"}}},
+		withIfMatch(etag))
+	r.Equal(200, res.Code)
+
+	// Second save based on the same (now outdated) version is rejected
+	res = s.apiWithHeaders("PUT", "/pages/page",
+		model.PutRequest{Page: &model.Page{Content: "Second edit", Meta: model.ContentMeta{Title: "Title"}}},
+		withIfMatch(etag))
+	r.Equal(412, res.Code)
+
+	body, _ := jsonbody[model.ConflictResponse](res)
+	r.NotNil(body.Page)
+	r.Equal("First edit", body.Page.Content)
+	r.Nil(body.Page.Meta.ACL)
+	r.NotEqual(etag, body.ETag)
+	r.Equal(body.ETag, res.Header().Get("ETag"))
+
+	page, err := s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Equal("First edit", page.Content)
+
+	// PATCH with outdated ETag is rejected as well
+	res = s.apiWithHeaders("PATCH", "/pages/page",
+		[]model.PatchOperation{{Op: "replace", Path: "/page/meta/title", Value: str2json("New Title")}},
+		withIfMatch(etag))
+	r.Equal(412, res.Code)
+
+	// Moving with outdated ETag is rejected before anything is moved
+	res = s.apiWithHeaders("PATCH", "/pages/page",
+		[]model.PatchOperation{
+			{Op: "replace", Path: "/page/url", Value: str2json("moved-page")},
+			{Op: "replace", Path: "/page/meta/title", Value: str2json("New Title")},
+		},
+		withIfMatch(etag))
+	r.Equal(412, res.Code)
+	r.True(s.app.Content.IsPage("page"))
+	r.False(s.app.Content.IsPage("moved-page"))
+	redirects, err := s.app.Redirects.ReadAll()
+	r.NoError(err)
+	r.Empty(redirects)
+
+	// PATCH with current ETag succeeds
+	res = s.apiWithHeaders("PATCH", "/pages/page",
+		[]model.PatchOperation{{Op: "replace", Path: "/page/meta/title", Value: str2json("New Title")}},
+		withIfMatch(body.ETag))
+	r.Equal(200, res.Code)
+
+	// Requests without precondition headers keep working
+	res = s.api("PUT", "/pages/page",
+		model.PutRequest{Page: &model.Page{Content: "Unconditional edit", Meta: model.ContentMeta{Title: "Title"}}},
+		s.userToken)
+	r.Equal(200, res.Code)
+
+	// If-Match: * only succeeds for existing content
+	res = s.apiWithHeaders("PUT", "/pages/page",
+		model.PutRequest{Page: &model.Page{Content: "Wildcard edit"}},
+		withIfMatch("*"))
+	r.Equal(200, res.Code)
+	res = s.apiWithHeaders("PUT", "/pages/nonexistent",
+		model.PutRequest{Page: &model.Page{Content: "Wildcard edit"}},
+		withIfMatch("*"))
+	r.Equal(412, res.Code)
+	r.False(s.app.Content.IsPage("nonexistent"))
+
+	// If-None-Match: * prevents overwriting existing content
+	res = s.apiWithHeaders("PUT", "/pages/page",
+		model.PutRequest{Page: &model.Page{Content: "Create only"}},
+		map[string]string{"Authorization": "Bearer " + *s.userToken, "If-None-Match": "*"})
+	r.Equal(412, res.Code)
+
+	page, err = s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Equal("Wildcard edit", page.Content)
+
+	// Folders have an ETag, too
+	res = s.api("GET", "/pages/published", nil, s.userToken)
+	r.Equal(200, res.Code)
+	r.NotEmpty(res.Header().Get("ETag"))
+
+	// Cleanup
+	r.NoError(s.app.Content.DeletePage("page"))
+}
+
+func (s *ContentTestSuite) TestMergeConcurrentEdits() {
+	r := s.Require()
+
+	baseTime := time.Now().Add(-time.Hour)
+	r.NoError(s.app.Content.SavePageAt("page", "line 1\nline 2\nline 3", model.ContentMeta{Title: "Title", Tags: []string{"a"}}, "", baseTime))
+	baseRev := baseTime.UnixMilli()
+
+	// Someone else changes the page in the meantime
+	r.NoError(s.app.Content.SavePageAt("page", "line 1 changed\nline 2\nline 3", model.ContentMeta{Title: "Title", Tags: []string{"a", "b"}}, "", baseTime.Add(time.Minute)))
+
+	// Non-overlapping changes are merged
+	res := s.api("PUT", "/pages/page", model.PutRequest{
+		Page:         &model.Page{Content: "line 1\nline 2\nline 3 edited", Meta: model.ContentMeta{Title: "New Title", Tags: []string{"c"}}},
+		BaseRevision: &baseRev,
+	}, s.userToken)
+	r.Equal(200, res.Code)
+	r.Contains(res.Result().Header.Get("Content-Type"), "application/json")
+
+	body, _ := jsonbody[model.PutResponse](res)
+	r.True(body.Merged)
+	r.Equal("line 1 changed\nline 2\nline 3 edited", body.Page.Content)
+	r.Equal("New Title", body.Page.Meta.Title)
+	r.Equal([]string{"b", "c"}, body.Page.Meta.Tags)
+
+	page, err := s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Equal("line 1 changed\nline 2\nline 3 edited", page.Content)
+
+	// Overlapping changes result in a conflict
+	res = s.api("PUT", "/pages/page", model.PutRequest{
+		Page:         &model.Page{Content: "line 1 mine\nline 2\nline 3", Meta: model.ContentMeta{Title: "Title"}},
+		BaseRevision: &baseRev,
+	}, s.userToken)
+	r.Equal(409, res.Code)
+
+	conflict, _ := jsonbody[model.ConflictResponse](res)
+	r.Equal("line 1 changed\nline 2\nline 3 edited", conflict.Page.Content)
+	r.Equal(res.Header().Get("ETag"), conflict.ETag)
+	r.Equal([]model.MergeConflict{{
+		Line:   1,
+		Base:   []string{"line 1"},
+		Client: []string{"line 1 mine"},
+		Server: []string{"line 1 changed"},
+	}}, conflict.Conflicts)
+
+	page, err = s.app.Content.ReadPage("page", nil)
+	r.NoError(err)
+	r.Equal("line 1 changed\nline 2\nline 3 edited", page.Content)
+
+	// Saving based on the current version doesn't merge
+	res = s.api("GET", "/pages/page", nil, s.userToken)
+	etag := res.Header().Get("ETag")
+	entries, err := s.app.Content.ListAttic("page")
+	r.NoError(err)
+	latestRev := entries[len(entries)-1].Revision
+
+	res = s.apiWithHeaders("PUT", "/pages/page", model.PutRequest{
+		Page:         &model.Page{Content: "line 1 mine", Meta: model.ContentMeta{Title: "Title"}},
+		BaseRevision: &latestRev,
+	}, map[string]string{"Authorization": "Bearer " + *s.userToken, "If-Match": etag})
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.PutResponse](res)
+	r.False(body.Merged)
+	r.Equal("line 1 mine", body.Page.Content)
+
+	// Unknown base revision
+	unknownRev := int64(1)
+	res = s.api("PUT", "/pages/page", model.PutRequest{
+		Page:         &model.Page{Content: "content"},
+		BaseRevision: &unknownRev,
+	}, s.userToken)
+	r.Equal(400, res.Code)
+
+	// Cleanup
+	r.NoError(s.app.Content.DeletePage("page"))
+}
+
 func (s *ContentTestSuite) TestAtticRevisions() {
 	r := s.Require()
 
@@ -1239,6 +2420,79 @@ func (s *ContentTestSuite) TestAtticRevisions() {
 	}
 }
 
+func (s *ContentTestSuite) TestAtticRevisionDetails() {
+	r := s.Require()
+
+	// Version saved before details were recorded in the sidecar file
+	t1 := time.Now().Add(-time.Hour)
+	err := s.app.Content.SavePageAt("published/page", "Old content", model.ContentMeta{Title: "Title"}, s.adminUserID, t1)
+	r.NoError(err)
+	r.NoError(s.app.Storage.DeleteFile("attic/published/page.revisions.yml"))
+
+	// Save with summary via API
+	res := s.api("PUT", "/pages/published/page",
+		model.PutRequest{
+			Page:    &model.Page{Content: "New and longer content", Meta: model.ContentMeta{Title: "Title"}},
+			Summary: "Extended content",
+			Minor:   true,
+		},
+		s.userToken)
+	r.Equal(200, res.Code)
+
+	res = s.api("GET", "/attic/published/page", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetAtticListResponse](res)
+	r.Len(body.Entries, 2)
+
+	legacy, latest := body.Entries[0], body.Entries[1]
+	r.Equal(t1.UnixMilli(), legacy.Revision)
+	r.Equal("admin", legacy.ModifiedByUsername)
+	r.Equal("Administrator", legacy.ModifiedByDisplayName)
+	r.Empty(legacy.Summary)
+	r.False(legacy.Minor)
+	r.Positive(legacy.Size)
+	r.Equal(legacy.Size, legacy.Delta)
+
+	r.Equal("user", latest.ModifiedByUsername)
+	r.Equal("User", latest.ModifiedByDisplayName)
+	r.Equal("Extended content", latest.Summary)
+	r.True(latest.Minor)
+	r.Equal(latest.Size-legacy.Size, latest.Delta)
+	r.Positive(latest.Delta)
+
+	// Legacy version was added to the sidecar file
+	r.True(s.app.Storage.Exists("attic/published/page.revisions.yml"))
+
+	// Details are kept when moving the page
+	r.NoError(s.app.Content.MovePage("published/page", "published/moved", nil))
+	r.False(s.app.Storage.Exists("attic/published/page.revisions.yml"))
+	entries, err := s.app.Content.ListAttic("published/moved")
+	r.NoError(err)
+	r.Len(entries, 2)
+	r.Equal("Extended content", entries[1].Summary)
+
+	// Details are kept when deleting and restoring the page
+	r.NoError(s.app.Content.DeletePage("published/moved"))
+	r.False(s.app.Storage.Exists("attic/published/moved.revisions.yml"))
+	trash, err := s.app.Content.ListTrash()
+	r.NoError(err)
+	r.Len(trash, 1)
+	r.NoError(s.app.Content.RestoreFromTrash("published/moved", trash[0].DeletedAt))
+	entries, err = s.app.Content.ListAttic("published/moved")
+	r.NoError(err)
+	r.Len(entries, 2)
+	r.Equal("Extended content", entries[1].Summary)
+	r.Equal(s.adminUserID, entries[0].ModifiedByUserID)
+
+	// Deleted versions are removed from the sidecar file
+	r.NoError(s.app.Content.DeleteAtticEntry("published/moved", entries[0].Revision))
+	entries, err = s.app.Content.ListAttic("published/moved")
+	r.NoError(err)
+	r.Len(entries, 1)
+	r.Equal("Extended content", entries[0].Summary)
+	r.Equal(entries[0].Size, entries[0].Delta)
+}
+
 func (s *ContentTestSuite) TestSearch() {
 	r := s.Require()
 
@@ -1345,34 +2599,382 @@ func (s *ContentTestSuite) TestSearchFolder() {
 		q        string
 		nResults int
 	}{
-		{"meta.title", "published", 1},
+		{"meta.title", "published", 1},
+	}
+	for _, tc := range tests {
+		t := s.T()
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			res := s.api("POST", "/search?q="+tc.q,
+				nil,
+				s.adminToken)
+			r.Equal(200, res.Code)
+
+			body, _ := jsonbody[model.SearchResponse](res)
+			r.Len(body.Items, tc.nResults)
+
+			for _, hit := range body.Items {
+				r.Nil(hit.EffectiveACL)
+				r.Nil(hit.Meta.ACL)
+				r.NotEmpty(hit.Url)
+				r.Equal(tc.q, hit.Meta.Title)
+				r.Len(hit.Meta.Tags, 0)
+
+				r.NotEmpty(hit.Fragments["meta.title"])
+				r.Len(hit.Fragments["meta.title"], 1)
+				r.Equal("<mark>"+tc.q+"</mark>", hit.Fragments["meta.title"][0])
+			}
+		})
+	}
+}
+
+func (s *ContentTestSuite) TestSearchQuerySyntax() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/fox", "The quick brown fox jumps", model.ContentMeta{Title: "Animals", Tags: []string{"nature"}}, s.userUserID))
+	r.NoError(s.app.Content.SavePage("published/dog", "The brown dog sleeps until 12:30", model.ContentMeta{Title: "Pets", Tags: []string{"animals"}}, s.adminUserID))
+	r.NoError(s.app.Content.SavePage("page", "Quick start guide", model.ContentMeta{Title: "Guide"}, s.adminUserID))
+
+	tests := []struct {
+		name string
+		q    string
+		urls []string
+	}{
+		{"any term", "fox dog", []string{"published/fox", "published/dog"}},
+		{"required term", "+brown +quick", []string{"published/fox"}},
+		{"excluded term", "brown -fox", []string{"published/dog"}},
+		{"exclusion only", "-brown -published", []string{"page", "read-only", "admin-only", "public"}},
+		{"phrase", `"quick brown"`, []string{"published/fox"}},
+		{"phrase in wrong order", `"brown quick"`, []string{}},
+		{"prefix", "slee*", []string{"published/dog"}},
+		{"title", "title:animals", []string{"published/fox"}},
+		{"tags", "tags:animals", []string{"published/dog"}},
+		{"content", "content:guide", []string{"page"}},
+		{"url", "url:published/fox", []string{"published/fox"}},
+		{"url prefix", "url:/published/*", []string{"published/fox", "published/dog"}},
+		{"modifiedBy", "modifiedBy:user", []string{"published/fox"}},
+		{"modifiedBy unknown user", "modifiedBy:nobody", []string{}},
+		{"time", "12:30", []string{"published/dog"}},
+		{"colon after word", "Note: fox", []string{"published/fox"}},
+		{"unknown field", "http://example.com", []string{}},
+	}
+	for _, tc := range tests {
+		t := s.T()
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			res := s.api("POST", "/search?q="+url.QueryEscape(tc.q), nil, s.adminToken)
+			r.Equal(200, res.Code)
+
+			body, _ := jsonbody[model.SearchResponse](res)
+			urls := []string{}
+			for _, hit := range body.Items {
+				urls = append(urls, hit.Url)
+			}
+			r.ElementsMatch(tc.urls, urls)
+		})
+	}
+
+	// Malformed queries
+	for _, q := range []string{`"unterminated`, "f*o", "title:"} {
+		res := s.api("POST", "/search?q="+url.QueryEscape(q), nil, s.adminToken)
+		r.Equal(400, res.Code, q)
+		r.Contains(res.Body.String(), "invalid search query")
+	}
+}
+
+func (s *ContentTestSuite) TestSearchFilters() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/fox", "animal", model.ContentMeta{Tags: []string{"wild", "mammal"}}, s.userUserID))
+	r.NoError(s.app.Content.SavePage("published/dog", "animal", model.ContentMeta{Tags: []string{"pet", "mammal"}}, s.adminUserID))
+	r.NoError(s.app.Content.SavePage("page", "animal", model.ContentMeta{Tags: []string{"wild"}}, s.adminUserID))
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	tests := []struct {
+		name   string
+		params string
+		urls   []string
+	}{
+		{"no filter", "q=animal", []string{"published/fox", "published/dog", "page"}},
+		{"folder", "q=animal&folder=published", []string{"published/fox", "published/dog"}},
+		{"folder includes folder itself", "q=published&folder=/published/", []string{"published"}},
+		{"unknown folder", "q=animal&folder=pub", []string{}},
+		{"tag", "q=animal&tags=wild", []string{"published/fox", "page"}},
+		{"all tags required", "q=animal&tags=wild,mammal", []string{"published/fox"}},
+		{"repeated tags", "q=animal&tags=wild&tags=mammal", []string{"published/fox"}},
+		{"tags are not analyzed", "q=animal&tags=mamm", []string{}},
+		{"modifiedBy", "q=animal&modifiedBy=user", []string{"published/fox"}},
+		{"modifiedBy unknown user", "q=animal&modifiedBy=nobody", []string{}},
+		{"modifiedAfter today", "q=animal&modifiedAfter=" + today, []string{"published/fox", "published/dog", "page"}},
+		{"modifiedAfter tomorrow", "q=animal&modifiedAfter=" + tomorrow, []string{}},
+		{"modifiedBefore today", "q=animal&modifiedBefore=" + today, []string{"published/fox", "published/dog", "page"}},
+		{"modifiedBefore yesterday", "q=animal&modifiedBefore=" + yesterday, []string{}},
+		{"timestamp", "q=animal&modifiedAfter=" + url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)), []string{"published/fox", "published/dog", "page"}},
+		{"filters without query", "folder=published&tags=mammal", []string{"published/fox", "published/dog"}},
+		{"combined", "q=animal&folder=published&tags=mammal&modifiedBy=admin&modifiedAfter=" + yesterday, []string{"published/dog"}},
 	}
 	for _, tc := range tests {
 		t := s.T()
 		t.Run(tc.name, func(t *testing.T) {
 			r := require.New(t)
 
-			res := s.api("POST", "/search?q="+tc.q,
-				nil,
-				s.adminToken)
+			res := s.api("POST", "/search?"+tc.params, nil, s.adminToken)
 			r.Equal(200, res.Code)
 
 			body, _ := jsonbody[model.SearchResponse](res)
-			r.Len(body.Items, tc.nResults)
-
+			urls := []string{}
 			for _, hit := range body.Items {
-				r.Nil(hit.EffectiveACL)
-				r.Nil(hit.Meta.ACL)
-				r.NotEmpty(hit.Url)
-				r.Equal(tc.q, hit.Meta.Title)
-				r.Len(hit.Meta.Tags, 0)
-
-				r.NotEmpty(hit.Fragments["meta.title"])
-				r.Len(hit.Fragments["meta.title"], 1)
-				r.Equal("<mark>"+tc.q+"</mark>", hit.Fragments["meta.title"][0])
+				urls = append(urls, hit.Url)
 			}
+			r.ElementsMatch(tc.urls, urls)
 		})
 	}
+
+	// Invalid filters
+	for _, params := range []string{"q=animal&modifiedAfter=yesterday", "q=animal&modifiedBefore=2024-13-01", "q=animal&folder=../etc"} {
+		res := s.api("POST", "/search?"+params, nil, s.adminToken)
+		r.Equal(400, res.Code, params)
+	}
+}
+
+func (s *ContentTestSuite) TestTags() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/fox", "", model.ContentMeta{Tags: []string{"wild", "mammal"}}, s.userUserID))
+	r.NoError(s.app.Content.SavePage("published/dog", "", model.ContentMeta{Tags: []string{"pet", "mammal"}}, s.adminUserID))
+	r.NoError(s.app.Content.SavePage("read-only/bird", "", model.ContentMeta{Tags: []string{"wild", "two words"}}, s.adminUserID))
+	r.NoError(s.app.Content.SavePage("admin-only/wolf", "", model.ContentMeta{Tags: []string{"wild", "secret"}}, s.adminUserID))
+	r.NoError(s.app.Content.SavePage("admin-only/untagged", "", model.ContentMeta{}, s.adminUserID))
+	r.NoError(s.app.Content.SaveFolder("read-only/sub", model.ContentMeta{Tags: []string{"mammal"}}, nil))
+
+	// Counts only include readable content
+	tests := []struct {
+		name  string
+		token *string
+		tags  []model.TagCount
+	}{
+		{"admin", s.adminToken, []model.TagCount{{Tag: "mammal", Count: 3}, {Tag: "wild", Count: 3}, {Tag: "pet", Count: 1}, {Tag: "secret", Count: 1}, {Tag: "two words", Count: 1}}},
+		{"user", s.userToken, []model.TagCount{{Tag: "mammal", Count: 3}, {Tag: "wild", Count: 2}, {Tag: "pet", Count: 1}, {Tag: "two words", Count: 1}}},
+		{"anonymous", nil, []model.TagCount{{Tag: "mammal", Count: 2}, {Tag: "pet", Count: 1}, {Tag: "wild", Count: 1}}},
+	}
+	for _, tc := range tests {
+		res := s.api("GET", "/tags", nil, tc.token)
+		r.Equal(200, res.Code, tc.name)
+		body, _ := jsonbody[model.GetTagsResponse](res)
+		r.Equal(tc.tags, body.Tags, tc.name)
+	}
+
+	// Content carrying a tag
+	urls := func(items []model.ContentSummary) []string {
+		urls := []string{}
+		for _, item := range items {
+			urls = append(urls, item.Url)
+		}
+		return urls
+	}
+
+	res := s.api("GET", "/tags/wild", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetTagResponse](res)
+	r.Equal("wild", body.Tag)
+	r.Equal([]string{"published/fox", "read-only/bird"}, urls(body.Items))
+	r.Nil(body.Items[0].Meta.ACL)
+	r.Equal("user", body.Items[0].Meta.ModifiedByUsername)
+
+	res = s.api("GET", "/tags/mammal", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetTagResponse](res)
+	r.Equal([]string{"published/dog", "published/fox", "read-only/sub"}, urls(body.Items))
+	r.True(body.Items[2].IsFolder)
+
+	res = s.api("GET", "/tags/two%20words", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetTagResponse](res)
+	r.Equal("two words", body.Tag)
+	r.Equal([]string{"read-only/bird"}, urls(body.Items))
+
+	// Tags are matched exactly
+	res = s.api("GET", "/tags/mamm", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetTagResponse](res)
+	r.Empty(body.Items)
+
+	// Inaccessible content is hidden
+	res = s.api("GET", "/tags/secret", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetTagResponse](res)
+	r.Empty(body.Items)
+
+	// Tag facets in search results
+	res = s.api("POST", "/search?tags=wild", nil, s.userToken)
+	r.Equal(200, res.Code)
+	searchBody, _ := jsonbody[model.SearchResponse](res)
+	r.Equal([]model.TagCount{{Tag: "wild", Count: 2}, {Tag: "mammal", Count: 1}, {Tag: "two words", Count: 1}}, searchBody.Tags)
+	r.False(searchBody.TagsTruncated)
+
+	// Admins get tag facets of all results from the index
+	res = s.api("POST", "/search?tags=wild", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	searchBody, _ = jsonbody[model.SearchResponse](res)
+	r.Equal([]model.TagCount{{Tag: "wild", Count: 3}, {Tag: "mammal", Count: 1}, {Tag: "secret", Count: 1}, {Tag: "two words", Count: 1}}, searchBody.Tags)
+
+	res = s.api("POST", "/search?q=nothingmatches", nil, s.userToken)
+	r.Equal(200, res.Code)
+	searchBody, _ = jsonbody[model.SearchResponse](res)
+	r.Empty(searchBody.Tags)
+}
+
+func (s *ContentTestSuite) TestTagFacetsTruncated() {
+	r := s.Require()
+
+	for i := 0; i <= 1000; i++ {
+		r.NoError(s.app.Content.SavePageWithoutVersion(fmt.Sprintf("published/page%04d", i), "", model.ContentMeta{Tags: []string{"many"}}, "", nil))
+	}
+
+	res := s.api("POST", "/search?tags=many", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.SearchResponse](res)
+	r.Equal([]model.TagCount{{Tag: "many", Count: 1000}}, body.Tags)
+	r.True(body.TagsTruncated)
+
+	res = s.api("POST", "/search?tags=many", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.SearchResponse](res)
+	r.Equal([]model.TagCount{{Tag: "many", Count: 1001}}, body.Tags)
+	r.False(body.TagsTruncated)
+
+	// Tags are counted among the best matching results, not by URL
+	for i := 0; i < 3; i++ {
+		r.NoError(s.app.Content.SavePageWithoutVersion(fmt.Sprintf("published/zzz%d", i), "unique", model.ContentMeta{Tags: []string{"many", "best"}}, "", nil))
+	}
+	res = s.api("POST", "/search?q=many+unique", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.SearchResponse](res)
+	r.True(strings.HasPrefix(body.Items[0].Url, "published/zzz"))
+	r.Contains(body.Tags, model.TagCount{Tag: "best", Count: 3})
+	r.True(body.TagsTruncated)
+}
+
+func (s *ContentTestSuite) TestTagsRateLimit() {
+	r := s.Require()
+
+	for i := 0; i < 3; i++ {
+		r.Equal(200, s.api("GET", "/tags", nil, nil).Code)
+	}
+	r.Equal(429, s.api("GET", "/tags", nil, nil).Code)
+	r.Equal(429, s.api("GET", "/tags/any", nil, nil).Code)
+	r.Equal(200, s.api("GET", "/tags", nil, s.userToken).Code)
+}
+
+func (s *ContentTestSuite) TestLinks() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/page", "[a](other) [b](/admin-only/secret) [c](/missing) [d](/read-only) [e](https://example.com)", model.ContentMeta{Title: "Page"}, ""))
+	r.NoError(s.app.Content.SavePage("published/other", "[back](page)", model.ContentMeta{Title: "Other"}, ""))
+	r.NoError(s.app.Content.SavePage("admin-only/secret", "[a](/published/page)", model.ContentMeta{Title: "Secret"}, ""))
+	r.NoError(s.app.Content.SavePage("read-only/page", "[a](/published/page) [b](../read-only)", model.ContentMeta{Title: "Read-only page"}, ""))
+
+	urls := func(links []model.LinkedContent) []string {
+		urls := []string{}
+		for _, link := range links {
+			urls = append(urls, link.Url)
+		}
+		return urls
+	}
+
+	// Links are filtered by read permission
+	res := s.api("GET", "/links/published/page", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.GetLinksResponse](res)
+	r.Equal([]string{"published/other", "admin-only/secret", "missing", "read-only"}, urls(body.Outgoing))
+	r.Equal([]string{"admin-only/secret", "published/other", "read-only/page"}, urls(body.Backlinks))
+
+	r.Equal(model.LinkedContent{Url: "published/other", Title: "Other", Exists: true}, body.Outgoing[0])
+	r.Equal(model.LinkedContent{Url: "missing"}, body.Outgoing[2])
+	r.Equal(model.LinkedContent{Url: "read-only", Title: "read-only", IsFolder: true, Exists: true}, body.Outgoing[3])
+
+	res = s.api("GET", "/links/published/page", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetLinksResponse](res)
+	r.Equal([]string{"published/other", "missing", "read-only"}, urls(body.Outgoing))
+	r.Equal([]string{"published/other", "read-only/page"}, urls(body.Backlinks))
+
+	res = s.api("GET", "/links/published/page", nil, nil)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetLinksResponse](res)
+	r.Equal([]string{"published/other", "missing"}, urls(body.Outgoing))
+	r.Equal([]string{"published/other"}, urls(body.Backlinks))
+
+	// Backlinks of folders
+	res = s.api("GET", "/links/read-only", nil, s.userToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.GetLinksResponse](res)
+	r.Empty(body.Outgoing)
+	r.Equal([]string{"published/page", "read-only/page"}, urls(body.Backlinks))
+
+	// Links are updated when pages are saved
+	r.NoError(s.app.Content.SavePage("published/other", "no links", model.ContentMeta{Title: "Other"}, ""))
+	res = s.api("GET", "/links/published/page", nil, s.userToken)
+	body, _ = jsonbody[model.GetLinksResponse](res)
+	r.Equal([]string{"read-only/page"}, urls(body.Backlinks))
+
+	// Links of inaccessible or missing content
+	res = s.api("GET", "/links/admin-only/secret", nil, s.userToken)
+	r.Equal(403, res.Code)
+	res = s.api("GET", "/links/published/missing", nil, s.userToken)
+	r.Equal(404, res.Code)
+}
+
+func (s *ContentTestSuite) TestLinkReport() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("published/page", "[a](other) [b](/missing) [c](/read-only) [self](page)", model.ContentMeta{Title: "Page"}, ""))
+	r.NoError(s.app.Content.SavePage("published/other", "[a](page) [b](/also-missing#section)", model.ContentMeta{Title: "Other"}, ""))
+	r.NoError(s.app.Content.SavePage("published/lonely", "[self](lonely)", model.ContentMeta{Title: "Lonely"}, ""))
+	r.NoError(s.app.Content.CreateFolder("published/folder", model.ContentMeta{}))
+	r.NoError(s.app.Content.SavePage("published/folder/moved", "[a](../other)", model.ContentMeta{Title: "Moved"}, ""))
+
+	res := s.api("GET", "/reports/links", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ := jsonbody[model.LinkReport](res)
+	r.Equal([]model.BrokenLink{
+		{Source: "published/other", Target: "also-missing"},
+		{Source: "published/page", Target: "missing"},
+	}, body.BrokenLinks)
+	// Pages only linking to themselves or not linked at all are orphans
+	r.Equal([]model.LinkedContent{
+		{Url: "published/folder/moved", Title: "Moved", Exists: true},
+		{Url: "published/lonely", Title: "Lonely", Exists: true},
+	}, body.Orphans)
+
+	// Pages linked by other pages aren't orphans, folders are never reported
+	r.NoError(s.app.Content.SavePage("published/lonely", "[self](lonely) [a](folder/moved)", model.ContentMeta{Title: "Lonely"}, ""))
+	r.NoError(s.app.Content.SavePage("published/folder/moved", "[a](../other) [b](..)", model.ContentMeta{Title: "Moved"}, ""))
+
+	res = s.api("GET", "/reports/links", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.LinkReport](res)
+	r.Equal([]model.LinkedContent{
+		{Url: "published/lonely", Title: "Lonely", Exists: true},
+	}, body.Orphans)
+
+	// Moving a folder breaks relative links leaving it
+	r.NoError(s.app.Content.MoveFolder("published/folder", "moved-folder", nil))
+	res = s.api("GET", "/reports/links", nil, s.adminToken)
+	r.Equal(200, res.Code)
+	body, _ = jsonbody[model.LinkReport](res)
+	r.Contains(body.BrokenLinks, model.BrokenLink{Source: "moved-folder/moved", Target: "other"})
+
+	// Only admins can access the report
+	res = s.api("GET", "/reports/links", nil, s.userToken)
+	r.Equal(403, res.Code)
+	res = s.api("GET", "/reports/links", nil, nil)
+	r.Equal(401, res.Code)
 }
 
 // TestSearchPaginationWithACL tests pagination when many results are filtered by ACL.
@@ -2504,6 +4106,444 @@ func (s *ContentTestSuite) TestTrashRestoreConflict() {
 	r.Len(trashAfter, 1)
 }
 
+func (s *ContentTestSuite) TestDenyRulesAndExtendedACL() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("read-only/page", "Content", model.ContentMeta{Title: "Page"}, ""))
+
+	setACL := func(url string, acl []model.AccessRule, extend bool) {
+		res := s.api("PATCH", "/pages/"+url,
+			[]model.PatchOperation{
+				{Op: "replace", Path: "/page/meta/acl", Value: acl2json(acl)},
+				{Op: "replace", Path: "/page/meta/extendAcl", Value: bool2json(extend)},
+			},
+			s.adminToken)
+		r.Equal(200, res.Code)
+	}
+
+	listed := func(token *string) bool {
+		res := s.api("GET", "/pages/read-only", nil, token)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		return slices.ContainsFunc(body.Folder.Content, func(e model.FolderEntry) bool { return e.Url == "read-only/page" })
+	}
+
+	// Only admins can change the inheritance
+	{
+		res := s.api("PATCH", "/pages/read-only/page",
+			[]model.PatchOperation{{Op: "replace", Path: "/page/meta/extendAcl", Value: bool2json(true)}},
+			s.userToken)
+		r.Equal(403, res.Code)
+	}
+
+	// Replacing ACL: the inherited read permission is lost
+	setACL("read-only/page", []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpWrite}}}, false)
+	{
+		res := s.api("GET", "/pages/read-only/page", nil, s.userToken)
+		r.Equal(403, res.Code)
+		r.False(listed(s.userToken))
+	}
+
+	// Extending ACL: the inherited read permission is kept
+	setACL("read-only/page", []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpWrite}}}, true)
+	{
+		res := s.api("GET", "/pages/read-only/page", nil, s.userToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		r.True(body.AllowWrite)
+		r.True(listed(s.userToken))
+	}
+
+	// Deny rule of a more specific subject overrides the inherited permission
+	setACL("read-only/page", []model.AccessRule{{Subject: "user:" + s.userUserID, Deny: []model.AccessOp{model.AccessOpRead}}}, true)
+	{
+		res := s.api("GET", "/pages/read-only/page", nil, s.userToken)
+		r.Equal(403, res.Code)
+		r.False(listed(s.userToken))
+
+		// Admins are not affected by deny rules
+		res = s.api("GET", "/pages/read-only/page", nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetContentResponse](res)
+		r.True(body.Page.Meta.ExtendACL)
+	}
+
+	// Extending nested ACLs
+	{
+		r.NoError(s.app.Content.CreateFolder("read-only/sub", model.ContentMeta{Title: "Sub"}))
+		r.NoError(s.app.Content.SavePage("read-only/sub/page", "Content", model.ContentMeta{Title: "Page"}, ""))
+
+		res := s.api("PATCH", "/pages/read-only/sub",
+			[]model.PatchOperation{
+				{Op: "replace", Path: "/folder/meta/acl", Value: acl2json([]model.AccessRule{{Subject: "user:" + s.userUserID, Deny: []model.AccessOp{model.AccessOpRead}}})},
+				{Op: "replace", Path: "/folder/meta/extendAcl", Value: bool2json(true)},
+			},
+			s.adminToken)
+		r.Equal(200, res.Code)
+
+		res = s.api("GET", "/pages/read-only/sub/page", nil, s.userToken)
+		r.Equal(403, res.Code)
+
+		// Rule for the same subject replaces the inherited one
+		setACL("read-only/sub/page", []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpRead}}}, true)
+		res = s.api("GET", "/pages/read-only/sub/page", nil, s.userToken)
+		r.Equal(200, res.Code)
+	}
+}
+
+func (s *ContentTestSuite) TestPermissionsExplanation() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("read-only/page", "Content", model.ContentMeta{Title: "Page"}, ""))
+
+	explain := func(url, username string) model.GetPermissionsResponse {
+		res := s.api("GET", "/permissions/"+url+"?user="+username, nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.GetPermissionsResponse](res)
+		r.Len(body.Operations, 3)
+		return body
+	}
+
+	// Only admins can explain permissions
+	{
+		res := s.api("GET", "/permissions/read-only/page?user="+TestUserUsername, nil, s.userToken)
+		r.Equal(403, res.Code)
+		res = s.api("GET", "/permissions/read-only/page", nil, nil)
+		r.Equal(401, res.Code)
+	}
+
+	// Errors
+	{
+		res := s.api("GET", "/permissions/read-only/nonexistent?user="+TestUserUsername, nil, s.adminToken)
+		r.Equal(404, res.Code)
+		res = s.api("GET", "/permissions/read-only/page?user=nonexistent", nil, s.adminToken)
+		r.Equal(404, res.Code)
+	}
+
+	// Inherited rule of the ancestor folder
+	{
+		body := explain("read-only/page", TestUserUsername)
+		r.Equal("read-only/page", body.Url)
+		r.NotNil(body.User)
+		r.Equal(s.userUserID, body.User.ID)
+
+		read := body.Operations[0]
+		r.Equal(model.AccessOpRead, read.Op)
+		r.True(read.Allowed)
+		r.Equal(model.PermissionSourceFolder, read.Source)
+		r.Equal("read-only", read.SourceUrl)
+		r.True(read.Inherited)
+		r.Equal("all", read.Rule.Subject)
+
+		write := body.Operations[1]
+		r.Equal(model.AccessOpWrite, write.Op)
+		r.False(write.Allowed)
+		r.Equal(model.PermissionSourceNone, write.Source)
+		r.Nil(write.Rule)
+	}
+
+	// Rule of the page itself, extending the ACL of the folder
+	{
+		acl := []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpWrite}, Deny: []model.AccessOp{model.AccessOpDelete}}}
+		res := s.api("PATCH", "/pages/read-only/page",
+			[]model.PatchOperation{
+				{Op: "replace", Path: "/page/meta/acl", Value: acl2json(acl)},
+				{Op: "replace", Path: "/page/meta/extendAcl", Value: bool2json(true)},
+			},
+			s.adminToken)
+		r.Equal(200, res.Code)
+
+		body := explain("read-only/page", TestUserUsername)
+		r.True(body.Operations[0].Allowed)
+		r.Equal("read-only", body.Operations[0].SourceUrl)
+
+		write := body.Operations[1]
+		r.True(write.Allowed)
+		r.Equal(model.PermissionSourcePage, write.Source)
+		r.Equal("read-only/page", write.SourceUrl)
+		r.False(write.Inherited)
+		r.NotNil(write.Rule.User)
+		r.Equal(TestUserUsername, write.Rule.User.Username)
+
+		del := body.Operations[2]
+		r.Equal(model.AccessOpDelete, del.Op)
+		r.False(del.Allowed)
+		r.Equal(model.PermissionSourcePage, del.Source)
+		r.Equal([]model.AccessOp{model.AccessOpDelete}, del.Rule.Deny)
+	}
+
+	// Admin privileges apply if the content ACL doesn't allow the operation
+	{
+		body := explain("read-only/page", TestAdminUsername)
+		r.True(body.Operations[0].Allowed)
+		r.Equal(model.PermissionSourceFolder, body.Operations[0].Source)
+		for _, op := range body.Operations[1:] {
+			r.True(op.Allowed)
+			r.Equal(model.PermissionSourceAdmin, op.Source)
+			r.Empty(op.SourceUrl)
+			r.Equal("user:"+s.adminUserID, op.Rule.Subject)
+		}
+	}
+
+	// Anonymous access
+	{
+		body := explain("published", "")
+		r.Nil(body.User)
+		r.True(body.Operations[0].Allowed)
+		r.Equal(model.PermissionSourceFolder, body.Operations[0].Source)
+		r.Equal("anonymous", body.Operations[0].Rule.Subject)
+		r.False(body.Operations[1].Allowed)
+	}
+}
+
+func (s *ContentTestSuite) TestAccessReport() {
+	r := s.Require()
+
+	r.NoError(s.app.Content.SavePage("read-only/page", "Content", model.ContentMeta{Title: "Page"}, ""))
+	r.NoError(s.app.Content.SavePage("published/page", "Content", model.ContentMeta{Title: "Published page"}, ""))
+
+	group, err := s.app.Users.CreateGroup("Auditors", nil)
+	r.NoError(err)
+	defer func() {
+		r.NoError(s.app.Users.DeleteGroup(group.ID))
+	}()
+	groupACL := []model.AccessRule{{Subject: "group:" + group.ID, Operations: []model.AccessOp{model.AccessOpRead}}}
+	r.NoError(s.app.Content.SavePage("admin-only/audit", "Content", model.ContentMeta{Title: "Audit", ACL: &groupACL}, ""))
+
+	report := func(query string) model.AccessReport {
+		res := s.api("GET", "/reports/access"+query, nil, s.adminToken)
+		r.Equal(200, res.Code)
+		body, _ := jsonbody[model.AccessReport](res)
+		r.NotNil(body.Root)
+		return body
+	}
+	child := func(node model.AccessReportNode, url string) *model.AccessReportNode {
+		for i := range node.Children {
+			if node.Children[i].Url == url {
+				return &node.Children[i]
+			}
+		}
+		return nil
+	}
+	readOnly := []model.AccessOp{model.AccessOpRead}
+	readWriteDelete := []model.AccessOp{model.AccessOpRead, model.AccessOpWrite, model.AccessOpDelete}
+
+	// Only admins can create access reports
+	{
+		res := s.api("GET", "/reports/access", nil, s.userToken)
+		r.Equal(403, res.Code)
+		res = s.api("GET", "/reports/access", nil, nil)
+		r.Equal(401, res.Code)
+	}
+
+	// Errors
+	{
+		res := s.api("GET", "/reports/access?user=nonexistent", nil, s.adminToken)
+		r.Equal(404, res.Code)
+		res = s.api("GET", "/reports/access?group=nonexistent", nil, s.adminToken)
+		r.Equal(404, res.Code)
+		res = s.api("GET", "/reports/access?user="+TestUserUsername+"&group="+group.ID, nil, s.adminToken)
+		r.Equal(400, res.Code)
+	}
+
+	// User
+	{
+		body := report("?user=" + TestUserUsername)
+		r.Equal("user:"+s.userUserID, body.Subject)
+		r.NotNil(body.User)
+		r.Equal(TestUserUsername, body.User.Username)
+
+		r.Nil(child(*body.Root, "admin-only"))
+
+		folder := child(*body.Root, "read-only")
+		r.NotNil(folder)
+		r.Equal(readOnly, folder.Ops)
+		r.True(folder.Uniform)
+		r.Empty(folder.Children)
+
+		folder = child(*body.Root, "published")
+		r.NotNil(folder)
+		r.Equal(readWriteDelete, folder.Ops)
+		r.True(folder.Uniform)
+	}
+
+	// Folders containing only hidden content aren't uniform, nor are the folders above them
+	{
+		r.NoError(s.app.Content.CreateFolder("read-only/sub", model.ContentMeta{Title: "Sub"}))
+		r.NoError(s.app.Content.SavePage("read-only/sub/secret", "Content", model.ContentMeta{Title: "Secret", ACL: &[]model.AccessRule{}}, ""))
+
+		body := report("?user=" + TestUserUsername)
+		folder := child(*body.Root, "read-only")
+		r.NotNil(folder)
+		r.False(folder.Uniform)
+
+		sub := child(*folder, "read-only/sub")
+		r.NotNil(sub)
+		r.Equal(readOnly, sub.Ops)
+		r.False(sub.Uniform)
+		r.Empty(sub.Children)
+
+		r.NoError(s.app.Content.DeleteFolder("read-only/sub"))
+	}
+
+	// Anonymous
+	{
+		body := report("")
+		r.Equal("anonymous", body.Subject)
+		r.Nil(body.User)
+		r.Empty(body.Root.Ops)
+		r.Nil(child(*body.Root, "admin-only"))
+		r.Nil(child(*body.Root, "read-only"))
+
+		folder := child(*body.Root, "published")
+		r.NotNil(folder)
+		r.Equal(readOnly, folder.Ops)
+
+		folder = child(*body.Root, "public")
+		r.NotNil(folder)
+		r.Equal(readWriteDelete, folder.Ops)
+	}
+
+	// Group
+	{
+		body := report("?group=" + group.ID)
+		r.Equal("group:"+group.ID, body.Subject)
+		r.NotNil(body.Group)
+
+		folder := child(*body.Root, "admin-only")
+		r.NotNil(folder)
+		r.Empty(folder.Ops)
+		r.False(folder.Uniform)
+		r.Len(folder.Children, 1)
+		r.Equal("admin-only/audit", folder.Children[0].Url)
+		r.Equal(readOnly, folder.Children[0].Ops)
+	}
+
+	// CSV export
+	{
+		res := s.api("GET", "/reports/access?format=csv&user="+TestUserUsername, nil, s.adminToken)
+		r.Equal(200, res.Code)
+		r.Contains(res.Header().Get("Content-Type"), "text/csv")
+		r.Contains(res.Header().Get("Content-Disposition"), "access-report.csv")
+
+		lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+		r.Equal("url,type,title,read,write,delete", lines[0])
+		r.Contains(lines, "/read-only,folder,read-only,yes,no,no")
+		r.Contains(lines, "/read-only/page,page,Page,yes,no,no")
+		r.NotContains(res.Body.String(), "admin-only")
+	}
+}
+
+func (s *ContentTestSuite) TestSubtreeACL() {
+	r := s.Require()
+
+	ownACL := []model.AccessRule{{Subject: "user:" + s.userUserID, Operations: []model.AccessOp{model.AccessOpRead}}}
+	r.NoError(s.app.Content.SavePage("published/own", "Content", model.ContentMeta{Title: "Own", ACL: &ownACL}, ""))
+	r.NoError(s.app.Content.SavePage("published/inherited", "Content", model.ContentMeta{Title: "Inherited"}, ""))
+	r.NoError(s.app.Content.CreateFolder("published/sub", model.ContentMeta{Title: "Sub", ACL: &ownACL, ExtendACL: true}))
+	r.NoError(s.app.Content.SavePage("published/sub/page", "Content", model.ContentMeta{Title: "Page", ACL: &ownACL}, ""))
+
+	readACL := []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRead}}}
+
+	subtreeACL := func(url string, body model.SubtreeACLRequest) []string {
+		res := s.api("POST", "/pages/"+url+"/acl", body, s.adminToken)
+		r.Equal(200, res.Code)
+		response, _ := jsonbody[model.SubtreeACLResponse](res)
+		r.Empty(response.Failed)
+		return response.Affected
+	}
+	readMeta := func(url string) model.ContentMeta {
+		if s.app.Content.IsFolder(url) {
+			meta, err := s.app.Content.ReadFolderMeta(url)
+			r.NoError(err)
+			return meta
+		}
+		page, err := s.app.Content.ReadPage(url, nil)
+		r.NoError(err)
+		return page.Meta
+	}
+
+	// Only admins can change ACLs
+	{
+		res := s.api("POST", "/pages/published/acl", model.SubtreeACLRequest{Mode: model.SubtreeACLReset}, s.userToken)
+		r.Equal(403, res.Code)
+		res = s.api("POST", "/pages/public/acl", model.SubtreeACLRequest{Mode: model.SubtreeACLReset}, nil)
+		r.Equal(401, res.Code)
+	}
+
+	// Errors
+	{
+		res := s.api("POST", "/pages/published/own/acl", model.SubtreeACLRequest{Mode: model.SubtreeACLReset}, s.adminToken)
+		r.Equal(404, res.Code)
+		res = s.api("POST", "/pages/published/acl", model.SubtreeACLRequest{Mode: "invalid"}, s.adminToken)
+		r.Equal(400, res.Code)
+		res = s.api("POST", "/pages/published/acl", model.SubtreeACLRequest{Mode: model.SubtreeACLApply}, s.adminToken)
+		r.Equal(400, res.Code)
+		invalidACL := []model.AccessRule{{Subject: "invalid", Operations: []model.AccessOp{model.AccessOpRead}}}
+		res = s.api("POST", "/pages/published/acl", model.SubtreeACLRequest{Mode: model.SubtreeACLApply, ACL: &invalidACL}, s.adminToken)
+		r.Equal(400, res.Code)
+	}
+
+	// Dry run of reset doesn't change anything
+	{
+		affected := subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLReset, DryRun: true})
+		r.ElementsMatch([]string{"published/own", "published/sub", "published/sub/page"}, affected)
+		r.NotNil(readMeta("published/own").ACL)
+	}
+
+	// Reset lets all content inherit the ACL of the folder
+	{
+		affected := subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLReset})
+		r.ElementsMatch([]string{"published/own", "published/sub", "published/sub/page"}, affected)
+
+		// One change per folder is recorded
+		changes, _, err := s.app.Changes.List(0, 10, func(model.Change) bool { return true })
+		r.NoError(err)
+		r.Len(changes, 2)
+		urls := []string{}
+		for _, change := range changes {
+			r.Equal(model.ChangePermissions, change.Type)
+			r.True(change.IsFolder)
+			urls = append(urls, change.Url)
+		}
+		r.ElementsMatch([]string{"published", "published/sub"}, urls)
+		for _, url := range affected {
+			meta := readMeta(url)
+			r.Nil(meta.ACL)
+			r.False(meta.ExtendACL)
+		}
+		r.NotNil(readMeta("published").ACL)
+
+		// Metadata-only change doesn't create a new version
+		entries, err := s.app.Content.ListAttic("published/own")
+		r.NoError(err)
+		r.Len(entries, 1)
+
+		r.Empty(subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLReset, DryRun: true}))
+	}
+
+	// Apply sets the ACL on the folder and all content
+	{
+		affected := subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLApply, ACL: &readACL, DryRun: true})
+		r.ElementsMatch([]string{"published", "published/own", "published/inherited", "published/sub", "published/sub/page"}, affected)
+		r.Nil(readMeta("published/inherited").ACL)
+
+		affected = subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLApply, ACL: &readACL})
+		r.Len(affected, 5)
+		for _, url := range affected {
+			meta := readMeta(url)
+			r.NotNil(meta.ACL)
+			r.Equal(readACL[0].Subject, (*meta.ACL)[0].Subject)
+			r.Equal(readACL[0].Operations, (*meta.ACL)[0].Operations)
+		}
+
+		res := s.api("PUT", "/pages/published/inherited", model.PutRequest{Page: &model.Page{Content: "Changed"}}, s.userToken)
+		r.Equal(403, res.Code)
+
+		r.Empty(subtreeACL("published", model.SubtreeACLRequest{Mode: model.SubtreeACLApply, ACL: &readACL, DryRun: true}))
+	}
+}
+
 // TestContentACLValidation tests that ACL values are properly validated when setting ACLs on pages/folders
 func (s *ContentTestSuite) TestContentACLValidation() {
 	r := s.Require()
@@ -2534,6 +4574,11 @@ func (s *ContentTestSuite) TestContentACLValidation() {
 			acl:          []model.AccessRule{{Subject: "user:" + s.adminUserID, Operations: []model.AccessOp{model.AccessOpRead, model.AccessOpWrite, model.AccessOpDelete}}},
 			responseCode: 200,
 		},
+		{
+			name:         "valid:group-read",
+			acl:          []model.AccessRule{{Subject: "group:editors", Operations: []model.AccessOp{model.AccessOpRead}}},
+			responseCode: 200,
+		},
 		{
 			name:         "valid:multiple-rules",
 			acl:          []model.AccessRule{{Subject: "anonymous", Operations: []model.AccessOp{model.AccessOpRead}}, {Subject: "all", Operations: []model.AccessOp{model.AccessOpWrite}}},
@@ -2554,6 +4599,11 @@ func (s *ContentTestSuite) TestContentACLValidation() {
 			acl:          []model.AccessRule{{Subject: "anonymous", Operations: []model.AccessOp{}}},
 			responseCode: 200,
 		},
+		{
+			name:         "valid:deny-read",
+			acl:          []model.AccessRule{{Subject: "user:" + s.userUserID, Deny: []model.AccessOp{model.AccessOpRead}}},
+			responseCode: 200,
+		},
 		// Invalid subjects
 		{
 			name:         "invalid:subject-empty",
@@ -2566,8 +4616,8 @@ func (s *ContentTestSuite) TestContentACLValidation() {
 			responseCode: 400,
 		},
 		{
-			name:         "invalid:subject-group",
-			acl:          []model.AccessRule{{Subject: "group:admins", Operations: []model.AccessOp{model.AccessOpRead}}},
+			name:         "invalid:subject-group-empty-id",
+			acl:          []model.AccessRule{{Subject: "group:", Operations: []model.AccessOp{model.AccessOpRead}}},
 			responseCode: 400,
 		},
 		{
@@ -2591,6 +4641,11 @@ func (s *ContentTestSuite) TestContentACLValidation() {
 			acl:          []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{model.AccessOpRegister}}},
 			responseCode: 400,
 		},
+		{
+			name:         "invalid:deny-admin",
+			acl:          []model.AccessRule{{Subject: "all", Deny: []model.AccessOp{model.AccessOpAdmin}}},
+			responseCode: 400,
+		},
 		{
 			name:         "invalid:op-unknown",
 			acl:          []model.AccessRule{{Subject: "all", Operations: []model.AccessOp{"superadmin"}}},
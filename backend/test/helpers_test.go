@@ -9,8 +9,10 @@ import (
 	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 
 	"github.com/stretchr/testify/suite"
+	"github.com/tfabritius/plainpage/libs/mail"
 	"github.com/tfabritius/plainpage/model"
 	"github.com/tfabritius/plainpage/server"
 	"github.com/tfabritius/plainpage/service"
@@ -220,3 +222,65 @@ func (s *AppTestSuite) apiRawBody(method, target string, body []byte, token *str
 	s.handler.ServeHTTP(res, req)
 	return res
 }
+
+func strs2json(s []string) *json.RawMessage {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	jsonRawMsg := json.RawMessage(bytes)
+	return &jsonRawMsg
+}
+
+func bool2json(b bool) *json.RawMessage {
+	bytes, err := json.Marshal(b)
+	if err != nil {
+		panic(err)
+	}
+	jsonRawMsg := json.RawMessage(bytes)
+	return &jsonRawMsg
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// fakeMailSender collects the emails sent by the app
+type fakeMailSender struct {
+	mu       sync.Mutex
+	messages []mail.Message
+
+	// failing contains the errors returned instead of sending emails by address
+	failing map[string]error
+}
+
+func (f *fakeMailSender) Send(msg mail.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, to := range msg.To {
+		if err := f.failing[to]; err != nil {
+			return err
+		}
+	}
+	f.messages = append(f.messages, msg)
+	return nil
+}
+
+// fail makes sending emails to the address fail with err, or succeed again if err is nil
+func (f *fakeMailSender) fail(to string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing == nil {
+		f.failing = map[string]error{}
+	}
+	f.failing[to] = err
+}
+
+// take returns the emails sent so far and forgets them
+func (f *fakeMailSender) take() []mail.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := f.messages
+	f.messages = nil
+	return messages
+}
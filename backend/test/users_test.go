@@ -402,7 +402,7 @@ func (s *UsersTestSuite) TestPatchUser() {
 	// Unsupported operation fails
 	{
 		res := s.api("PATCH", "/auth/users/"+username,
-			[]map[string]string{{"op": "add", "path": "/displayName", "value": "New Name"}},
+			[]map[string]string{{"op": "merge", "path": "/displayName", "value": "New Name"}},
 			&token)
 		r.Equal(400, res.Code)
 	}
@@ -0,0 +1,7 @@
+/**
+ * Copies the permalink of a page or folder to the clipboard.
+ * Permalinks are based on the ID and keep working when the content is moved.
+ */
+export async function copyPermalink(id: string) {
+  await navigator.clipboard.writeText(`${window.location.origin}/_id/${id}`)
+}
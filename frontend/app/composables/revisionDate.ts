@@ -0,0 +1,7 @@
+/**
+ * Returns the date a revision was saved.
+ * Revisions are Unix timestamps in milliseconds, older revisions in seconds.
+ */
+export function revisionDate(rev: number): Date {
+  return new Date(rev < 100_000_000_000 ? rev * 1000 : rev)
+}
@@ -0,0 +1,64 @@
+import type { DropdownMenuItem } from '@nuxt/ui'
+import type { NotificationMode } from '~/types'
+import { useAuthStore } from '~/store/auth'
+
+/**
+ * Provides menu items to watch a page or folder, or to stop watching it.
+ * Only logged-in users can watch content.
+ */
+export function useWatchMenu(
+  urlPath: () => string,
+  watching: () => NotificationMode | undefined,
+  onChanged: () => void,
+) {
+  const { t } = useI18n()
+  const toast = useToast()
+  const auth = useAuthStore()
+
+  async function setMode(mode: NotificationMode | undefined) {
+    try {
+      if (mode) {
+        await apiFetch(`/subscriptions/${urlPath()}`, { method: 'PUT', body: { mode } })
+      } else {
+        await apiFetch(`/subscriptions/${urlPath()}`, { method: 'DELETE' })
+      }
+      toast.add({ description: mode ? t('watching') : t('not-watching'), color: 'success' })
+      onChanged()
+    } catch (err) {
+      toast.add({ description: String(err), color: 'error' })
+    }
+  }
+
+  const watchMenuItems = computed(() => {
+    const items: DropdownMenuItem[] = []
+    if (!auth.loggedIn) {
+      return items
+    }
+
+    if (watching()) {
+      items.push({
+        icon: 'tabler:eye-off',
+        label: t('stop-watching'),
+        onSelect: () => setMode(undefined),
+      })
+    }
+    if (watching() !== 'immediate') {
+      items.push({
+        icon: 'tabler:eye',
+        label: t('watch-immediately'),
+        onSelect: () => setMode('immediate'),
+      })
+    }
+    if (watching() !== 'digest') {
+      items.push({
+        icon: 'tabler:eye',
+        label: t('watch-daily-digest'),
+        onSelect: () => setMode('digest'),
+      })
+    }
+
+    return items
+  })
+
+  return { watchMenuItems }
+}
@@ -1,4 +1,4 @@
-import type { DeleteUserRequest, LoginRequest, LoginResponse, PatchOperation, RefreshResponse, User } from '~/types'
+import type { DeleteUserRequest, FeedTokenResponse, LoginRequest, LoginResponse, PatchOperation, RefreshResponse, User } from '~/types'
 import { FetchError } from 'ofetch'
 import { defineStore } from 'pinia'
 import { apiRawFetch } from '~/composables/apiFetch'
@@ -101,18 +101,20 @@ export const useAuthStore = defineStore(
       await appStore.refresh()
     }
 
-    async function updateMe(newMe: { displayName: string }) {
+    async function updateMe(newMe: { displayName: string, email: string }) {
       if (!user.value) {
         throw new Error('not logged in')
       }
       const ops: PatchOperation[] = [
         { op: 'replace', path: '/displayName', value: newMe.displayName },
+        { op: 'replace', path: '/email', value: newMe.email },
       ]
       await apiFetch(`/auth/users/${user.value.username}`, {
         method: 'PATCH',
         body: ops,
       })
       user.value.displayName = newMe.displayName
+      user.value.email = newMe.email
     }
 
     async function changePassword(currentPassword: string, newPassword: string) {
@@ -137,6 +139,25 @@ export const useAuthStore = defineStore(
       await logout()
     }
 
+    async function createFeedToken(): Promise<string> {
+      if (!user.value) {
+        throw new Error('not logged in')
+      }
+      const response = await apiFetch<FeedTokenResponse>(`/auth/users/${user.value.username}/feed-token`, {
+        method: 'POST',
+      })
+      return response.token
+    }
+
+    async function deleteFeedToken() {
+      if (!user.value) {
+        throw new Error('not logged in')
+      }
+      await apiFetch(`/auth/users/${user.value.username}/feed-token/delete`, {
+        method: 'POST',
+      })
+    }
+
     return {
       login,
       logout,
@@ -146,6 +167,8 @@ export const useAuthStore = defineStore(
       updateMe,
       changePassword,
       deleteMe,
+      createFeedToken,
+      deleteFeedToken,
       refreshAccessToken,
     }
   },
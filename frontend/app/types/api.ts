@@ -18,6 +18,19 @@ export interface GetAppResponse {
 export interface PutRequest {
   page?: Page
   folder?: Folder
+  baseRevision?: number
+  summary?: string
+  minor?: boolean
+}
+
+export interface PutResponse {
+  page: Page
+  merged: boolean
+}
+
+export interface PatchResponse {
+  rewrittenPages: string[]
+  skippedPages: string[]
 }
 
 export interface GetContentResponse {
@@ -26,6 +39,22 @@ export interface GetContentResponse {
   allowWrite: boolean
   allowDelete: boolean
   breadcrumbs: Breadcrumb[]
+  redirect?: string // New URL of the page or folder if it was moved away from the requested URL
+  watching?: NotificationMode // Notification mode if the user watches the page or folder
+}
+
+export interface ConflictResponse {
+  page: Page | null
+  folder: Folder | null
+  etag: string
+  conflicts?: MergeConflict[]
+}
+
+export interface MergeConflict {
+  line: number
+  base: string[]
+  client: string[]
+  server: string[]
 }
 
 export interface GetAtticListResponse {
@@ -33,13 +62,51 @@ export interface GetAtticListResponse {
   breadcrumbs: Breadcrumb[]
 }
 
+export interface RevisionDiff {
+  from: number
+  to: number | null // null for the current version
+  lines: DiffLine[]
+  meta: MetaDiff
+  simplified?: boolean // True if the versions differ in too many lines to be compared line by line
+}
+
+export type DiffType = 'equal' | 'insert' | 'delete'
+
+export interface DiffLine {
+  type: DiffType
+  text: string
+  oldLine?: number // Line number (1-based) in the old version, omitted for inserted lines
+  newLine?: number // Line number (1-based) in the new version, omitted for deleted lines
+  words?: DiffSegment[] // Word diff of a changed line against the corresponding line of the other version
+}
+
+export interface DiffSegment {
+  type: DiffType
+  text: string
+}
+
+export interface MetaDiff {
+  title?: { old: string, new: string }
+  addedTags?: string[]
+  removedTags?: string[]
+  acl?: { old: AccessRule[] | null, new: AccessRule[] | null, oldExtend: boolean, newExtend: boolean } // Only included for admins
+}
+
 export interface PatchOperation {
-  op: 'replace'
+  op: 'add' | 'remove' | 'replace' | 'move' | 'copy' | 'test' | 'addTag' | 'removeTag' // addTag and removeTag change single tags by value
   path: string
   value?: unknown
   from?: string
 }
 
+export interface RevertRequest {
+  rev: number
+}
+
+export interface RevertResponse {
+  page: Page
+}
+
 export interface LoginRequest {
   username: string
   password: string
@@ -70,8 +137,53 @@ export interface RefreshResponse {
   user: User
 }
 
+export interface FeedTokenResponse {
+  token: string
+}
+
+export type NotificationMode = 'immediate' | 'digest'
+
+export interface Subscription {
+  url: string
+  mode: NotificationMode
+}
+
+export interface GetSubscriptionsResponse {
+  subscriptions: Subscription[]
+  notificationsEnabled: boolean
+}
+
 export interface AtticEntry {
   rev: number
+  modifiedByUsername?: string
+  modifiedByDisplayName?: string
+  summary?: string
+  minor?: boolean
+  size: number // in bytes
+  delta: number // change in size compared to the previous version
+}
+
+export type ChangeType = 'create' | 'edit' | 'move' | 'delete' | 'restore' | 'permissions'
+
+export interface Change {
+  type: ChangeType
+  url: string // URL after the change
+  previousUrl?: string // URL before a move
+  isFolder: boolean
+  title: string
+  time: string
+  rev?: number // Version created by the change
+  summary?: string
+  minor?: boolean
+  modifiedByUsername?: string
+  modifiedByDisplayName?: string
+}
+
+export interface GetChangesResponse {
+  changes: Change[]
+  page: number
+  limit: number
+  hasMore: boolean
 }
 
 export interface TrashEntry {
@@ -113,9 +225,12 @@ export interface Folder {
 }
 
 export interface ContentMeta {
+  id?: string // Immutable, assigned by the server
   title: string
   tags: string[] | null
   acl?: AccessRule[] | null
+  extendAcl?: boolean // ACL extends the inherited ACL instead of replacing it
+  revertedTo?: number // Revision whose content this version restored
   modifiedAt?: string
   modifiedByUsername?: string
   modifiedByDisplayName?: string
@@ -131,7 +246,9 @@ export interface FolderEntry {
 export interface AccessRule {
   subject: string
   ops: AccessOp[] | null
+  deny?: AccessOp[]
   user?: User
+  group?: Group
 }
 
 export enum AccessOp {
@@ -150,10 +267,67 @@ export const ConfigAccessOps = [AccessOp.admin, AccessOp.register] as const
 export type ContentAccessOp = (typeof ContentAccessOps)[number]
 export type ConfigAccessOp = (typeof ConfigAccessOps)[number]
 
+export interface PermissionExplanation {
+  op: AccessOp
+  allowed: boolean
+  source: 'page' | 'folder' | 'admin' | 'none'
+  sourceUrl: string // URL of the page or folder defining the rule, if source is page or folder
+  rule: AccessRule | null // Deciding rule, null if source is none
+  inherited: boolean
+}
+
+export interface GetPermissionsResponse {
+  url: string
+  user: User | null // null for anonymous users
+  operations: PermissionExplanation[]
+}
+
+export interface AccessReportNode {
+  url: string
+  title: string
+  isFolder: boolean
+  ops: AccessOp[] // Allowed operations
+  uniform?: boolean // true if all content of the folder allows the same operations, children are omitted
+  children?: AccessReportNode[] // Only content with accessible pages or folders
+}
+
+export interface AccessReport {
+  subject: string
+  user?: User
+  group?: Group
+  root: AccessReportNode | null // null if nothing is accessible
+}
+
+export type SubtreeAclMode = 'apply' | 'reset'
+
+export interface SubtreeAclRequest {
+  mode: SubtreeAclMode
+  acl?: AccessRule[] | null // Only for mode apply
+  extendAcl?: boolean // Only for mode apply
+  dryRun: boolean
+}
+
+export interface SubtreeAclResponse {
+  affected: string[] // URLs of the pages and folders whose ACL is (or would be) changed
+  failed: { url: string, error: string }[] // Pages and folders whose ACL couldn't be changed
+}
+
 export interface User {
   id: string
   username: string
   displayName: string
+  email: string
+}
+
+export interface Group {
+  id: string
+  name: string
+  members: string[] // IDs of the users in the group
+}
+
+export interface PostGroupRequest {
+  name: string
+  members: string[]
 }
 
 export interface Config {
@@ -165,6 +339,7 @@ export interface Config {
 export interface RetentionConfig {
   trash: TrashRetention
   attic: AtticRetention
+  changes: ChangesRetention
 }
 
 export interface TrashRetention {
@@ -176,6 +351,10 @@ export interface AtticRetention {
   maxVersions: number
 }
 
+export interface ChangesRetention {
+  maxAgeDays: number
+}
+
 export interface SearchHit {
   url: string
   meta: ContentMeta
@@ -188,6 +367,74 @@ export interface SearchResponse {
   page: number
   limit: number
   hasMore: boolean
+  tags: TagCount[]
+  tagsTruncated?: boolean // True if the tags were only counted among the first results
+}
+
+export interface TagCount {
+  tag: string
+  count: number
+}
+
+export interface GetTagsResponse {
+  tags: TagCount[]
+}
+
+export interface ContentSummary {
+  url: string
+  meta: ContentMeta
+  isFolder: boolean
+}
+
+export interface GetTagResponse {
+  tag: string
+  items: ContentSummary[]
+}
+
+export interface LinkedContent {
+  url: string
+  title: string
+  isFolder: boolean
+  exists: boolean
+}
+
+export interface GetLinksResponse {
+  outgoing: LinkedContent[]
+  backlinks: LinkedContent[]
+}
+
+export interface BrokenLink {
+  source: string
+  target: string
+}
+
+export interface LinkReport {
+  brokenLinks: BrokenLink[]
+  orphans: LinkedContent[]
+}
+
+export interface Redirect {
+  from: string
+  to: string
+  createdAt: string
+  stale: boolean // true if content exists at from or to doesn't exist anymore
+}
+
+export interface GetRedirectsResponse {
+  redirects: Redirect[]
+}
+
+export interface DeleteRedirectsRequest {
+  urls: string[]
+}
+
+export interface PruneRedirectsResponse {
+  removed: number
+}
+
+export interface ResolveIDResponse {
+  url: string
+  isFolder: boolean
 }
 
 export interface GetStatsResponse {
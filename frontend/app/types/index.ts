@@ -17,3 +17,6 @@ export type MdEditorGenerator = (text: string) => {
     to: number
   }
 }
+
+// State of an operation for a subject in an ACL
+export type AclOpState = 'none' | 'allow' | 'deny'